@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -24,33 +25,86 @@ func main() {
 		logger.Fatal().Err(err).Msg("failed to load config")
 	}
 
-	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
+	shutdownTracing, err := pkg.NewTracerProvider(context.Background(), pkg.TracingConfig{
+		ServiceName: "api",
+		Exporter:    cfg.Tracing.Exporter,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create tracer provider")
+	}
+
+	dbConfig := pkg.DBClientConfig{
 		Host:     cfg.Postgres.Host,
 		Port:     cfg.Postgres.Port,
 		User:     cfg.Postgres.User,
 		Password: cfg.Postgres.Password,
 		Database: cfg.Postgres.DB,
-	})
+	}
+
+	dbClient, err := pkg.NewDBClient(dbConfig)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("failed to create db client")
 	}
 
-	server := api.NewServer(api.Config{
-		DBClient: dbClient,
-		Logger:   &logger,
+	ethClient, err := pkg.NewEthClient(pkg.EthClientConfig{
+		URL:          cfg.Ethereum.URL,
+		RateLimit:    cfg.Ethereum.RateLimit,
+		RateBurst:    cfg.Ethereum.RateBurst,
+		MaxBatchSize: cfg.Ethereum.MaxBatchSize,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create eth client")
+	}
+
+	redisClient := pkg.NewRedisClient(pkg.RedisClientConfig{
+		Addr: cfg.Redis.Address,
+		DB:   cfg.Redis.DB,
 	})
 
+	dbListener, err := pkg.NewDBListener(dbConfig, nil, api.StreamChannels...)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create db listener")
+	}
+
+	server, err := api.NewServer(api.Config{
+		DBClient:                dbClient,
+		EthClient:               ethClient,
+		RedisClient:             redisClient,
+		DBListener:              dbListener,
+		GraphQLMaxDepth:         cfg.API.GraphQLMaxDepth,
+		GraphQLMaxComplexity:    cfg.API.GraphQLMaxComplexity,
+		AuthEnabled:             cfg.API.AuthEnabled,
+		CacheEnabled:            cfg.API.CacheEnabled,
+		CacheUnfinalizedTTLSecs: cfg.API.CacheUnfinalizedTTLSecs,
+		Logger:                  &logger,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create api server")
+	}
+
 	go func() {
 		logger.Info().Msg("starting api server")
 		server.Run(cfg.API.Port)
 	}()
 
+	opsServer := pkg.NewOpsServer(cfg.Ops.Port, &logger)
+	opsServer.AddCheck("postgres", pkg.PingDB(dbClient))
+	opsServer.AddCheck("redis", pkg.PingRedis(redisClient))
+	opsServer.Start()
+
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
 	<-signalCh
 	logger.Info().Msg("shutting down")
 
+	opsServer.Close()
+
 	server.Close()
 
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error().Err(err).Msg("failed to flush traces")
+	}
+
 	logger.Info().Msg("shutdown complete")
 }
@@ -0,0 +1,135 @@
+// Package main is the admin command of the API keys
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/korprulu/interview-homework-b/internal/app/api"
+	"github.com/korprulu/interview-homework-b/internal/config"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+const usage = `usage:
+  apikey create -name NAME [-rate 10] [-burst 20] [-quota 0]
+  apikey revoke -id ID
+  apikey list`
+
+func main() {
+	if len(os.Args) < 2 {
+		fail(usage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fail("failed to load config: %v", err)
+	}
+
+	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		User:     cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
+	})
+	if err != nil {
+		fail("failed to create db client: %v", err)
+	}
+	defer dbClient.Close()
+
+	ctx := context.Background()
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "create":
+		err = create(ctx, dbClient, args)
+	case "revoke":
+		err = revoke(ctx, dbClient, args)
+	case "list":
+		redisClient := pkg.NewRedisClient(pkg.RedisClientConfig{
+			Addr: cfg.Redis.Address,
+			DB:   cfg.Redis.DB,
+		})
+		defer redisClient.Close()
+		err = list(ctx, dbClient, redisClient)
+	default:
+		fail(usage)
+	}
+	if err != nil {
+		fail("%s failed: %v", os.Args[1], err)
+	}
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+// create creates a key and prints it, the key cannot be shown again
+func create(ctx context.Context, dbClient *pkg.DBClient, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	name := flags.String("name", "", "owner of the key")
+	rate := flags.Float64("rate", 10, "requests per second")
+	burst := flags.Int("burst", 20, "requests allowed at once")
+	quota := flags.Int64("quota", 0, "requests per UTC day, 0 is unlimited")
+	flags.Parse(args)
+
+	if *name == "" || *rate <= 0 || *burst < 1 || *quota < 0 {
+		fail(usage)
+	}
+
+	apiKey := &model.APIKey{Name: *name, RateLimit: *rate, Burst: *burst, DailyQuota: *quota}
+	key, err := model.NewAPIKey(ctx, dbClient, apiKey)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created key %d for %s, store it now as it cannot be shown again:\n%s\n", apiKey.ID, apiKey.Name, key)
+	return nil
+}
+
+func revoke(ctx context.Context, dbClient *pkg.DBClient, args []string) error {
+	flags := flag.NewFlagSet("revoke", flag.ExitOnError)
+	id := flags.Int64("id", 0, "id of the key")
+	flags.Parse(args)
+
+	if *id == 0 {
+		fail(usage)
+	}
+	if err := model.RevokeAPIKey(ctx, dbClient, *id); err != nil {
+		return err
+	}
+	fmt.Printf("revoked key %d\n", *id)
+	return nil
+}
+
+// list prints every key with its usage today
+func list(ctx context.Context, dbClient *pkg.DBClient, redisClient *pkg.RedisClient) error {
+	apiKeys, err := model.ListAPIKeys(ctx, dbClient)
+	if err != nil {
+		return err
+	}
+
+	limiter := pkg.NewRedisRateLimiter(redisClient, api.RateLimitPrefix)
+	now := time.Now()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tRATE\tBURST\tQUOTA\tUSED TODAY\tCREATED\tREVOKED")
+	for _, apiKey := range apiKeys {
+		used, err := limiter.Usage(ctx, strconv.FormatInt(apiKey.ID, 10), now)
+		if err != nil {
+			return err
+		}
+		revoked := "-"
+		if apiKey.RevokedAt != nil {
+			revoked = apiKey.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%g\t%d\t%d\t%d\t%s\t%s\n",
+			apiKey.ID, apiKey.Name, apiKey.Prefix, apiKey.RateLimit, apiKey.Burst, apiKey.DailyQuota, used, apiKey.CreatedAt.Format(time.RFC3339), revoked)
+	}
+	return w.Flush()
+}
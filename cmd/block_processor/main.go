@@ -11,6 +11,7 @@ import (
 	"github.com/korprulu/interview-homework-b/internal/app/processor"
 	"github.com/korprulu/interview-homework-b/internal/config"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 )
 
@@ -25,18 +26,43 @@ func main() {
 		logger.Fatal().Err(err).Msg("failed to load config")
 	}
 
+	shutdownTracing, err := pkg.NewTracerProvider(context.Background(), pkg.TracingConfig{
+		ServiceName: "block-processor",
+		Exporter:    cfg.Tracing.Exporter,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create tracer provider")
+	}
+
 	redisClient := pkg.NewRedisClient(pkg.RedisClientConfig{
 		Addr: cfg.Redis.Address,
 		DB:   cfg.Redis.DB,
 	})
 
 	ethClient, err := pkg.NewEthClient(pkg.EthClientConfig{
-		URL: cfg.Ethereum.URL,
+		URL:          cfg.Ethereum.URL,
+		RateLimit:    cfg.Ethereum.RateLimit,
+		RateBurst:    cfg.Ethereum.RateBurst,
+		MaxBatchSize: cfg.Ethereum.MaxBatchSize,
 	})
 	if err != nil {
 		logger.Fatal().Err(err).Msg("failed to create eth client")
 	}
 
+	var verifyEthClient *pkg.EthClient
+	if cfg.Ethereum.VerificationURL != "" {
+		verifyEthClient, err = pkg.NewEthClient(pkg.EthClientConfig{
+			URL:          cfg.Ethereum.VerificationURL,
+			RateLimit:    cfg.Ethereum.RateLimit,
+			RateBurst:    cfg.Ethereum.RateBurst,
+			MaxBatchSize: cfg.Ethereum.MaxBatchSize,
+		})
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create verification eth client")
+		}
+	}
+
 	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
 		Host:     cfg.Postgres.Host,
 		Port:     cfg.Postgres.Port,
@@ -54,9 +80,13 @@ func main() {
 	blockProcessor, err := processor.NewBlockProcessor(ctx, processor.BlockProcessorConfig{
 		RedisClient:            redisClient,
 		EthClient:              ethClient,
+		VerifyEthClient:        verifyEthClient,
 		DBClient:               dbClient,
 		Logger:                 &logger,
 		ConcurrentCount:        cfg.BlockProcessor.ConcurrentCount,
+		VerifyIntegrity:        cfg.BlockProcessor.VerifyIntegrity,
+		TraceMode:              cfg.BlockProcessor.TraceMode,
+		TrackBalances:          cfg.BlockProcessor.TrackBalances,
 		BlockConsumerSteamName: cfg.BlockProcessor.BlockStreamName,
 		BlockConsumerGroupName: cfg.BlockProcessor.ConsumerGroup,
 		TxProducerStreamName:   cfg.BlockProcessor.TransactionStreamName,
@@ -70,12 +100,31 @@ func main() {
 		blockProcessor.Start(ctx)
 	}()
 
+	prometheus.MustRegister(
+		processor.NewHeadLagCollector(dbClient, ethClient, &logger),
+		pkg.NewStreamCollector(redisClient, &logger, cfg.BlockProcessor.BlockStreamName),
+	)
+
+	opsServer := pkg.NewOpsServer(cfg.Ops.Port, &logger)
+	opsServer.AddCheck("postgres", pkg.PingDB(dbClient))
+	opsServer.AddCheck("redis", pkg.PingRedis(redisClient))
+	opsServer.AddCheck("rpc", pkg.PingRPC(ethClient))
+	opsServer.AddCheck("head_lag", pkg.HeadLagCheck(dbClient, ethClient, cfg.Ops.ReadyMaxHeadLagBlocks))
+	opsServer.AddCheck("stream_lag", pkg.StreamLagCheck(redisClient, cfg.BlockProcessor.BlockStreamName, cfg.BlockProcessor.ConsumerGroup, cfg.Ops.ReadyMaxStreamLag))
+	opsServer.Start()
+
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
 	<-signalCh
 	logger.Info().Msg("shutting down")
 
+	opsServer.Close()
+
 	blockProcessor.Close()
 
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error().Err(err).Msg("failed to flush traces")
+	}
+
 	logger.Info().Msg("shutdown complete")
 }
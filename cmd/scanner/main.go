@@ -25,13 +25,25 @@ func main() {
 		logger.Fatal().Err(err).Msg("failed to load config")
 	}
 
+	shutdownTracing, err := pkg.NewTracerProvider(context.Background(), pkg.TracingConfig{
+		ServiceName: "scanner",
+		Exporter:    cfg.Tracing.Exporter,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create tracer provider")
+	}
+
 	redisClient := pkg.NewRedisClient(pkg.RedisClientConfig{
 		Addr: cfg.Redis.Address,
 		DB:   cfg.Redis.DB,
 	})
 
 	ethClient, err := pkg.NewEthClient(pkg.EthClientConfig{
-		URL: cfg.Ethereum.URL,
+		URL:          cfg.Ethereum.URL,
+		RateLimit:    cfg.Ethereum.RateLimit,
+		RateBurst:    cfg.Ethereum.RateBurst,
+		MaxBatchSize: cfg.Ethereum.MaxBatchSize,
 	})
 	if err != nil {
 		logger.Fatal().Err(err).Msg("failed to create eth client")
@@ -58,12 +70,23 @@ func main() {
 		scannerInstance.Start(ctx)
 	}()
 
+	opsServer := pkg.NewOpsServer(cfg.Ops.Port, &logger)
+	opsServer.AddCheck("redis", pkg.PingRedis(redisClient))
+	opsServer.AddCheck("rpc", pkg.PingRPC(ethClient))
+	opsServer.Start()
+
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
 	<-signalCh
 	logger.Info().Msg("shutting down")
 
+	opsServer.Close()
+
 	scannerInstance.Close()
 
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error().Err(err).Msg("failed to flush traces")
+	}
+
 	logger.Info().Msg("shutdown complete")
 }
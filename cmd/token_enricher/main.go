@@ -0,0 +1,93 @@
+// Package main ...
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/korprulu/interview-homework-b/internal/app/enricher"
+	"github.com/korprulu/interview-homework-b/internal/config"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/rs/zerolog"
+)
+
+func main() {
+	logger := zerolog.
+		New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).
+		With().Timestamp().
+		Logger()
+
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to load config")
+	}
+
+	shutdownTracing, err := pkg.NewTracerProvider(context.Background(), pkg.TracingConfig{
+		ServiceName: "token-enricher",
+		Exporter:    cfg.Tracing.Exporter,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create tracer provider")
+	}
+
+	ethClient, err := pkg.NewEthClient(pkg.EthClientConfig{
+		URL:          cfg.Ethereum.URL,
+		RateLimit:    cfg.Ethereum.RateLimit,
+		RateBurst:    cfg.Ethereum.RateBurst,
+		MaxBatchSize: cfg.Ethereum.MaxBatchSize,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create eth client")
+	}
+
+	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		User:     cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create db client")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	enricherInstance := enricher.NewEnricher(enricher.Config{
+		EthClient:         ethClient,
+		DBClient:          dbClient,
+		Logger:            &logger,
+		BatchSize:         cfg.TokenEnricher.BatchSize,
+		WatchIntervalSecs: cfg.TokenEnricher.WatchIntervalSecs,
+	})
+
+	go func() {
+		logger.Info().Msg("starting token enricher")
+		enricherInstance.Start(ctx)
+	}()
+
+	opsServer := pkg.NewOpsServer(cfg.Ops.Port, &logger)
+	opsServer.AddCheck("postgres", pkg.PingDB(dbClient))
+	opsServer.AddCheck("rpc", pkg.PingRPC(ethClient))
+	opsServer.Start()
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+	<-signalCh
+	logger.Info().Msg("shutting down")
+
+	opsServer.Close()
+
+	enricherInstance.Close()
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error().Err(err).Msg("failed to flush traces")
+	}
+
+	logger.Info().Msg("shutdown complete")
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/korprulu/interview-homework-b/internal/app/processor"
 	"github.com/korprulu/interview-homework-b/internal/config"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 )
 
@@ -25,13 +26,25 @@ func main() {
 		logger.Fatal().Err(err).Msg("failed to load config")
 	}
 
+	shutdownTracing, err := pkg.NewTracerProvider(context.Background(), pkg.TracingConfig{
+		ServiceName: "transaction-processor",
+		Exporter:    cfg.Tracing.Exporter,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create tracer provider")
+	}
+
 	redisClient := pkg.NewRedisClient(pkg.RedisClientConfig{
 		Addr: cfg.Redis.Address,
 		DB:   cfg.Redis.DB,
 	})
 
 	ethClient, err := pkg.NewEthClient(pkg.EthClientConfig{
-		URL: cfg.Ethereum.URL,
+		URL:          cfg.Ethereum.URL,
+		RateLimit:    cfg.Ethereum.RateLimit,
+		RateBurst:    cfg.Ethereum.RateBurst,
+		MaxBatchSize: cfg.Ethereum.MaxBatchSize,
 	})
 	if err != nil {
 		logger.Fatal().Err(err).Msg("failed to create eth client")
@@ -70,12 +83,27 @@ func main() {
 		txProcessor.Start(ctx)
 	}()
 
+	prometheus.MustRegister(pkg.NewStreamCollector(redisClient, &logger, cfg.TransactionProcessor.TransactionStreamName))
+
+	opsServer := pkg.NewOpsServer(cfg.Ops.Port, &logger)
+	opsServer.AddCheck("postgres", pkg.PingDB(dbClient))
+	opsServer.AddCheck("redis", pkg.PingRedis(redisClient))
+	opsServer.AddCheck("rpc", pkg.PingRPC(ethClient))
+	opsServer.AddCheck("stream_lag", pkg.StreamLagCheck(redisClient, cfg.TransactionProcessor.TransactionStreamName, cfg.TransactionProcessor.ConsumerGroup, cfg.Ops.ReadyMaxStreamLag))
+	opsServer.Start()
+
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
 	<-signalCh
 	logger.Info().Msg("shutting down")
 
+	opsServer.Close()
+
 	txProcessor.Close()
 
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error().Err(err).Msg("failed to flush traces")
+	}
+
 	logger.Info().Msg("shutdown complete")
 }
@@ -0,0 +1,84 @@
+// Package main ...
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/korprulu/interview-homework-b/internal/app/dispatcher"
+	"github.com/korprulu/interview-homework-b/internal/config"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/rs/zerolog"
+)
+
+func main() {
+	logger := zerolog.
+		New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).
+		With().Timestamp().
+		Logger()
+
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to load config")
+	}
+
+	shutdownTracing, err := pkg.NewTracerProvider(context.Background(), pkg.TracingConfig{
+		ServiceName: "webhook-dispatcher",
+		Exporter:    cfg.Tracing.Exporter,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create tracer provider")
+	}
+
+	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		User:     cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create db client")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dispatcherInstance := dispatcher.NewDispatcher(dispatcher.Config{
+		DBClient:          dbClient,
+		Logger:            &logger,
+		BatchSize:         cfg.WebhookDispatcher.BatchSize,
+		LookbackBlocks:    cfg.WebhookDispatcher.LookbackBlocks,
+		MaxAttempts:       cfg.WebhookDispatcher.MaxAttempts,
+		TimeoutSecs:       cfg.WebhookDispatcher.TimeoutSecs,
+		WatchIntervalSecs: cfg.WebhookDispatcher.WatchIntervalSecs,
+	})
+
+	go func() {
+		logger.Info().Msg("starting webhook dispatcher")
+		dispatcherInstance.Start(ctx)
+	}()
+
+	opsServer := pkg.NewOpsServer(cfg.Ops.Port, &logger)
+	opsServer.AddCheck("postgres", pkg.PingDB(dbClient))
+	opsServer.Start()
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+	<-signalCh
+	logger.Info().Msg("shutting down")
+
+	opsServer.Close()
+
+	dispatcherInstance.Close()
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error().Err(err).Msg("failed to flush traces")
+	}
+
+	logger.Info().Msg("shutdown complete")
+}
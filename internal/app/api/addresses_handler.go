@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// AddressBalance is the ETH balance DTO
+type AddressBalance struct {
+	Address  string `json:"address"`
+	BlockNum uint64 `json:"block_num"`
+	// Balance is in wei
+	Balance string `json:"balance"`
+}
+
+// GetAddressBalance returns the ETH balance of an address at a block of the
+// canonical chain by summing its balance changes, the latest indexed block is
+// used when block is not given
+func (h *Server) GetAddressBalance(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address"})
+		return
+	}
+
+	var blockNum uint64
+	if block, ok := c.GetQuery("block"); ok {
+		number, err := strconv.ParseUint(block, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid block"})
+			return
+		}
+		blockNum = number
+	} else {
+		row := h.dbClient.QueryRowContext(ctx, "SELECT COALESCE(MAX(number), 0) FROM blocks WHERE is_uncle = false")
+		if err := row.Scan(&blockNum); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	balance := AddressBalance{
+		Address:  common.HexToAddress(address).Hex(),
+		BlockNum: blockNum,
+	}
+	row := h.dbClient.QueryRowContext(ctx, "SELECT COALESCE(SUM(c.delta), 0)::TEXT FROM address_balance_changes c JOIN blocks b ON b.hash = c.block_hash AND b.number = c.block_number WHERE c.address = $1 AND c.block_number <= $2 AND b.is_uncle = false", balance.Address, blockNum)
+	if err := row.Scan(&balance.Balance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
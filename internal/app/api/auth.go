@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/rs/zerolog"
+)
+
+// RateLimitPrefix starts the Redis keys of the rate limits and the usage of
+// the API keys
+const RateLimitPrefix = "ratelimit:apikey"
+
+const (
+	// apiKeyHeader is the header carrying the API key, Authorization: Bearer
+	// and the api_key query param are accepted as well for clients that
+	// cannot set headers, like EventSource
+	apiKeyHeader = "X-API-Key"
+	// apiKeyCacheTTL is how long a looked up key is trusted, a revoked key
+	// stops working within it
+	apiKeyCacheTTL = 30 * time.Second
+)
+
+// rateLimiter takes a token for a request of a key
+type rateLimiter interface {
+	Allow(ctx context.Context, key string, limit pkg.RateLimit) (pkg.RateLimitResult, error)
+}
+
+type cachedAPIKey struct {
+	apiKey  *model.APIKey
+	expires time.Time
+}
+
+// authenticator rejects requests without an active API key and enforces the
+// rate limit and the quota of the key
+type authenticator struct {
+	lookup  func(ctx context.Context, key string) (*model.APIKey, error)
+	limiter rateLimiter
+	logger  *zerolog.Logger
+
+	mu    sync.Mutex
+	cache map[string]cachedAPIKey
+}
+
+func newAuthenticator(dbClient *pkg.DBClient, limiter rateLimiter, logger *zerolog.Logger) *authenticator {
+	return &authenticator{
+		lookup: func(ctx context.Context, key string) (*model.APIKey, error) {
+			return model.GetAPIKey(ctx, dbClient, key)
+		},
+		limiter: limiter,
+		logger:  logger,
+		cache:   map[string]cachedAPIKey{},
+	}
+}
+
+// apiKeyFromRequest returns the API key of a request, empty when there is none
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// redactAPIKey hides the api_key query param of a logged request path
+func redactAPIKey(path string) string {
+	base, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base
+	}
+	if !query.Has("api_key") {
+		return path
+	}
+	query.Set("api_key", "REDACTED")
+	return base + "?" + query.Encode()
+}
+
+// get returns the active key of a key, unknown keys are not cached so a new
+// key works at once
+func (a *authenticator) get(ctx context.Context, key string) (*model.APIKey, error) {
+	hash := model.HashAPIKey(key)
+	now := time.Now()
+
+	a.mu.Lock()
+	cached, ok := a.cache[hash]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.apiKey, nil
+	}
+
+	apiKey, err := a.lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// drop the expired keys once the cache grows
+	if len(a.cache) >= 1024 {
+		for hash, cached := range a.cache {
+			if now.After(cached.expires) {
+				delete(a.cache, hash)
+			}
+		}
+	}
+	a.cache[hash] = cachedAPIKey{apiKey: apiKey, expires: now.Add(apiKeyCacheTTL)}
+	return apiKey, nil
+}
+
+// middleware authenticates a request. The limits are not enforced when Redis
+// fails, an outage of the limiter must not take the API down.
+func (a *authenticator) middleware(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	key := apiKeyFromRequest(c.Request)
+	if key == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing api key"})
+		return
+	}
+
+	apiKey, err := a.get(ctx, key)
+	if err != nil {
+		if errors.Is(err, model.ErrAPIKeyNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := a.limiter.Allow(ctx, strconv.FormatInt(apiKey.ID, 10), pkg.RateLimit{
+		Rate:       apiKey.RateLimit,
+		Burst:      apiKey.Burst,
+		DailyQuota: apiKey.DailyQuota,
+	})
+	if err != nil {
+		a.logger.Warn().Err(err).Int64("api_key_id", apiKey.ID).Msg("failed to rate limit request")
+		c.Next()
+		return
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(apiKey.Burst))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	if result.QuotaExceeded {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "daily quota exceeded"})
+		return
+	}
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return
+	}
+	c.Next()
+}
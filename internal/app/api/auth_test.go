@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/rs/zerolog"
+)
+
+type stubRateLimiter struct {
+	result pkg.RateLimitResult
+	err    error
+}
+
+func (l stubRateLimiter) Allow(ctx context.Context, key string, limit pkg.RateLimit) (pkg.RateLimitResult, error) {
+	return l.result, l.err
+}
+
+func TestAuthenticatorMiddleware(t *testing.T) {
+	t.Parallel()
+
+	const key = "bik_valid"
+	logger := zerolog.Nop()
+	tests := []struct {
+		name       string
+		header     http.Header
+		query      string
+		limiter    stubRateLimiter
+		expected   int
+		retryAfter string
+	}{
+		{name: "missing key", expected: http.StatusUnauthorized},
+		{name: "unknown key", header: http.Header{apiKeyHeader: {"bik_unknown"}}, expected: http.StatusUnauthorized},
+		{name: "header", header: http.Header{apiKeyHeader: {key}}, limiter: stubRateLimiter{result: pkg.RateLimitResult{Allowed: true}}, expected: http.StatusNoContent},
+		{name: "bearer", header: http.Header{"Authorization": {"Bearer " + key}}, limiter: stubRateLimiter{result: pkg.RateLimitResult{Allowed: true}}, expected: http.StatusNoContent},
+		{name: "query", query: "?api_key=" + key, limiter: stubRateLimiter{result: pkg.RateLimitResult{Allowed: true}}, expected: http.StatusNoContent},
+		{name: "rate limited", header: http.Header{apiKeyHeader: {key}}, limiter: stubRateLimiter{result: pkg.RateLimitResult{RetryAfter: 1500 * time.Millisecond}}, expected: http.StatusTooManyRequests, retryAfter: "2"},
+		{name: "quota exceeded", header: http.Header{apiKeyHeader: {key}}, limiter: stubRateLimiter{result: pkg.RateLimitResult{QuotaExceeded: true}}, expected: http.StatusTooManyRequests},
+		{name: "limiter down", header: http.Header{apiKeyHeader: {key}}, limiter: stubRateLimiter{err: errors.New("connection refused")}, expected: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		auth := &authenticator{
+			lookup: func(ctx context.Context, k string) (*model.APIKey, error) {
+				if k != key {
+					return nil, model.ErrAPIKeyNotFound
+				}
+				return &model.APIKey{ID: 1, RateLimit: 1, Burst: 1}, nil
+			},
+			limiter: test.limiter,
+			logger:  &logger,
+			cache:   map[string]cachedAPIKey{},
+		}
+		router := gin.New()
+		router.GET("/blocks", auth.middleware, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/blocks"+test.query, nil)
+		for name, values := range test.header {
+			req.Header.Set(name, values[0])
+		}
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != test.expected {
+			t.Errorf("%s: Expected %d, got %d: %s", test.name, test.expected, recorder.Code, recorder.Body)
+		}
+		if got := recorder.Header().Get("Retry-After"); got != test.retryAfter {
+			t.Errorf("%s: Expected Retry-After %q, got %q", test.name, test.retryAfter, got)
+		}
+	}
+}
+
+func TestRedactAPIKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/blocks", expected: "/blocks"},
+		{path: "/blocks?limit=10", expected: "/blocks?limit=10"},
+		{path: "/stream?api_key=bik_secret", expected: "/stream?api_key=REDACTED"},
+		{path: "/stream?topic=blocks&api_key=bik_secret", expected: "/stream?api_key=REDACTED&topic=blocks"},
+		{path: "/stream?api_key=bik_secret&%zz", expected: "/stream"},
+	}
+
+	for _, test := range tests {
+		if got := redactAPIKey(test.path); got != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, got)
+		}
+	}
+}
+
+func TestLogFormatterRedactsAPIKey(t *testing.T) {
+	t.Parallel()
+
+	line := logFormatter(gin.LogFormatterParams{Method: http.MethodGet, Path: "/stream?api_key=bik_secret", StatusCode: http.StatusOK})
+	if strings.Contains(line, "bik_secret") {
+		t.Errorf("Expected the API key to be redacted, got %s", line)
+	}
+}
+
+func TestAuthenticatorCache(t *testing.T) {
+	t.Parallel()
+
+	lookups := 0
+	auth := &authenticator{
+		lookup: func(ctx context.Context, key string) (*model.APIKey, error) {
+			lookups++
+			return &model.APIKey{ID: 1}, nil
+		},
+		cache: map[string]cachedAPIKey{},
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := auth.get(context.Background(), "bik_key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("Expected 1 lookup, got %d", lookups)
+	}
+}
@@ -1,10 +1,16 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,34 +21,145 @@ type Block struct {
 	BlockTime  uint64 `json:"block_time"`
 	ParentHash string `json:"parent_hash"`
 	IsUncle    bool   `json:"is_uncle"`
+	// Status is unfinalized, or finalized once checked by the validator
+	Status string `json:"status"`
+
+	Miner                 string  `json:"miner"`
+	GasUsed               uint64  `json:"gas_used"`
+	GasLimit              uint64  `json:"gas_limit"`
+	BaseFeePerGas         string  `json:"base_fee_per_gas,omitempty"`
+	Difficulty            string  `json:"difficulty"`
+	ExtraData             string  `json:"extra_data"`
+	StateRoot             string  `json:"state_root"`
+	TransactionsRoot      string  `json:"transactions_root"`
+	ReceiptsRoot          string  `json:"receipts_root"`
+	LogsBloom             string  `json:"logs_bloom"`
+	Sha3Uncles            string  `json:"sha3_uncles"`
+	MixHash               string  `json:"mix_hash"`
+	Nonce                 string  `json:"nonce"`
+	Size                  uint64  `json:"size"`
+	WithdrawalsRoot       string  `json:"withdrawals_root,omitempty"`
+	BlobGasUsed           *uint64 `json:"blob_gas_used,omitempty"`
+	ExcessBlobGas         *uint64 `json:"excess_blob_gas,omitempty"`
+	ParentBeaconBlockRoot string  `json:"parent_beacon_block_root,omitempty"`
+}
+
+// Withdrawal is the withdrawal DTO
+type Withdrawal struct {
+	Index          uint64 `json:"index"`
+	ValidatorIndex uint64 `json:"validator_index"`
+	Address        string `json:"address"`
+	Amount         uint64 `json:"amount"`
+}
+
+// Uncle is the ommer header DTO
+type Uncle struct {
+	Index      uint64 `json:"index"`
+	BlockNum   uint64 `json:"block_num"`
+	BlockHash  string `json:"block_hash"`
+	ParentHash string `json:"parent_hash"`
+	BlockTime  uint64 `json:"block_time"`
+	Miner      string `json:"miner"`
+	Difficulty string `json:"difficulty"`
+	GasLimit   uint64 `json:"gas_limit"`
+	GasUsed    uint64 `json:"gas_used"`
 }
 
 // BlockByID is the block DTO with transactions
 type BlockByID struct {
 	Block
-	Transactions []string `json:"transactions"`
+	// Orphaned is set when the block was replaced by a reorg, only a lookup
+	// by hash returns such a block
+	Orphaned bool `json:"orphaned"`
+	// Transactions are the transaction hashes, or the Transaction DTOs with
+	// expand=transactions
+	Transactions any          `json:"transactions"`
+	Withdrawals  []Withdrawal `json:"withdrawals,omitempty"`
+	Uncles       []Uncle      `json:"uncles,omitempty"`
+}
+
+// blockColumns are the columns scanned by scanBlock
+const blockColumns = "number, hash, timestamp, parent_hash, is_uncle, COALESCE(status, ''), miner, gas_used, gas_limit, COALESCE(base_fee_per_gas::text, ''), COALESCE(difficulty::text, ''), extra_data, state_root, transactions_root, receipts_root, logs_bloom, sha3_uncles, mix_hash, nonce, size, withdrawals_root, blob_gas_used, excess_blob_gas, parent_beacon_block_root"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanBlock scans a row selected with blockColumns
+func scanBlock(row rowScanner, block *Block) error {
+	return row.Scan(
+		&block.BlockNum, &block.BlockHash, &block.BlockTime, &block.ParentHash, &block.IsUncle, &block.Status,
+		&block.Miner, &block.GasUsed, &block.GasLimit, &block.BaseFeePerGas, &block.Difficulty, &block.ExtraData,
+		&block.StateRoot, &block.TransactionsRoot, &block.ReceiptsRoot, &block.LogsBloom, &block.Sha3Uncles, &block.MixHash, &block.Nonce,
+		&block.Size, &block.WithdrawalsRoot, &block.BlobGasUsed, &block.ExcessBlobGas, &block.ParentBeaconBlockRoot,
+	)
+}
+
+// blocksOrder sorts blocks from the newest, uncle blocks share the number of
+// the canonical block
+var blocksOrder = pageOrder{
+	columns: []keyColumn{{name: "number", numeric: true}, {name: "hash"}},
+	desc:    true,
 }
 
-// Blocks is a slice of Block
-type Blocks []Block
+// blockRangeFilters are the optional range query params of GetBlocks
+var blockRangeFilters = []struct {
+	param     string
+	condition string
+}{
+	{param: "from_block", condition: "number >="},
+	{param: "to_block", condition: "number <="},
+	{param: "from_time", condition: "timestamp >="},
+	{param: "to_time", condition: "timestamp <="},
+}
 
-// GetBlocks returns all blocks
+// GetBlocks returns a page of blocks from the newest, optionally within a
+// block number and timestamp range. Uncle blocks are only listed with
+// include_uncles.
 func (h *Server) GetBlocks(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	limit := c.DefaultQuery("limit", "10")
-	rows, err := h.dbClient.QueryContext(ctx, "SELECT number, hash, timestamp, parent_hash, is_uncle FROM blocks ORDER BY number DESC LIMIT $1", limit)
+	params, err := parsePageParams(c, blocksOrder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	includeUncles, err := queryBool(c, "include_uncles")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var conditions []string
+	var args []any
+	if !includeUncles {
+		conditions = append(conditions, "is_uncle = false")
+	}
+	for _, filter := range blockRangeFilters {
+		value, err := queryUint(c, filter.param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if value != nil {
+			args = append(args, *value)
+			conditions = append(conditions, fmt.Sprintf("%s $%d", filter.condition, len(args)))
+		}
+	}
+
+	statement, args := params.paginate("SELECT "+blockColumns+" FROM blocks", conditions, args)
+	rows, err := h.dbClient.QueryContext(ctx, statement, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	var blocks Blocks
+	var blocks []Block
 
 	for rows.Next() {
 		var block Block
-		err := rows.Scan(&block.BlockNum, &block.BlockHash, &block.BlockTime, &block.ParentHash, &block.IsUncle)
+		err := scanBlock(rows, &block)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -51,18 +168,201 @@ func (h *Server) GetBlocks(c *gin.Context) {
 		blocks = append(blocks, block)
 	}
 
-	c.JSON(http.StatusOK, blocks)
+	c.JSON(http.StatusOK, newPage(blocks, params, func(block Block) []string {
+		return []string{strconv.FormatUint(block.BlockNum, 10), block.BlockHash}
+	}))
+}
+
+// blockQueryByID returns the query of a block by number, hash or tag. Numbers
+// and tags select canonical blocks, a hash also selects an orphaned block.
+func blockQueryByID(id string) (statement string, args []any, err error) {
+	switch id {
+	case "latest":
+		return "SELECT " + blockColumns + " FROM blocks WHERE is_uncle = false ORDER BY number DESC LIMIT 1", nil, nil
+	case "finalized", "safe":
+		return "SELECT " + blockColumns + " FROM blocks WHERE is_uncle = false AND status = 'finalized' ORDER BY number DESC LIMIT 1", nil, nil
+	}
+
+	if strings.HasPrefix(id, "0x") {
+		hash, err := hexutil.Decode(id)
+		if err != nil || len(hash) != common.HashLength {
+			return "", nil, errors.New("invalid block hash")
+		}
+		return "SELECT " + blockColumns + " FROM blocks WHERE hash = $1 ORDER BY is_uncle LIMIT 1", []any{common.BytesToHash(hash).Hex()}, nil
+	}
+
+	number, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return "", nil, errors.New("invalid block id")
+	}
+	return "SELECT " + blockColumns + " FROM blocks WHERE number = $1 AND is_uncle = false", []any{number}, nil
 }
 
-// GetBlockByID returns a block by id
+// GetBlockByID returns a block by number, hash, or the tags latest, finalized
+// and safe. Finalized and safe are the newest block checked by the validator.
+// expand=transactions,logs embeds the full transactions and their logs.
 func (h *Server) GetBlockByID(c *gin.Context) {
-	ctx := c.Request.Context()
+	statement, args, err := blockQueryByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expand, err := parseBlockExpand(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// a tag moves to newer blocks, a number or a hash always selects the same
+	// block once it is finalized
+	cacheable := !isBlockTag(c.Param("id"))
+	h.respondBlock(c, h.dbClient.QueryRowContext(c.Request.Context(), statement, args...), expand, cacheable)
+}
+
+// isBlockTag reports whether a block id is a tag
+func isBlockTag(id string) bool {
+	switch id {
+	case "latest", "finalized", "safe":
+		return true
+	}
+	return false
+}
+
+// GetBlockByTime returns the canonical block closest at or before the unix
+// timestamp ts
+func (h *Server) GetBlockByTime(c *gin.Context) {
+	ts, err := strconv.ParseUint(c.Query("ts"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ts"})
+		return
+	}
+	expand, err := parseBlockExpand(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the timestamp index turns this into a single descent of the b-tree
+	row := h.dbClient.QueryRowContext(c.Request.Context(), "SELECT "+blockColumns+" FROM blocks WHERE timestamp <= $1 AND is_uncle = false ORDER BY timestamp DESC, number DESC LIMIT 1", ts)
+	h.respondBlock(c, row, expand, false)
+}
+
+// blockExpand are the parts embedded in a block response
+type blockExpand struct {
+	transactions bool
+	logs         bool
+}
+
+// parseBlockExpand parses the comma separated expand query param, logs are
+// embedded in the transactions so they expand both
+func parseBlockExpand(c *gin.Context) (blockExpand, error) {
+	var expand blockExpand
+	value := c.Query("expand")
+	if value == "" {
+		return expand, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		switch strings.TrimSpace(part) {
+		case "transactions":
+			expand.transactions = true
+		case "logs":
+			expand.transactions = true
+			expand.logs = true
+		default:
+			return expand, fmt.Errorf("invalid expand %q", part)
+		}
+	}
+	return expand, nil
+}
+
+// queryBlockTransactionHashes returns the hashes of the transactions of a
+// block in order
+func (h *Server) queryBlockTransactionHashes(ctx context.Context, blockHash string) ([]string, error) {
+	rows, err := h.dbClient.QueryContext(ctx, "SELECT hash FROM transactions WHERE block_hash = $1 ORDER BY index", blockHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := make([]string, 0)
+	for rows.Next() {
+		var transaction string
+		if err := rows.Scan(&transaction); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// queryBlockUncles returns the ommer headers referenced by a block in order
+func (h *Server) queryBlockUncles(ctx context.Context, blockHash string) ([]Uncle, error) {
+	rows, err := h.dbClient.QueryContext(ctx, "SELECT index, number, hash, parent_hash, timestamp, miner, COALESCE(difficulty::text, ''), gas_limit, gas_used FROM uncles WHERE block_hash = $1 ORDER BY index", blockHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uncles []Uncle
+	for rows.Next() {
+		var uncle Uncle
+		err := rows.Scan(&uncle.Index, &uncle.BlockNum, &uncle.BlockHash, &uncle.ParentHash, &uncle.BlockTime, &uncle.Miner, &uncle.Difficulty, &uncle.GasLimit, &uncle.GasUsed)
+		if err != nil {
+			return nil, err
+		}
+		uncles = append(uncles, uncle)
+	}
+	return uncles, rows.Err()
+}
+
+// queryBlockTransactions returns the transactions of a block in order with one
+// query, the logs are stored with the transactions
+func (h *Server) queryBlockTransactions(ctx context.Context, blockHash string, withLogs bool) ([]Transaction, error) {
+	rows, err := h.dbClient.QueryContext(ctx, "SELECT "+transactionColumns+" FROM transactions t JOIN blocks b ON b.hash = t.block_hash AND b.number = t.block_number WHERE t.block_hash = $1 ORDER BY t.index", blockHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := make([]Transaction, 0)
+	for rows.Next() {
+		tx, blockStatus, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, toTransactionDTO(tx, blockStatus, withLogs))
+	}
+	return transactions, rows.Err()
+}
+
+// queryBlockWithdrawals returns the withdrawals of a block in order, nil when
+// it has none
+func (h *Server) queryBlockWithdrawals(ctx context.Context, blockHash string) ([]Withdrawal, error) {
+	rows, err := h.dbClient.QueryContext(ctx, "SELECT index, validator_index, address, amount FROM withdrawals WHERE block_hash = $1 ORDER BY index", blockHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var withdrawals []Withdrawal
+	for rows.Next() {
+		var withdrawal Withdrawal
+		if err := rows.Scan(&withdrawal.Index, &withdrawal.ValidatorIndex, &withdrawal.Address, &withdrawal.Amount); err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, withdrawal)
+	}
+	return withdrawals, rows.Err()
+}
 
-	number := c.Param("id")
-	row := h.dbClient.QueryRowContext(ctx, "SELECT number, hash, timestamp, parent_hash, is_uncle FROM blocks WHERE number = $1 AND is_uncle = false", number)
+// respondBlock responds the block of a row selected with blockColumns with
+// its transactions, withdrawals and uncles, a cacheable response is cached for
+// good once the block is finalized
+func (h *Server) respondBlock(c *gin.Context, row *sql.Row, expand blockExpand, cacheable bool) {
+	ctx := c.Request.Context()
 
 	var block BlockByID
-	err := row.Scan(&block.BlockNum, &block.BlockHash, &block.BlockTime, &block.ParentHash, &block.IsUncle)
+	err := scanBlock(row, &block.Block)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "block not found"})
@@ -71,28 +371,32 @@ func (h *Server) GetBlockByID(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	block.Orphaned = block.IsUncle
 
-	rows, err := h.dbClient.QueryContext(ctx, "SELECT hash FROM transactions WHERE block_number = $1 AND block_hash = $2", number, block.BlockHash)
+	if expand.transactions {
+		block.Transactions, err = h.queryBlockTransactions(ctx, block.BlockHash, expand.logs)
+	} else {
+		block.Transactions, err = h.queryBlockTransactionHashes(ctx, block.BlockHash)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	var transactions []string
 
-	for rows.Next() {
-		var transaction string
-		err := rows.Scan(&transaction)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		transactions = append(transactions, transaction)
+	block.Withdrawals, err = h.queryBlockWithdrawals(ctx, block.BlockHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	block.Transactions = transactions
+	block.Uncles, err = h.queryBlockUncles(ctx, block.BlockHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
+	if cacheable {
+		setCachePolicy(c, block.Status == "finalized" && !block.IsUncle, block.BlockHash)
+	}
 	c.JSON(http.StatusOK, block)
 }
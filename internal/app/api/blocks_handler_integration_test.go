@@ -0,0 +1,73 @@
+//go:build integration
+
+package api
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/korprulu/interview-homework-b/internal/config"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+func TestQueryBlockUncles(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		User:     cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbClient.Close()
+
+	ctx := context.Background()
+	block := &model.Block{
+		Number:     uint64(3),
+		Hash:       "0xabc3",
+		ParentHash: "0xabc2",
+		Timestamp:  uint64(time.Now().Unix()),
+		Status:     "unfinalized",
+		Miner:      "0x124",
+		Uncles: model.Uncles{
+			{BlockNumber: uint64(3), BlockHash: "0xabc3", Index: uint64(0), Number: uint64(2), Hash: "0xdef2", ParentHash: "0xabc1", Miner: "0x126", Timestamp: uint64(1), Difficulty: "17171480576", GasLimit: uint64(5000000), GasUsed: uint64(21000)},
+		},
+	}
+
+	if err := block.Save(ctx, dbClient); err != nil {
+		t.Fatal(err)
+	}
+	defer dbClient.ExecContext(ctx, "DELETE FROM blocks WHERE number = $1 AND hash = $2", block.Number, block.Hash)
+	defer dbClient.ExecContext(ctx, "DELETE FROM uncles WHERE block_hash = $1", block.Hash)
+
+	server := &Server{dbClient: dbClient}
+	actual, err := server.queryBlockUncles(ctx, block.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Uncle{
+		{Index: 0, BlockNum: 2, BlockHash: "0xdef2", ParentHash: "0xabc1", BlockTime: 1, Miner: "0x126", Difficulty: "17171480576", GasLimit: 5000000, GasUsed: 21000},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+
+	actual, err = server.queryBlockUncles(ctx, "0xnone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 0 {
+		t.Errorf("Expected no uncles, got %+v", actual)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/korprulu/interview-homework-b/internal/model"
+)
+
+func TestBlockQueryByID(t *testing.T) {
+	t.Parallel()
+
+	hash := common.HexToHash("0xabc").Hex()
+	tests := []struct {
+		id        string
+		condition string
+		args      []any
+		err       bool
+	}{
+		{id: "100", condition: "WHERE number = $1 AND is_uncle = false", args: []any{uint64(100)}},
+		{id: "latest", condition: "WHERE is_uncle = false ORDER BY number DESC"},
+		{id: "finalized", condition: "status = 'finalized'"},
+		{id: "safe", condition: "status = 'finalized'"},
+		{id: strings.ToLower(hash), condition: "WHERE hash = $1 ORDER BY is_uncle", args: []any{hash}},
+		{id: "0xabc", err: true},
+		{id: "pending", err: true},
+		{id: "-1", err: true},
+	}
+
+	for _, test := range tests {
+		statement, args, err := blockQueryByID(test.id)
+		if (err != nil) != test.err {
+			t.Errorf("Expected error %v for %s, got %v", test.err, test.id, err)
+			continue
+		}
+		if !strings.Contains(statement, test.condition) {
+			t.Errorf("Expected %q in the query of %s, got %q", test.condition, test.id, statement)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("Expected args %v for %s, got %v", test.args, test.id, args)
+		}
+	}
+}
+
+func TestParseBlockExpand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query    string
+		expected blockExpand
+		err      bool
+	}{
+		{query: ""},
+		{query: "expand=transactions", expected: blockExpand{transactions: true}},
+		{query: "expand=logs", expected: blockExpand{transactions: true, logs: true}},
+		{query: "expand=transactions,%20logs", expected: blockExpand{transactions: true, logs: true}},
+		{query: "expand=receipts", err: true},
+	}
+
+	for _, test := range tests {
+		expand, err := parseBlockExpand(newTestContext(test.query))
+		if (err != nil) != test.err {
+			t.Errorf("Expected error %v for %q, got %v", test.err, test.query, err)
+			continue
+		}
+		if expand != test.expected {
+			t.Errorf("Expected %+v for %q, got %+v", test.expected, test.query, expand)
+		}
+	}
+}
+
+func TestToTransactionDTO(t *testing.T) {
+	t.Parallel()
+
+	tx := &model.Transaction{
+		Hash:        common.HexToHash("0x01").Hex(),
+		BlockNumber: 100,
+		BlockHash:   common.HexToHash("0x02").Hex(),
+		Logs:        model.TransactionLogs{{Index: 1, Data: "0x"}},
+	}
+
+	txDTO := toTransactionDTO(tx, "orphaned", false)
+	if txDTO.BlockNumber != 100 || txDTO.BlockHash != tx.BlockHash || txDTO.BlockStatus != "orphaned" {
+		t.Errorf("Expected block 100 %s orphaned, got %d %s %s", tx.BlockHash, txDTO.BlockNumber, txDTO.BlockHash, txDTO.BlockStatus)
+	}
+	if txDTO.Logs != nil {
+		t.Errorf("Expected no logs, got %v", txDTO.Logs)
+	}
+
+	txDTO = toTransactionDTO(&model.Transaction{}, "finalized", true)
+	if txDTO.Logs == nil || len(txDTO.Logs) != 0 {
+		t.Errorf("Expected empty logs, got %v", txDTO.Logs)
+	}
+	if len(toTransactionDTO(tx, "finalized", true).Logs) != 1 {
+		t.Errorf("Expected 1 log")
+	}
+}
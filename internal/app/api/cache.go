@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// finalizedCacheTTL is how long a finalized response is kept in Redis,
+	// clients may keep it for good
+	finalizedCacheTTL = 24 * time.Hour
+	// finalizedCacheControl marks a finalized response as never changing
+	finalizedCacheControl = "public, max-age=31536000, immutable"
+	// cachePolicyKey is the context key of the cache policy of a response
+	cachePolicyKey = "cache_policy"
+)
+
+// cachePolicy is set by a handler whose response may be cached. A finalized
+// response never changes, any other response is kept briefly and dropped
+// when its block becomes an uncle.
+type cachePolicy struct {
+	finalized bool
+	blockHash string
+}
+
+// setCachePolicy allows the response of a request to be cached
+func setCachePolicy(c *gin.Context, finalized bool, blockHash string) {
+	c.Set(cachePolicyKey, cachePolicy{finalized: finalized, blockHash: blockHash})
+}
+
+// responseStore keeps cached responses
+type responseStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, blockHash string) error
+}
+
+// cachedResponse is a response kept in the store
+type cachedResponse struct {
+	ETag         string `json:"etag"`
+	ContentType  string `json:"content_type"`
+	CacheControl string `json:"cache_control"`
+	Body         []byte `json:"body"`
+}
+
+// responseCache serves the responses of the routes it wraps from the store
+type responseCache struct {
+	store          responseStore
+	unfinalizedTTL time.Duration
+	logger         *zerolog.Logger
+}
+
+// bufferedWriter holds the body of a response until it is known whether the
+// client already has it
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// cacheKey identifies the response of a request, the API key is left out so
+// every client shares the response
+func cacheKey(r *http.Request) string {
+	query := r.URL.Query()
+	query.Del("api_key")
+	sum := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + "?" + query.Encode()))
+	return hex.EncodeToString(sum[:])
+}
+
+// etagOf returns the strong ETag of a body
+func etagOf(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified reports whether the client already has the response of etag
+func notModified(r *http.Request, etag string) bool {
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == etag || tag == "W/"+etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// write responds a cached response, without a body when the client has it
+func (r *cachedResponse) write(c *gin.Context) {
+	c.Header("ETag", r.ETag)
+	c.Header("Cache-Control", r.CacheControl)
+	if notModified(c.Request, r.ETag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Data(http.StatusOK, r.ContentType, r.Body)
+}
+
+// middleware serves a cached response, or runs the handler and caches its
+// response when the handler set a cache policy. Requests are served uncached
+// when the store fails.
+func (rc *responseCache) middleware(c *gin.Context) {
+	ctx := c.Request.Context()
+	key := cacheKey(c.Request)
+
+	if value, err := rc.store.Get(ctx, key); err != nil {
+		rc.logger.Warn().Err(err).Msg("failed to get cached response")
+	} else if value != nil {
+		var cached cachedResponse
+		if err := json.Unmarshal(value, &cached); err == nil {
+			c.Header("X-Cache", "HIT")
+			cached.write(c)
+			c.Abort()
+			return
+		}
+	}
+
+	writer := &bufferedWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	value, ok := c.Get(cachePolicyKey)
+	if !ok || c.Writer.Status() != http.StatusOK {
+		c.Writer.Write(writer.body.Bytes())
+		return
+	}
+	policy := value.(cachePolicy)
+
+	cached := cachedResponse{
+		ETag:         etagOf(writer.body.Bytes()),
+		ContentType:  c.Writer.Header().Get("Content-Type"),
+		CacheControl: finalizedCacheControl,
+		Body:         writer.body.Bytes(),
+	}
+	ttl, blockHash := finalizedCacheTTL, ""
+	if !policy.finalized {
+		ttl, blockHash = rc.unfinalizedTTL, policy.blockHash
+		cached.CacheControl = fmt.Sprintf("public, max-age=%d", int(rc.unfinalizedTTL.Seconds()))
+	}
+
+	if ttl > 0 {
+		if value, err := json.Marshal(cached); err != nil {
+			rc.logger.Warn().Err(err).Msg("failed to encode cached response")
+		} else if err := rc.store.Set(ctx, key, value, ttl, blockHash); err != nil {
+			rc.logger.Warn().Err(err).Msg("failed to cache response")
+		}
+	} else {
+		cached.CacheControl = "no-cache"
+	}
+
+	c.Header("X-Cache", "MISS")
+	cached.write(c)
+}
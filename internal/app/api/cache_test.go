@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	ttls    map[string]time.Duration
+	tags    map[string]string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: map[string][]byte{}, ttls: map[string]time.Duration{}, tags: map[string]string{}}
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key], nil
+}
+
+func (s *memoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration, blockHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key], s.ttls[key], s.tags[key] = value, ttl, blockHash
+	return nil
+}
+
+func TestResponseCache(t *testing.T) {
+	t.Parallel()
+
+	logger := zerolog.Nop()
+	store := newMemoryStore()
+	cache := &responseCache{store: store, unfinalizedTTL: 2 * time.Second, logger: &logger}
+
+	calls := 0
+	router := gin.New()
+	router.GET("/blocks/:id", cache.middleware, func(c *gin.Context) {
+		calls++
+		switch c.Param("id") {
+		case "1":
+			setCachePolicy(c, true, "0x01")
+		case "2":
+			setCachePolicy(c, false, "0x02")
+		case "404":
+			setCachePolicy(c, true, "")
+			c.JSON(http.StatusNotFound, gin.H{"error": "block not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, values := range header {
+			req.Header.Set(name, values[0])
+		}
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := get("/blocks/1", nil)
+	if first.Code != http.StatusOK || first.Header().Get("X-Cache") != "MISS" || first.Header().Get("Cache-Control") != finalizedCacheControl {
+		t.Fatalf("Expected an immutable miss, got %d %v", first.Code, first.Header())
+	}
+	etag := first.Header().Get("ETag")
+
+	second := get("/blocks/1?api_key=other", nil)
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != first.Body.String() || second.Header().Get("ETag") != etag {
+		t.Errorf("Expected the cached response, got %v %s", second.Header(), second.Body)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 handler call, got %d", calls)
+	}
+
+	revalidated := get("/blocks/1", http.Header{"If-None-Match": {etag}})
+	if revalidated.Code != http.StatusNotModified || revalidated.Body.Len() != 0 {
+		t.Errorf("Expected 304 without a body, got %d %s", revalidated.Code, revalidated.Body)
+	}
+
+	unfinalized := get("/blocks/2", nil)
+	if got := unfinalized.Header().Get("Cache-Control"); got != "public, max-age=2" {
+		t.Errorf("Expected a short max-age, got %q", got)
+	}
+	key := cacheKey(httptest.NewRequest(http.MethodGet, "/blocks/2", nil))
+	if store.ttls[key] != 2*time.Second || store.tags[key] != "0x02" {
+		t.Errorf("Expected a 2s entry tagged 0x02, got %v %q", store.ttls[key], store.tags[key])
+	}
+
+	for i := 0; i < 2; i++ {
+		if notFound := get("/blocks/404", nil); notFound.Code != http.StatusNotFound || notFound.Header().Get("ETag") != "" {
+			t.Errorf("Expected an uncached 404, got %d %v", notFound.Code, notFound.Header())
+		}
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 handler calls, got %d", calls)
+	}
+}
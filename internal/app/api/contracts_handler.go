@@ -0,0 +1,49 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// Contract is the contract DTO
+type Contract struct {
+	Address        string `json:"address"`
+	Creator        string `json:"creator"`
+	CreationTxHash string `json:"creation_tx_hash"`
+	BlockNum       uint64 `json:"block_num"`
+	BlockHash      string `json:"block_hash"`
+	// BytecodeHash is empty when the provider no longer had the state of the
+	// creation block
+	BytecodeHash string `json:"bytecode_hash,omitempty"`
+}
+
+// GetContractByAddress returns the latest creation of a contract in the
+// canonical chain
+func (h *Server) GetContractByAddress(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address"})
+		return
+	}
+
+	row := h.dbClient.QueryRowContext(ctx, "SELECT c.address, c.creator, c.creation_tx_hash, c.block_number, c.block_hash, COALESCE(c.bytecode_hash, '') FROM contracts c JOIN blocks b ON b.hash = c.block_hash AND b.number = c.block_number WHERE c.address = $1 AND b.is_uncle = false ORDER BY c.block_number DESC LIMIT 1", common.HexToAddress(address).Hex())
+
+	var contract Contract
+	err := row.Scan(&contract.Address, &contract.Creator, &contract.CreationTxHash, &contract.BlockNum, &contract.BlockHash, &contract.BytecodeHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "contract not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, contract)
+}
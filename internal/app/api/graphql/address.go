@@ -0,0 +1,201 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+
+	"github.com/korprulu/interview-homework-b/internal/model"
+)
+
+type tokenResolver struct {
+	address     string
+	name        *string
+	symbol      *string
+	decimals    *uint8
+	totalSupply *string
+}
+
+// token returns the metadata of a token, only the address is set until the
+// token is enriched
+func (r *Resolver) token(ctx context.Context, address string) (*tokenResolver, error) {
+	if err := charge(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	token := &tokenResolver{address: address}
+	var name, symbol, totalSupply string
+	var decimals sql.NullInt16
+	row := r.dbClient.QueryRowContext(ctx, "SELECT name, symbol, decimals, total_supply FROM tokens WHERE address = $1", address)
+	err := row.Scan(&name, &symbol, &decimals, &totalSupply)
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token.name, token.symbol, token.totalSupply = optional(name), optional(symbol), optional(totalSupply)
+	if decimals.Valid {
+		d := uint8(decimals.Int16)
+		token.decimals = &d
+	}
+	return token, nil
+}
+
+func (t *tokenResolver) Address() string      { return t.address }
+func (t *tokenResolver) Name() *string        { return t.name }
+func (t *tokenResolver) Symbol() *string      { return t.symbol }
+func (t *tokenResolver) TotalSupply() *string { return t.totalSupply }
+
+func (t *tokenResolver) Decimals() *int32 {
+	if t.decimals == nil {
+		return nil
+	}
+	d := int32(*t.decimals)
+	return &d
+}
+
+type tokenBalanceResolver struct {
+	token   *tokenResolver
+	balance string
+}
+
+func (b *tokenBalanceResolver) Token() *tokenResolver { return b.token }
+func (b *tokenBalanceResolver) Balance() string       { return b.balance }
+
+func (b *tokenBalanceResolver) FormattedBalance() *string {
+	if b.token.decimals == nil {
+		return nil
+	}
+	formatted := model.FormatUnits(b.balance, *b.token.decimals)
+	return &formatted
+}
+
+type contractResolver struct {
+	contract model.Contract
+}
+
+func (c *contractResolver) Address() string        { return c.contract.Address }
+func (c *contractResolver) Creator() string        { return c.contract.Creator }
+func (c *contractResolver) CreationTxHash() string { return c.contract.CreationTxHash }
+func (c *contractResolver) BlockNumber() Long      { return Long(c.contract.BlockNumber) }
+func (c *contractResolver) BytecodeHash() *string  { return optional(c.contract.BytecodeHash) }
+
+type addressResolver struct {
+	r       *Resolver
+	address string
+}
+
+func (a *addressResolver) Address() string { return a.address }
+
+// Balance returns the ETH balance summed from the balance changes of the
+// canonical blocks
+func (a *addressResolver) Balance(ctx context.Context, args struct{ Block *Long }) (string, error) {
+	if err := charge(ctx, 1); err != nil {
+		return "", err
+	}
+
+	block := uint64(math.MaxInt64)
+	if args.Block != nil && uint64(*args.Block) < block {
+		block = uint64(*args.Block)
+	}
+
+	var balance string
+	row := a.r.dbClient.QueryRowContext(ctx, "SELECT COALESCE(SUM(c.delta), 0)::TEXT FROM address_balance_changes c JOIN blocks b ON b.hash = c.block_hash AND b.number = c.block_number WHERE c.address = $1 AND c.block_number <= $2 AND b.is_uncle = false", a.address, block)
+	err := row.Scan(&balance)
+	return balance, err
+}
+
+// TokenBalances returns the ERC-20 balances of the address
+func (a *addressResolver) TokenBalances(ctx context.Context) ([]*tokenBalanceResolver, error) {
+	if err := charge(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	rows, err := a.r.dbClient.QueryContext(ctx, "SELECT b.token, b.balance::TEXT, COALESCE(t.name, ''), COALESCE(t.symbol, ''), t.decimals, COALESCE(t.total_supply, '') FROM token_balances b LEFT JOIN tokens t ON t.address = b.token WHERE b.holder = $1 AND b.balance > 0 ORDER BY b.token", a.address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []*tokenBalanceResolver
+	for rows.Next() {
+		balance := &tokenBalanceResolver{token: &tokenResolver{}}
+		var name, symbol, totalSupply string
+		var decimals sql.NullInt16
+		if err := rows.Scan(&balance.token.address, &balance.balance, &name, &symbol, &decimals, &totalSupply); err != nil {
+			return nil, err
+		}
+		balance.token.name, balance.token.symbol, balance.token.totalSupply = optional(name), optional(symbol), optional(totalSupply)
+		if decimals.Valid {
+			d := uint8(decimals.Int16)
+			balance.token.decimals = &d
+		}
+		balances = append(balances, balance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := charge(ctx, len(balances)); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// Contract returns the creation of the contract at the address
+func (a *addressResolver) Contract(ctx context.Context) (*contractResolver, error) {
+	if err := charge(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	c := &contractResolver{}
+	row := a.r.dbClient.QueryRowContext(ctx, "SELECT c.address, c.creator, c.creation_tx_hash, c.block_number, COALESCE(c.bytecode_hash, '') FROM contracts c JOIN blocks b ON b.hash = c.block_hash AND b.number = c.block_number WHERE c.address = $1 AND b.is_uncle = false ORDER BY c.block_number DESC LIMIT 1", a.address)
+	err := row.Scan(&c.contract.Address, &c.contract.Creator, &c.contract.CreationTxHash, &c.contract.BlockNumber, &c.contract.BytecodeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Transactions returns the canonical transactions sent or received by the
+// address from the newest
+func (a *addressResolver) Transactions(ctx context.Context, args struct {
+	First     *int32
+	After     *string
+	FromBlock *Long
+	ToBlock   *Long
+}) (*transactionConnection, error) {
+	first, err := pageSize(args.First)
+	if err != nil {
+		return nil, err
+	}
+	if err := charge(ctx, 1+first); err != nil {
+		return nil, err
+	}
+
+	from, to := blockRange(args.FromBlock, args.ToBlock)
+	hasCursor := false
+	var cursorBlock, cursorIndex uint64
+	if args.After != nil {
+		cursorBlock, cursorIndex, err = decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		hasCursor = true
+	}
+
+	rows, err := a.r.dbClient.QueryContext(ctx, "SELECT "+transactionColumns+" FROM transactions t JOIN blocks b ON b.hash = t.block_hash AND b.number = t.block_number WHERE (t.from_address = $1 OR t.to_address = $1) AND b.is_uncle = false AND t.block_number BETWEEN $2 AND $3 AND ($4 = false OR (t.block_number, t.index) < ($5, $6)) ORDER BY t.block_number DESC, t.index DESC LIMIT $7",
+		a.address, from, to, hasCursor, cursorBlock, cursorIndex, first+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return newTransactionConnection(a.r, rows, first)
+}
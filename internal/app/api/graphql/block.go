@@ -0,0 +1,91 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/korprulu/interview-homework-b/internal/model"
+)
+
+// blockColumns are the columns scanned by scanBlock
+const blockColumns = "number, hash, parent_hash, timestamp, is_uncle, miner, gas_used, gas_limit, COALESCE(base_fee_per_gas::text, '')"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+type blockResolver struct {
+	r     *Resolver
+	block model.Block
+}
+
+func scanBlock(r *Resolver, row rowScanner) (*blockResolver, error) {
+	b := &blockResolver{r: r}
+	err := row.Scan(&b.block.Number, &b.block.Hash, &b.block.ParentHash, &b.block.Timestamp, &b.block.IsUncle, &b.block.Miner, &b.block.GasUsed, &b.block.GasLimit, &b.block.BaseFeePerGas)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *blockResolver) Number() Long       { return Long(b.block.Number) }
+func (b *blockResolver) Hash() string       { return b.block.Hash }
+func (b *blockResolver) ParentHash() string { return b.block.ParentHash }
+func (b *blockResolver) Timestamp() Long    { return Long(b.block.Timestamp) }
+func (b *blockResolver) IsUncle() bool      { return b.block.IsUncle }
+func (b *blockResolver) Miner() string      { return b.block.Miner }
+func (b *blockResolver) GasUsed() Long      { return Long(b.block.GasUsed) }
+func (b *blockResolver) GasLimit() Long     { return Long(b.block.GasLimit) }
+
+func (b *blockResolver) BaseFeePerGas() *string {
+	if b.block.BaseFeePerGas == "" {
+		return nil
+	}
+	return &b.block.BaseFeePerGas
+}
+
+// Transactions returns the transactions of the block in order
+func (b *blockResolver) Transactions(ctx context.Context, args struct {
+	First *int32
+	After *string
+}) (*transactionConnection, error) {
+	first, err := pageSize(args.First)
+	if err != nil {
+		return nil, err
+	}
+	if err := charge(ctx, 1+first); err != nil {
+		return nil, err
+	}
+
+	start := int64(-1)
+	if args.After != nil {
+		_, index, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		start = int64(index)
+	}
+
+	rows, err := b.r.dbClient.QueryContext(ctx, "SELECT "+transactionColumns+" FROM transactions t WHERE t.block_hash = $1 AND t.index > $2 ORDER BY t.index LIMIT $3", b.block.Hash, start, first+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return newTransactionConnection(b.r, rows, first)
+}
+
+type blockConnection struct {
+	edges    []blockEdge
+	pageInfo PageInfo
+}
+
+func (c *blockConnection) Edges() []blockEdge { return c.edges }
+func (c *blockConnection) PageInfo() PageInfo { return c.pageInfo }
+
+type blockEdge struct {
+	cursor string
+	node   *blockResolver
+}
+
+func (e blockEdge) Cursor() string       { return e.cursor }
+func (e blockEdge) Node() *blockResolver { return e.node }
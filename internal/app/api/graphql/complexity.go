@@ -0,0 +1,33 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// errComplexityExceeded is returned by resolvers once the query spent its
+// complexity budget
+var errComplexityExceeded = errors.New("query complexity limit exceeded")
+
+type budgetKey struct{}
+
+// withBudget attaches the complexity budget of a query to the context.
+// Resolvers run in parallel, so the budget is shared atomically.
+func withBudget(ctx context.Context, budget int64) context.Context {
+	return context.WithValue(ctx, budgetKey{}, &budget)
+}
+
+// charge spends cost units of the query budget. Every database query costs one
+// unit and every node of a list costs one more, connections are charged
+// their page size before they are queried.
+func charge(ctx context.Context, cost int) error {
+	budget, ok := ctx.Value(budgetKey{}).(*int64)
+	if !ok {
+		return nil
+	}
+	if atomic.AddInt64(budget, -int64(cost)) < 0 {
+		return errComplexityExceeded
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package graphql
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// maxPageSize is the largest page of a connection
+const maxPageSize = 100
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor returns an opaque cursor of a position made of a block number
+// and a transaction index
+func encodeCursor(blockNumber uint64, index uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", blockNumber, index)))
+}
+
+// decodeCursor returns the position of a cursor made by encodeCursor
+func decodeCursor(cursor string) (blockNumber uint64, index uint64, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, errInvalidCursor
+	}
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &blockNumber, &index); err != nil {
+		return 0, 0, errInvalidCursor
+	}
+	return blockNumber, index, nil
+}
+
+// pageSize validates the first argument of a connection
+func pageSize(first *int32) (int, error) {
+	if first == nil {
+		return 10, nil
+	}
+	if *first <= 0 || *first > maxPageSize {
+		return 0, fmt.Errorf("first must be between 1 and %d", maxPageSize)
+	}
+	return int(*first), nil
+}
@@ -0,0 +1,76 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewHandler(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewHandler(Config{MaxDepth: 8, MaxComplexity: 1000}); err != nil {
+		t.Errorf("Expected schema to parse, got %v", err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	t.Parallel()
+
+	blockNumber, index, err := decodeCursor(encodeCursor(19000000, 42))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if blockNumber != 19000000 || index != 42 {
+		t.Errorf("Expected 19000000:42, got %d:%d", blockNumber, index)
+	}
+
+	if _, _, err := decodeCursor("not a cursor"); !errors.Is(err, errInvalidCursor) {
+		t.Errorf("Expected %v, got %v", errInvalidCursor, err)
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	t.Parallel()
+
+	size, err := pageSize(nil)
+	if err != nil || size != 10 {
+		t.Errorf("Expected default page size 10, got %d (%v)", size, err)
+	}
+
+	tooLarge := int32(maxPageSize + 1)
+	if _, err := pageSize(&tooLarge); err == nil {
+		t.Errorf("Expected error for page size %d", tooLarge)
+	}
+}
+
+func TestCharge(t *testing.T) {
+	t.Parallel()
+
+	ctx := withBudget(context.Background(), 10)
+	if err := charge(ctx, 1+9); err != nil {
+		t.Errorf("Expected budget to cover 10 units, got %v", err)
+	}
+	if err := charge(ctx, 1); !errors.Is(err, errComplexityExceeded) {
+		t.Errorf("Expected %v, got %v", errComplexityExceeded, err)
+	}
+
+	if err := charge(context.Background(), 1000); err != nil {
+		t.Errorf("Expected no limit without budget, got %v", err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewHandler(Config{MaxDepth: 2, MaxComplexity: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := `{ blocks { edges { node { number } } } }`
+	response := handler.schema.Exec(context.Background(), query, "", nil)
+	if len(response.Errors) == 0 {
+		t.Errorf("Expected depth limit error")
+	}
+}
@@ -0,0 +1,74 @@
+// Package graphql serves the indexed chain data over GraphQL
+package graphql
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// Config is the configuration of the GraphQL handler
+type Config struct {
+	DBClient *pkg.DBClient
+	// MaxDepth is the deepest selection a query may nest
+	MaxDepth int
+	// MaxComplexity is the budget of a query, see charge
+	MaxComplexity int
+}
+
+// Handler executes GraphQL queries
+type Handler struct {
+	schema        *graphqlgo.Schema
+	maxComplexity int64
+}
+
+// request is the body of a GraphQL request
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// NewHandler parses the schema and creates a new handler
+func NewHandler(cfg Config) (*Handler, error) {
+	parsed, err := graphqlgo.ParseSchema(schema, &Resolver{dbClient: cfg.DBClient},
+		graphqlgo.MaxDepth(cfg.MaxDepth),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		schema:        parsed,
+		maxComplexity: int64(cfg.MaxComplexity),
+	}, nil
+}
+
+// ServeHTTP executes the query of a POST request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := withBudget(r.Context(), h.maxComplexity)
+	response := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,170 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+// Resolver is the root resolver of the GraphQL schema
+type Resolver struct {
+	dbClient *pkg.DBClient
+}
+
+// PageInfo is the page information of a connection
+type PageInfo struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+// HasNextPage reports whether there are more nodes after the page
+func (p PageInfo) HasNextPage() bool { return p.hasNextPage }
+
+// EndCursor is the cursor of the last node of the page
+func (p PageInfo) EndCursor() *string { return p.endCursor }
+
+// blockRange returns the bounds of the optional fromBlock and toBlock
+// arguments
+func blockRange(from, to *Long) (uint64, uint64) {
+	lower, upper := uint64(0), uint64(math.MaxInt64)
+	if from != nil {
+		lower = uint64(*from)
+	}
+	if to != nil && uint64(*to) < upper {
+		upper = uint64(*to)
+	}
+	return lower, upper
+}
+
+// normalizeAddress returns the checksummed address stored in the database
+func normalizeAddress(address string) (string, error) {
+	if !common.IsHexAddress(address) {
+		return "", errors.New("invalid address")
+	}
+	return common.HexToAddress(address).Hex(), nil
+}
+
+// Block returns a canonical block by number or any block by hash
+func (r *Resolver) Block(ctx context.Context, args struct {
+	Number *Long
+	Hash   *string
+}) (*blockResolver, error) {
+	if err := charge(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	var row *sql.Row
+	switch {
+	case args.Hash != nil:
+		row = r.dbClient.QueryRowContext(ctx, "SELECT "+blockColumns+" FROM blocks WHERE hash = $1 ORDER BY is_uncle LIMIT 1", strings.ToLower(*args.Hash))
+	case args.Number != nil:
+		row = r.dbClient.QueryRowContext(ctx, "SELECT "+blockColumns+" FROM blocks WHERE number = $1 AND is_uncle = false", uint64(*args.Number))
+	default:
+		return nil, errors.New("number or hash is required")
+	}
+
+	block, err := scanBlock(r, row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return block, err
+}
+
+// Blocks returns canonical blocks from the newest
+func (r *Resolver) Blocks(ctx context.Context, args struct {
+	First     *int32
+	After     *string
+	FromBlock *Long
+	ToBlock   *Long
+}) (*blockConnection, error) {
+	first, err := pageSize(args.First)
+	if err != nil {
+		return nil, err
+	}
+	if err := charge(ctx, 1+first); err != nil {
+		return nil, err
+	}
+
+	from, to := blockRange(args.FromBlock, args.ToBlock)
+	if args.After != nil {
+		number, _, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		if number == 0 {
+			return &blockConnection{}, nil
+		}
+		if number-1 < to {
+			to = number - 1
+		}
+	}
+
+	rows, err := r.dbClient.QueryContext(ctx, "SELECT "+blockColumns+" FROM blocks WHERE is_uncle = false AND number BETWEEN $1 AND $2 ORDER BY number DESC LIMIT $3", from, to, first+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	connection := &blockConnection{}
+	for rows.Next() {
+		block, err := scanBlock(r, rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(connection.edges) == first {
+			connection.pageInfo.hasNextPage = true
+			break
+		}
+		connection.edges = append(connection.edges, blockEdge{
+			cursor: encodeCursor(block.block.Number, 0),
+			node:   block,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(connection.edges) > 0 {
+		connection.pageInfo.endCursor = &connection.edges[len(connection.edges)-1].cursor
+	}
+
+	return connection, nil
+}
+
+// Transaction returns a transaction by hash
+func (r *Resolver) Transaction(ctx context.Context, args struct{ Hash string }) (*transactionResolver, error) {
+	if err := charge(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	// a transaction re-included by a reorg is stored once per block, the one
+	// in the canonical chain is returned
+	row := r.dbClient.QueryRowContext(ctx, "SELECT "+transactionColumns+" FROM transactions t LEFT JOIN blocks b ON b.hash = t.block_hash AND b.number = t.block_number WHERE t.hash = $1 ORDER BY COALESCE(b.is_uncle, false), t.block_number DESC LIMIT 1", strings.ToLower(args.Hash))
+	tx, err := scanTransaction(r, row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return tx, err
+}
+
+// Address returns an account
+func (r *Resolver) Address(args struct{ Address string }) (*addressResolver, error) {
+	address, err := normalizeAddress(args.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &addressResolver{r: r, address: address}, nil
+}
+
+// Token returns the metadata of an ERC-20 token
+func (r *Resolver) Token(ctx context.Context, args struct{ Address string }) (*tokenResolver, error) {
+	address, err := normalizeAddress(args.Address)
+	if err != nil {
+		return nil, err
+	}
+	return r.token(ctx, address)
+}
@@ -0,0 +1,165 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/korprulu/interview-homework-b/internal/model"
+)
+
+// transactionColumns are the columns scanned by scanTransaction, the table
+// is aliased t
+const transactionColumns = "t.hash, t.index, t.block_number, t.block_hash, t.from_address, t.to_address, t.contract_address, t.nonce, t.value, t.data, t.type, t.gas, COALESCE(t.gas_price::text, ''), t.logs"
+
+type transactionResolver struct {
+	r  *Resolver
+	tx model.Transaction
+}
+
+func scanTransaction(r *Resolver, row rowScanner) (*transactionResolver, error) {
+	t := &transactionResolver{r: r}
+	tx := &t.tx
+	err := row.Scan(&tx.Hash, &tx.Index, &tx.BlockNumber, &tx.BlockHash, &tx.From, &tx.To, &tx.ContractAddress, &tx.Nonce, &tx.Value, &tx.Data, &tx.Type, &tx.Gas, &tx.GasPrice, &tx.Logs)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// newTransactionConnection reads a page of transactions from rows queried
+// with one more row than the page size
+func newTransactionConnection(r *Resolver, rows *sql.Rows, first int) (*transactionConnection, error) {
+	connection := &transactionConnection{}
+	for rows.Next() {
+		tx, err := scanTransaction(r, rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(connection.edges) == first {
+			connection.pageInfo.hasNextPage = true
+			break
+		}
+		connection.edges = append(connection.edges, transactionEdge{
+			cursor: encodeCursor(tx.tx.BlockNumber, tx.tx.Index),
+			node:   tx,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(connection.edges) > 0 {
+		connection.pageInfo.endCursor = &connection.edges[len(connection.edges)-1].cursor
+	}
+	return connection, nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (t *transactionResolver) Hash() string             { return t.tx.Hash }
+func (t *transactionResolver) Index() int32             { return int32(t.tx.Index) }
+func (t *transactionResolver) BlockNumber() Long        { return Long(t.tx.BlockNumber) }
+func (t *transactionResolver) BlockHash() string        { return t.tx.BlockHash }
+func (t *transactionResolver) From() string             { return t.tx.From }
+func (t *transactionResolver) To() *string              { return optional(t.tx.To) }
+func (t *transactionResolver) ContractAddress() *string { return optional(t.tx.ContractAddress) }
+func (t *transactionResolver) Nonce() Long              { return Long(t.tx.Nonce) }
+func (t *transactionResolver) Value() string            { return t.tx.Value }
+func (t *transactionResolver) Data() string             { return t.tx.Data }
+func (t *transactionResolver) Type() int32              { return int32(t.tx.Type) }
+func (t *transactionResolver) Gas() Long                { return Long(t.tx.Gas) }
+func (t *transactionResolver) GasPrice() string         { return t.tx.GasPrice }
+
+// Block returns the block the transaction was included in
+func (t *transactionResolver) Block(ctx context.Context) (*blockResolver, error) {
+	if err := charge(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	row := t.r.dbClient.QueryRowContext(ctx, "SELECT "+blockColumns+" FROM blocks WHERE number = $1 AND hash = $2", t.tx.BlockNumber, t.tx.BlockHash)
+	block, err := scanBlock(t.r, row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return block, err
+}
+
+// Logs returns the logs of the transaction, they are stored with the
+// transaction so no query is made
+func (t *transactionResolver) Logs(ctx context.Context) ([]*logResolver, error) {
+	if err := charge(ctx, len(t.tx.Logs)); err != nil {
+		return nil, err
+	}
+
+	logs := make([]*logResolver, len(t.tx.Logs))
+	for i, log := range t.tx.Logs {
+		logs[i] = &logResolver{r: t.r, log: log}
+	}
+	return logs, nil
+}
+
+type transactionConnection struct {
+	edges    []transactionEdge
+	pageInfo PageInfo
+}
+
+func (c *transactionConnection) Edges() []transactionEdge { return c.edges }
+func (c *transactionConnection) PageInfo() PageInfo       { return c.pageInfo }
+
+type transactionEdge struct {
+	cursor string
+	node   *transactionResolver
+}
+
+func (e transactionEdge) Cursor() string             { return e.cursor }
+func (e transactionEdge) Node() *transactionResolver { return e.node }
+
+type logResolver struct {
+	r   *Resolver
+	log model.TransactionLog
+}
+
+func (l *logResolver) Index() int32     { return int32(l.log.Index) }
+func (l *logResolver) Address() string  { return l.log.Address }
+func (l *logResolver) Data() string     { return l.log.Data }
+func (l *logResolver) Topics() []string { return append([]string{}, l.log.Topics...) }
+
+// Decoded returns the decoded ERC-20 Transfer event of the log
+func (l *logResolver) Decoded() *transferEventResolver {
+	from, to, value, ok := model.DecodeTransferLog(l.log)
+	if !ok {
+		return nil
+	}
+	return &transferEventResolver{r: l.r, token: l.log.Address, from: from, to: to, value: value}
+}
+
+type transferEventResolver struct {
+	r     *Resolver
+	token string
+	from  string
+	to    string
+	value string
+}
+
+func (e *transferEventResolver) Name() string  { return "Transfer" }
+func (e *transferEventResolver) From() string  { return e.from }
+func (e *transferEventResolver) To() string    { return e.to }
+func (e *transferEventResolver) Value() string { return e.value }
+
+func (e *transferEventResolver) Token(ctx context.Context) (*tokenResolver, error) {
+	return e.r.token(ctx, e.token)
+}
+
+func (e *transferEventResolver) FormattedValue(ctx context.Context) (*string, error) {
+	token, err := e.r.token(ctx, e.token)
+	if err != nil || token.decimals == nil {
+		return nil, err
+	}
+	formatted := model.FormatUnits(e.value, *token.decimals)
+	return &formatted, nil
+}
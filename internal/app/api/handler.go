@@ -6,39 +6,95 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/gin-gonic/gin"
+	"github.com/korprulu/interview-homework-b/internal/app/api/graphql"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
 	"github.com/rs/zerolog"
 )
 
 // Server is the handler for the API
 type Server struct {
-	dbClient *pkg.DBClient
-	server   *http.Server
-	logger   *zerolog.Logger
+	dbClient    *pkg.DBClient
+	ethClient   *pkg.EthClient
+	redisClient *pkg.RedisClient
+	listener    *pkg.DBListener
+	hub         *streamHub
+	graphql     *graphql.Handler
+	openapi     *openAPIDoc
+	// auth is nil when authentication is disabled
+	auth *authenticator
+	// cache is nil when response caching is disabled
+	cache  *responseCache
+	server *http.Server
+	logger *zerolog.Logger
+
+	cancelFunc context.CancelFunc
 }
 
 // Config is the config for the handler
 type Config struct {
 	Logger   *zerolog.Logger
 	DBClient *pkg.DBClient
+	// EthClient answers the JSON-RPC methods not served from the index
+	EthClient *pkg.EthClient
+	// RedisClient keeps the rate limits and the usage of the API keys, and
+	// the cached responses
+	RedisClient *pkg.RedisClient
+	// DBListener feeds the live feed, it must listen to StreamChannels
+	DBListener *pkg.DBListener
+	// GraphQLMaxDepth is the deepest selection a GraphQL query may nest
+	GraphQLMaxDepth int
+	// GraphQLMaxComplexity is the complexity budget of a GraphQL query
+	GraphQLMaxComplexity int
+	// AuthEnabled requires an API key on every route but the OpenAPI document
+	AuthEnabled bool
+	// CacheEnabled caches the responses of finalized blocks and transactions
+	CacheEnabled bool
+	// CacheUnfinalizedTTLSecs is how long a response of an unfinalized block
+	// is cached, 0 doesn't cache it
+	CacheUnfinalizedTTLSecs int
 }
 
 // NewServer creates a new handler
-func NewServer(cfg Config) *Server {
-	return &Server{
-		dbClient: cfg.DBClient,
-		logger:   cfg.Logger,
+func NewServer(cfg Config) (*Server, error) {
+	graphqlHandler, err := graphql.NewHandler(graphql.Config{
+		DBClient:      cfg.DBClient,
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		dbClient:    cfg.DBClient,
+		ethClient:   cfg.EthClient,
+		redisClient: cfg.RedisClient,
+		listener:    cfg.DBListener,
+		hub:         newStreamHub(cfg.DBClient, cfg.DBListener, cfg.Logger),
+		graphql:     graphqlHandler,
+		logger:      cfg.Logger,
+	}
+	if cfg.AuthEnabled {
+		limiter := pkg.NewRedisRateLimiter(cfg.RedisClient, RateLimitPrefix)
+		server.auth = newAuthenticator(cfg.DBClient, limiter, cfg.Logger)
 	}
+	if cfg.CacheEnabled {
+		server.cache = &responseCache{
+			store:          pkg.NewResponseCache(cfg.RedisClient),
+			unfinalizedTTL: time.Duration(cfg.CacheUnfinalizedTTLSecs) * time.Second,
+			logger:         cfg.Logger,
+		}
+	}
+	return server, nil
 }
 
 // Run runs the API
 func (s *Server) Run(port string) {
-	router := gin.Default()
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	s.cancelFunc = cancelFunc
+	go s.hub.run(ctx)
 
-	router.GET("/blocks", s.GetBlocks)
-	router.GET("/blocks/:id", s.GetBlockByID)
-	router.GET("/transaction/:txHash", s.GetTransactionByHash)
+	router := s.newRouter()
 
 	s.server = &http.Server{
 		Addr:    ":" + port,
@@ -52,6 +108,12 @@ func (s *Server) Run(port string) {
 
 // Close closes the API
 func (s *Server) Close() {
+	if s.cancelFunc != nil {
+		s.cancelFunc()
+	}
+	// streams never finish on their own, end them before shutting down
+	s.hub.close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := s.server.Shutdown(ctx); err != nil {
@@ -61,5 +123,10 @@ func (s *Server) Close() {
 	select {
 	case <-ctx.Done():
 	}
+	s.listener.Close()
+	s.ethClient.Close()
+	if s.redisClient != nil {
+		s.redisClient.Close()
+	}
 	s.dbClient.Close()
 }
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveRequest(t *testing.T) {
+	t.Parallel()
+
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "test_http_request_duration_seconds",
+	}, []string{"method", "route", "status"})
+
+	router := gin.New()
+	router.Use(observeRequest(histogram))
+	router.GET("/blocks/:id", func(c *gin.Context) { c.Status(http.StatusTeapot) })
+
+	for _, path := range []string{"/blocks/1", "/blocks/2", "/unknown/1", "/unknown/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.CollectAndCount(histogram); got != 2 {
+		t.Errorf("Expected 2 series, got %d", got)
+	}
+	for _, labels := range [][]string{{"GET", "/blocks/:id", "418"}, {"GET", "unmatched", "404"}} {
+		if !histogram.DeleteLabelValues(labels...) {
+			t.Errorf("Expected a series labelled %v", labels)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPIDoc is the OpenAPI 3 document of the API, it is generated from the
+// route table so a route cannot be served without being described
+type openAPIDoc struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components *components                      `json:"components,omitempty"`
+}
+
+type components struct {
+	SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+// apiKeyScheme is the name of the API key security scheme
+const apiKeyScheme = "apiKey"
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// parameter is a path or query parameter of a route, the validation
+// middleware checks the request against its schema
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is the subset of the OpenAPI schema object the API needs
+type schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*schema          `json:"oneOf,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+
+	// pattern is Pattern compiled for the validation middleware
+	pattern *regexp.Regexp
+}
+
+const (
+	inPath  = "path"
+	inQuery = "query"
+)
+
+// errorSchema is the body of every error response
+var errorSchema = &schema{
+	Type:       "object",
+	Properties: map[string]*schema{"error": {Type: "string"}},
+	Required:   []string{"error"},
+}
+
+func float(v float64) *float64 { return &v }
+
+// withPattern returns a string schema matching pattern
+func withPattern(pattern string) *schema {
+	return &schema{Type: "string", Pattern: pattern, pattern: regexp.MustCompile(pattern)}
+}
+
+// uintSchema is the schema of an unsigned integer param
+func uintSchema() *schema {
+	return &schema{Type: "integer", Format: "int64", Minimum: float(0)}
+}
+
+// newOpenAPIDoc describes the routes, secured routes require an API key
+// unless they are public
+func newOpenAPIDoc(routes []route, secured bool) *openAPIDoc {
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: "Block Indexer API", Version: "1.0.0"},
+		Paths:   map[string]map[string]*operation{},
+	}
+	if secured {
+		doc.Components = &components{SecuritySchemes: map[string]securityScheme{
+			apiKeyScheme: {Type: "apiKey", In: "header", Name: apiKeyHeader},
+		}}
+	}
+
+	for _, r := range routes {
+		status := r.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		contentType := r.contentType
+		if contentType == "" {
+			contentType = gin.MIMEJSON
+		}
+
+		op := &operation{
+			Summary:    r.summary,
+			Parameters: r.params,
+			Responses: map[string]*response{
+				strconv.Itoa(status): {
+					Description: http.StatusText(status),
+					Content:     map[string]mediaType{contentType: {Schema: schemaFor(r.response)}},
+				},
+				"default": {
+					Description: "Error",
+					Content:     map[string]mediaType{gin.MIMEJSON: {Schema: errorSchema}},
+				},
+			},
+		}
+		if secured && !r.public {
+			op.Security = []map[string][]string{{apiKeyScheme: {}}}
+		}
+		if r.request != nil {
+			op.RequestBody = &requestBody{
+				Required: true,
+				Content:  map[string]mediaType{gin.MIMEJSON: {Schema: schemaFor(r.request)}},
+			}
+		}
+
+		path := openAPIPath(r.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*operation{}
+		}
+		doc.Paths[path][strings.ToLower(r.method)] = op
+	}
+	return doc
+}
+
+// openAPIPath turns the gin path params of a path into OpenAPI templates
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// schemaFor returns a hand written schema as is and describes any other
+// value by the JSON encoding of its type
+func schemaFor(v any) *schema {
+	if s, ok := v.(*schema); ok {
+		return s
+	}
+	return schemaOf(reflect.TypeOf(v))
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// schemaOf describes the JSON encoding of a type. A field is required unless
+// it is omitempty, and nil pointers and slices are nullable.
+func schemaOf(t reflect.Type) *schema {
+	if t == nil || t == rawMessageType {
+		return &schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := *schemaOf(t.Elem())
+		s.Nullable = true
+		return &s
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schema{Type: "string", Format: "byte"}
+		}
+		return &schema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case reflect.Struct:
+		s := &schema{Type: "object", Properties: map[string]*schema{}}
+		addFields(s, t)
+		return s
+	}
+	// interfaces hold any value
+	return &schema{}
+}
+
+// addFields adds the JSON fields of a struct to an object schema, the fields
+// of embedded structs are promoted like encoding/json does
+func addFields(s *schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addFields(s, field.Type)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaOf(field.Type)
+		omitempty := strings.Contains(options, "omitempty")
+		if field.Type.Kind() == reflect.Slice && field.Type != rawMessageType && !omitempty {
+			property.Nullable = true
+		}
+		s.Properties[name] = property
+		if !omitempty {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// validateRequest rejects a request whose path or query params do not match
+// the params of its route
+func validateRequest(params []parameter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, p := range params {
+			var value string
+			var ok bool
+			if p.In == inPath {
+				value, ok = c.Param(p.Name), true
+			} else {
+				value, ok = c.GetQuery(p.Name)
+			}
+			if !ok {
+				if p.Required {
+					c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("missing %s", p.Name)})
+					return
+				}
+				continue
+			}
+			if !p.Schema.valid(value) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s", p.Name)})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// valid reports whether a param value matches a param schema
+func (s *schema) valid(value string) bool {
+	switch s.Type {
+	case "integer":
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		return (s.Minimum == nil || float64(n) >= *s.Minimum) && (s.Maximum == nil || float64(n) <= *s.Maximum)
+	case "boolean":
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	}
+	return s.pattern == nil || s.pattern.MatchString(value)
+}
+
+// GetOpenAPI returns the OpenAPI document of the API
+func (h *Server) GetOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, h.openapi)
+}
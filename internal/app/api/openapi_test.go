@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	t.Parallel()
+
+	server := &Server{}
+	router := server.newRouter()
+	if _, err := json.Marshal(server.openapi); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range router.Routes() {
+		operations, ok := server.openapi.Paths[openAPIPath(r.Path)]
+		if !ok || operations[strings.ToLower(r.Method)] == nil {
+			t.Errorf("Expected %s %s in the OpenAPI document", r.Method, r.Path)
+			continue
+		}
+
+		// every path param must be described
+		for _, part := range strings.Split(r.Path, "/") {
+			if !strings.HasPrefix(part, ":") {
+				continue
+			}
+			found := false
+			for _, p := range operations[strings.ToLower(r.Method)].Parameters {
+				found = found || (p.In == inPath && p.Name == part[1:])
+			}
+			if !found {
+				t.Errorf("Expected path param %s of %s %s in the OpenAPI document", part, r.Method, r.Path)
+			}
+		}
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	t.Parallel()
+
+	s := schemaOf(reflect.TypeOf(TokenBalance{}))
+	if s.Properties["address"] == nil || s.Properties["balance"] == nil {
+		t.Errorf("Expected embedded Token fields to be promoted, got %v", s.Properties)
+	}
+	if decimals := s.Properties["decimals"]; decimals == nil || !decimals.Nullable || decimals.Type != "integer" {
+		t.Errorf("Expected nullable integer decimals, got %+v", decimals)
+	}
+	expected := []string{"address", "balance"}
+	if !reflect.DeepEqual(s.Required, expected) {
+		t.Errorf("Expected required %v, got %v", expected, s.Required)
+	}
+
+	block := schemaOf(reflect.TypeOf(Page[Block]{}))
+	if items := block.Properties["data"].Items; items == nil || items.Properties["block_num"] == nil {
+		t.Errorf("Expected block items in the page, got %+v", block.Properties["data"])
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	t.Parallel()
+
+	server := &Server{}
+	routes := server.routes()
+	router := gin.New()
+	for _, r := range routes {
+		router.Handle(r.method, r.path, validateRequest(r.params), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+	}
+
+	address := "0x0000000000000000000000000000000000000001"
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{path: "/blocks?limit=10&include_uncles=true", expected: http.StatusNoContent},
+		{path: "/blocks?limit=0", expected: http.StatusBadRequest},
+		{path: "/blocks?limit=101", expected: http.StatusBadRequest},
+		{path: "/blocks?from_block=-1", expected: http.StatusBadRequest},
+		{path: "/blocks?include_uncles=maybe", expected: http.StatusBadRequest},
+		{path: "/blocks/by-time", expected: http.StatusBadRequest},
+		{path: "/blocks/by-time?ts=1700000000&expand=transactions,logs", expected: http.StatusNoContent},
+		{path: "/blocks/latest", expected: http.StatusNoContent},
+		{path: "/blocks/pending", expected: http.StatusBadRequest},
+		{path: "/blocks/100?expand=receipts", expected: http.StatusBadRequest},
+		{path: "/tokens/" + address + "/holders?limit=5", expected: http.StatusNoContent},
+		{path: "/tokens/0x01", expected: http.StatusBadRequest},
+		{path: "/transaction/0x01", expected: http.StatusBadRequest},
+		{path: "/stream/blocks?events=block,reorg&address=" + address, expected: http.StatusNoContent},
+		{path: "/stream/blocks?events=uncle", expected: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if recorder.Code != test.expected {
+			t.Errorf("Expected %d for %s, got %d: %s", test.expected, test.path, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestOpenAPISecurity(t *testing.T) {
+	t.Parallel()
+
+	server := &Server{auth: &authenticator{}}
+	server.newRouter()
+
+	if server.openapi.Components == nil || server.openapi.Components.SecuritySchemes[apiKeyScheme].Name != apiKeyHeader {
+		t.Errorf("Expected the %s security scheme, got %+v", apiKeyScheme, server.openapi.Components)
+	}
+	if security := server.openapi.Paths["/blocks"]["get"].Security; len(security) != 1 {
+		t.Errorf("Expected /blocks to require an api key, got %v", security)
+	}
+	if security := server.openapi.Paths["/openapi.json"]["get"].Security; security != nil {
+		t.Errorf("Expected /openapi.json to be public, got %v", security)
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultPageLimit is the page size when limit is not given
+	defaultPageLimit = 10
+	// maxPageLimit is the largest page of any list endpoint
+	maxPageLimit = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Page is the response envelope of the list endpoints
+type Page[T any] struct {
+	Data       []T        `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// Pagination holds the cursors of the pages around a page, a cursor is empty
+// when there is no such page
+type Pagination struct {
+	Limit int `json:"limit"`
+	// NextCursor is passed as after to get the next page
+	NextCursor string `json:"next_cursor,omitempty"`
+	// PrevCursor is passed as before to get the previous page
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// keyColumn is a column of the key a list is sorted by
+type keyColumn struct {
+	name string
+	// numeric columns only accept integer cursor values
+	numeric bool
+}
+
+// pageOrder is the unique key a list is sorted by, every column is sorted in
+// the same direction so the key can be compared as a row
+type pageOrder struct {
+	columns []keyColumn
+	desc    bool
+}
+
+// pageParams are the validated limit, before and after query params
+type pageParams struct {
+	order  pageOrder
+	limit  int
+	after  []string
+	before []string
+}
+
+// parsePageParams validates the pagination query params of a list sorted by
+// order
+func parsePageParams(c *gin.Context, order pageOrder) (pageParams, error) {
+	params := pageParams{order: order, limit: defaultPageLimit}
+
+	if limit, ok := c.GetQuery("limit"); ok {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			return params, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		params.limit = n
+	}
+
+	after, hasAfter := c.GetQuery("after")
+	before, hasBefore := c.GetQuery("before")
+	if hasAfter && hasBefore {
+		return params, errors.New("before and after cannot be used together")
+	}
+
+	var err error
+	if hasAfter {
+		params.after, err = decodeCursor(after, order)
+	}
+	if hasBefore {
+		params.before, err = decodeCursor(before, order)
+	}
+	return params, err
+}
+
+// encodeCursor returns an opaque cursor of the key values of an item
+func encodeCursor(values []string) string {
+	raw, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor returns the key values of a cursor made by encodeCursor
+func decodeCursor(cursor string, order pageOrder) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil || len(values) != len(order.columns) {
+		return nil, errInvalidCursor
+	}
+	for i, column := range order.columns {
+		if _, ok := new(big.Int).SetString(values[i], 10); column.numeric && !ok {
+			return nil, errInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+// keyset returns the condition selecting the items past the cursor, or an
+// empty condition without a cursor, and the ORDER BY of the query. Cursor
+// values are bound from $argOffset+1. A before page is queried in reverse and
+// put back in order by newPage.
+func (p pageParams) keyset(argOffset int) (condition string, orderBy string, args []any) {
+	names := make([]string, len(p.order.columns))
+	for i, column := range p.order.columns {
+		names[i] = column.name
+	}
+
+	// the query walks away from the cursor, backwards for before pages
+	desc := p.order.desc
+	if p.before != nil {
+		desc = !desc
+	}
+	direction, operator := " ASC", ">"
+	if desc {
+		direction, operator = " DESC", "<"
+	}
+	orderBy = strings.Join(names, direction+", ") + direction
+
+	cursor := p.after
+	if p.before != nil {
+		cursor = p.before
+	}
+	if cursor == nil {
+		return "", orderBy, nil
+	}
+
+	params := make([]string, len(cursor))
+	args = make([]any, len(cursor))
+	for i, value := range cursor {
+		params[i] = fmt.Sprintf("$%d", argOffset+i+1)
+		args[i] = value
+	}
+	condition = "(" + strings.Join(names, ", ") + ") " + operator + " (" + strings.Join(params, ", ") + ")"
+	return condition, orderBy, args
+}
+
+// paginate appends the conditions, the cursor condition, the order and the
+// limit of the page to a SELECT statement. The conditions use the args as
+// $1 to $len(args).
+func (p pageParams) paginate(statement string, conditions []string, args []any) (string, []any) {
+	cursorCondition, orderBy, cursorArgs := p.keyset(len(args))
+	if cursorCondition != "" {
+		conditions = append(conditions, cursorCondition)
+		args = append(args, cursorArgs...)
+	}
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, p.limit+1)
+	statement += fmt.Sprintf(" ORDER BY %s LIMIT $%d", orderBy, len(args))
+	return statement, args
+}
+
+// newPage builds the page of the limit+1 rows queried with paginate, key
+// returns the key values of an item
+func newPage[T any](items []T, params pageParams, key func(T) []string) Page[T] {
+	more := len(items) > params.limit
+	if more {
+		items = items[:params.limit]
+	}
+	if params.before != nil {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := Page[T]{Data: items, Pagination: Pagination{Limit: params.limit}}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(items) == 0 {
+		return page
+	}
+
+	// a before page always has a next page, an after page a previous one
+	if more || params.before != nil {
+		page.Pagination.NextCursor = encodeCursor(key(items[len(items)-1]))
+	}
+	if (params.before != nil && more) || params.after != nil {
+		page.Pagination.PrevCursor = encodeCursor(key(items[0]))
+	}
+	return page
+}
+
+// queryUint returns an optional unsigned integer query param
+func queryUint(c *gin.Context, name string) (*uint64, error) {
+	value, ok := c.GetQuery(name)
+	if !ok {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &n, nil
+}
+
+// queryBool returns an optional boolean query param, false when omitted
+func queryBool(c *gin.Context, name string) (bool, error) {
+	value, ok := c.GetQuery(name)
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s", name)
+	}
+	return b, nil
+}
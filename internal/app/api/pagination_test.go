@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	return c
+}
+
+func TestParsePageParams(t *testing.T) {
+	t.Parallel()
+
+	cursor := encodeCursor([]string{"100", "0xabc"})
+	tests := []struct {
+		query string
+		err   bool
+	}{
+		{query: ""},
+		{query: "limit=100"},
+		{query: "limit=0", err: true},
+		{query: "limit=101", err: true},
+		{query: "limit=10%3BDROP%20TABLE%20blocks", err: true},
+		{query: "after=" + cursor},
+		{query: "before=" + cursor},
+		{query: "after=" + cursor + "&before=" + cursor, err: true},
+		{query: "after=" + encodeCursor([]string{"latest", "0xabc"}), err: true},
+		{query: "after=" + encodeCursor([]string{"100"}), err: true},
+		{query: "after=not-a-cursor", err: true},
+	}
+
+	for _, test := range tests {
+		_, err := parsePageParams(newTestContext(test.query), blocksOrder)
+		if (err != nil) != test.err {
+			t.Errorf("Expected error %v for %q, got %v", test.err, test.query, err)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	t.Parallel()
+
+	params := pageParams{order: blocksOrder, limit: 10}
+	statement, args := params.paginate("SELECT number FROM blocks", []string{"number >= $1"}, []any{uint64(5)})
+	expected := "SELECT number FROM blocks WHERE number >= $1 ORDER BY number DESC, hash DESC LIMIT $2"
+	if statement != expected || !reflect.DeepEqual(args, []any{uint64(5), 11}) {
+		t.Errorf("Expected %q %v, got %q %v", expected, []any{uint64(5), 11}, statement, args)
+	}
+
+	params.before = []string{"100", "0xabc"}
+	statement, args = params.paginate("SELECT number FROM blocks", nil, nil)
+	expected = "SELECT number FROM blocks WHERE (number, hash) > ($1, $2) ORDER BY number ASC, hash ASC LIMIT $3"
+	if statement != expected || !reflect.DeepEqual(args, []any{"100", "0xabc", 11}) {
+		t.Errorf("Expected %q, got %q %v", expected, statement, args)
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	t.Parallel()
+
+	key := func(n int) []string { return []string{strconv.Itoa(n)} }
+	order := pageOrder{columns: []keyColumn{{name: "n", numeric: true}}, desc: true}
+
+	// first page with one more row than the limit
+	page := newPage([]int{9, 8, 7}, pageParams{order: order, limit: 2}, key)
+	if !reflect.DeepEqual(page.Data, []int{9, 8}) {
+		t.Errorf("Expected [9 8], got %v", page.Data)
+	}
+	if page.Pagination.NextCursor != encodeCursor(key(8)) || page.Pagination.PrevCursor != "" {
+		t.Errorf("Expected only a next cursor at 8, got %+v", page.Pagination)
+	}
+
+	// last page after a cursor
+	page = newPage([]int{6}, pageParams{order: order, limit: 2, after: key(7)}, key)
+	if page.Pagination.NextCursor != "" || page.Pagination.PrevCursor != encodeCursor(key(6)) {
+		t.Errorf("Expected only a prev cursor at 6, got %+v", page.Pagination)
+	}
+
+	// before pages are queried in reverse
+	page = newPage([]int{7, 8, 9}, pageParams{order: order, limit: 2, before: key(6)}, key)
+	if !reflect.DeepEqual(page.Data, []int{8, 7}) {
+		t.Errorf("Expected [8 7], got %v", page.Data)
+	}
+	if page.Pagination.NextCursor != encodeCursor(key(7)) || page.Pagination.PrevCursor != encodeCursor(key(8)) {
+		t.Errorf("Expected cursors at 7 and 8, got %+v", page.Pagination)
+	}
+
+	page = newPage[int](nil, pageParams{order: order, limit: 2}, key)
+	if page.Data == nil || len(page.Data) != 0 {
+		t.Errorf("Expected empty data, got %v", page.Data)
+	}
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// route is an endpoint of the API, the OpenAPI document and the request
+// validation are both built from the route table
+type route struct {
+	method  string
+	path    string
+	handler gin.HandlerFunc
+	summary string
+	params  []parameter
+	// request is the JSON body, a *schema or a value of the DTO type
+	request any
+	// response is the success body, a *schema or a value of the DTO type
+	response any
+	// status is the success status, 200 when not set
+	status int
+	// contentType is the success content type, JSON when not set
+	contentType string
+	// public routes don't require an API key
+	public bool
+	// cached routes are served from the response cache when their handler
+	// sets a cache policy
+	cached bool
+}
+
+const (
+	addressPattern = `^(0x)?[0-9a-fA-F]{40}$`
+	hashPattern    = `^0x[0-9a-fA-F]{64}$`
+)
+
+func pathParam(name, description string, s *schema) parameter {
+	return parameter{Name: name, In: inPath, Required: true, Description: description, Schema: s}
+}
+
+func queryParam(name, description string, s *schema) parameter {
+	return parameter{Name: name, In: inQuery, Description: description, Schema: s}
+}
+
+// pageQueryParams are the params of the list endpoints
+func pageQueryParams() []parameter {
+	return []parameter{
+		queryParam("limit", "Page size", &schema{Type: "integer", Format: "int64", Minimum: float(1), Maximum: float(maxPageLimit)}),
+		queryParam("after", "next_cursor of the previous page", &schema{Type: "string"}),
+		queryParam("before", "prev_cursor of the next page", &schema{Type: "string"}),
+	}
+}
+
+var (
+	addressParam = pathParam("address", "Address", withPattern(addressPattern))
+	txHashParam  = pathParam("txHash", "Transaction hash", withPattern(hashPattern))
+	expandParam  = queryParam("expand", "Comma separated parts to embed: transactions, logs", withPattern(`^\s*(transactions|logs)\s*(,\s*(transactions|logs)\s*)*$`))
+)
+
+// streamQueryParams are the params of the live feed endpoints
+var streamQueryParams = []parameter{
+	queryParam("events", "Comma separated events to send: block, reorg, transaction", withPattern(`^\s*(block|reorg|transaction)\s*(,\s*(block|reorg|transaction)\s*)*$`)),
+	queryParam("address", "Send the transactions involving the address", withPattern(addressPattern)),
+	queryParam("topic", "Send the transactions emitting a log with the topic", withPattern(hashPattern)),
+}
+
+// graphqlRequestSchema is the body of a GraphQL request
+var graphqlRequestSchema = &schema{
+	Type: "object",
+	Properties: map[string]*schema{
+		"query":         {Type: "string"},
+		"operationName": {Type: "string"},
+		"variables":     {Type: "object", AdditionalProperties: &schema{}},
+	},
+	Required: []string{"query"},
+}
+
+// graphqlResponseSchema is the body of a GraphQL response
+var graphqlResponseSchema = &schema{
+	Type: "object",
+	Properties: map[string]*schema{
+		"data":   {Type: "object", Nullable: true, AdditionalProperties: &schema{}},
+		"errors": {Type: "array", Items: &schema{Type: "object", AdditionalProperties: &schema{}}},
+	},
+}
+
+// rpcResponseSchema is a JSON-RPC response, or a batch of them
+var rpcResponseSchema = &schema{
+	OneOf: []*schema{rpcSingleResponseSchema, {Type: "array", Items: rpcSingleResponseSchema}},
+}
+
+var rpcSingleResponseSchema = &schema{
+	Type: "object",
+	Properties: map[string]*schema{
+		"jsonrpc": {Type: "string"},
+		"id":      {},
+		"result":  {},
+		"error":   schemaFor(rpcError{}),
+	},
+	Required: []string{"jsonrpc", "id"},
+}
+
+// routes is the route table of the API
+func (s *Server) routes() []route {
+	return []route{
+		{
+			method: http.MethodGet, path: "/blocks", handler: s.GetBlocks,
+			summary: "List blocks from the newest",
+			params: append(pageQueryParams(),
+				queryParam("from_block", "Lowest block number", uintSchema()),
+				queryParam("to_block", "Highest block number", uintSchema()),
+				queryParam("from_time", "Earliest unix timestamp", uintSchema()),
+				queryParam("to_time", "Latest unix timestamp", uintSchema()),
+				queryParam("include_uncles", "List uncle blocks as well", &schema{Type: "boolean"}),
+			),
+			response: Page[Block]{},
+		},
+		{
+			method: http.MethodGet, path: "/blocks/by-time", handler: s.GetBlockByTime,
+			summary: "Get the canonical block at or before a timestamp",
+			params: []parameter{
+				{Name: "ts", In: inQuery, Required: true, Description: "Unix timestamp", Schema: uintSchema()},
+				expandParam,
+			},
+			response: BlockByID{},
+		},
+		{
+			method: http.MethodGet, path: "/blocks/:id", handler: s.GetBlockByID,
+			summary: "Get a block by number, hash or tag",
+			params: []parameter{
+				pathParam("id", "Block number, block hash, or latest, finalized or safe", withPattern(`^([0-9]+|0x[0-9a-fA-F]{64}|latest|finalized|safe)$`)),
+				expandParam,
+			},
+			response: BlockByID{},
+			cached:   true,
+		},
+		{
+			method: http.MethodGet, path: "/transaction/:txHash", handler: s.GetTransactionByHash,
+			summary:  "Get a transaction by hash",
+			params:   []parameter{txHashParam},
+			response: Transaction{},
+			cached:   true,
+		},
+		{
+			method: http.MethodGet, path: "/transaction/:txHash/internal", handler: s.GetInternalTransactions,
+			summary:  "List the internal transactions of a transaction",
+			params:   append([]parameter{txHashParam}, pageQueryParams()...),
+			response: Page[InternalTransaction]{},
+		},
+		{
+			method: http.MethodGet, path: "/contracts/:address", handler: s.GetContractByAddress,
+			summary:  "Get the creation of a contract",
+			params:   []parameter{addressParam},
+			response: Contract{},
+		},
+		{
+			method: http.MethodGet, path: "/addresses/:address/balance", handler: s.GetAddressBalance,
+			summary: "Get the ETH balance of an address",
+			params: []parameter{
+				addressParam,
+				queryParam("block", "Block number, the latest indexed block when not given", uintSchema()),
+			},
+			response: AddressBalance{},
+		},
+		{
+			method: http.MethodGet, path: "/addresses/:address/tokens", handler: s.GetAddressTokens,
+			summary:  "List the token balances of an address",
+			params:   append([]parameter{addressParam}, pageQueryParams()...),
+			response: Page[TokenBalance]{},
+		},
+		{
+			method: http.MethodGet, path: "/tokens/:address", handler: s.GetToken,
+			summary:  "Get the metadata of a token",
+			params:   []parameter{addressParam},
+			response: Token{},
+		},
+		{
+			method: http.MethodGet, path: "/tokens/:address/holders", handler: s.GetTokenHolders,
+			summary:  "List the holders of a token from the largest balance",
+			params:   append([]parameter{addressParam}, pageQueryParams()...),
+			response: Page[TokenHolder]{},
+		},
+		{
+			method: http.MethodGet, path: "/stream/blocks", handler: s.StreamBlocks,
+			summary:     "Stream the live feed as Server-Sent Events",
+			params:      streamQueryParams,
+			response:    StreamEvent{},
+			contentType: "text/event-stream",
+		},
+		{
+			method: http.MethodGet, path: "/stream/ws", handler: s.StreamWebSocket,
+			summary:  "Stream the live feed over a WebSocket",
+			params:   streamQueryParams,
+			response: StreamEvent{},
+			status:   http.StatusSwitchingProtocols,
+		},
+		{
+			method: http.MethodPost, path: "/graphql", handler: gin.WrapH(s.graphql),
+			summary:  "Execute a GraphQL query",
+			request:  graphqlRequestSchema,
+			response: graphqlResponseSchema,
+		},
+		{
+			method: http.MethodPost, path: "/rpc", handler: s.HandleRPC,
+			summary: "Execute Ethereum JSON-RPC requests",
+			request: &schema{OneOf: []*schema{
+				schemaFor(rpcRequest{}),
+				{Type: "array", Items: schemaFor(rpcRequest{})},
+			}},
+			response: rpcResponseSchema,
+		},
+		{
+			method: http.MethodGet, path: "/openapi.json", handler: s.GetOpenAPI,
+			summary:  "Get the OpenAPI document of the API",
+			public:   true,
+			response: &schema{Type: "object", AdditionalProperties: &schema{}},
+		},
+	}
+}
+
+// newRouter registers the route table, every route authenticates the
+// request when authentication is enabled and validates its params before its
+// handler runs, and cached routes are looked up in the response cache
+func (s *Server) newRouter() *gin.Engine {
+	routes := s.routes()
+	s.openapi = newOpenAPIDoc(routes, s.auth != nil)
+
+	router := gin.New()
+	router.Use(gin.LoggerWithFormatter(logFormatter), gin.Recovery())
+	router.Use(traceRequest, observeRequest(httpRequestDuration))
+	for _, r := range routes {
+		var handlers []gin.HandlerFunc
+		if s.auth != nil && !r.public {
+			handlers = append(handlers, s.auth.middleware)
+		}
+		handlers = append(handlers, validateRequest(r.params))
+		if s.cache != nil && r.cached {
+			handlers = append(handlers, s.cache.middleware)
+		}
+		handlers = append(handlers, r.handler)
+		router.Handle(r.method, r.path, handlers...)
+	}
+	return router
+}
+
+// logFormatter is the request log line of gin without the API key of the
+// requests authenticated by the api_key query param
+func logFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactAPIKey(param.Path),
+		param.ErrorMessage,
+	)
+}
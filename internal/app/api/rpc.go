@@ -0,0 +1,473 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/lib/pq"
+)
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcLimitExceeded  = -32005
+)
+
+const (
+	// maxRPCBatchSize is the largest batch request served
+	maxRPCBatchSize = 100
+	// maxLogsBlockRange is the widest block range of eth_getLogs
+	maxLogsBlockRange = 10000
+	// maxLogsResults is the largest number of logs returned by eth_getLogs
+	maxLogsResults = 10000
+)
+
+// proxiedMethods are the read methods not served from the index that are
+// proxied to the upstream provider, any other method is not available
+var proxiedMethods = map[string]bool{
+	"web3_clientVersion":                      true,
+	"net_version":                             true,
+	"eth_chainId":                             true,
+	"eth_syncing":                             true,
+	"eth_gasPrice":                            true,
+	"eth_maxPriorityFeePerGas":                true,
+	"eth_blobBaseFee":                         true,
+	"eth_feeHistory":                          true,
+	"eth_getBalance":                          true,
+	"eth_getCode":                             true,
+	"eth_getStorageAt":                        true,
+	"eth_getTransactionCount":                 true,
+	"eth_getProof":                            true,
+	"eth_call":                                true,
+	"eth_estimateGas":                         true,
+	"eth_getBlockTransactionCountByHash":      true,
+	"eth_getBlockTransactionCountByNumber":    true,
+	"eth_getTransactionByBlockHashAndIndex":   true,
+	"eth_getTransactionByBlockNumberAndIndex": true,
+	"eth_getUncleCountByBlockHash":            true,
+	"eth_getUncleCountByBlockNumber":          true,
+	"eth_getBlockReceipts":                    true,
+}
+
+// rpcRequest is a JSON-RPC 2.0 request
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+// rpcError is the error of a JSON-RPC response
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+func invalidParams(format string, args ...any) *rpcError {
+	return &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// rpcResponse is a JSON-RPC 2.0 response
+type rpcResponse struct {
+	ID     json.RawMessage
+	Result any
+	Error  *rpcError
+}
+
+// MarshalJSON omits the result of an error response and keeps a null result
+// of a successful one
+func (r rpcResponse) MarshalJSON() ([]byte, error) {
+	id := r.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			ID      json.RawMessage `json:"id"`
+			Error   *rpcError       `json:"error"`
+		}{"2.0", id, r.Error})
+	}
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result"`
+	}{"2.0", id, r.Result})
+}
+
+// rpcBlock is a block in the shape returned by eth_getBlockByNumber
+type rpcBlock struct {
+	Number                hexutil.Uint64   `json:"number"`
+	Hash                  string           `json:"hash"`
+	ParentHash            string           `json:"parentHash"`
+	Nonce                 string           `json:"nonce"`
+	MixHash               string           `json:"mixHash"`
+	Sha3Uncles            string           `json:"sha3Uncles"`
+	LogsBloom             string           `json:"logsBloom"`
+	TransactionsRoot      string           `json:"transactionsRoot"`
+	StateRoot             string           `json:"stateRoot"`
+	ReceiptsRoot          string           `json:"receiptsRoot"`
+	Miner                 string           `json:"miner"`
+	Difficulty            *hexutil.Big     `json:"difficulty"`
+	ExtraData             string           `json:"extraData"`
+	Size                  hexutil.Uint64   `json:"size"`
+	GasLimit              hexutil.Uint64   `json:"gasLimit"`
+	GasUsed               hexutil.Uint64   `json:"gasUsed"`
+	Timestamp             hexutil.Uint64   `json:"timestamp"`
+	BaseFeePerGas         *hexutil.Big     `json:"baseFeePerGas,omitempty"`
+	WithdrawalsRoot       string           `json:"withdrawalsRoot,omitempty"`
+	Withdrawals           *[]rpcWithdrawal `json:"withdrawals,omitempty"`
+	BlobGasUsed           *hexutil.Uint64  `json:"blobGasUsed,omitempty"`
+	ExcessBlobGas         *hexutil.Uint64  `json:"excessBlobGas,omitempty"`
+	ParentBeaconBlockRoot string           `json:"parentBeaconBlockRoot,omitempty"`
+	// Transactions holds hashes, or rpcTransaction when full transactions
+	// are requested
+	Transactions []any    `json:"transactions"`
+	Uncles       []string `json:"uncles"`
+}
+
+// rpcWithdrawal is a withdrawal in the shape returned by the node
+type rpcWithdrawal struct {
+	Index          hexutil.Uint64 `json:"index"`
+	ValidatorIndex hexutil.Uint64 `json:"validatorIndex"`
+	Address        string         `json:"address"`
+	Amount         hexutil.Uint64 `json:"amount"`
+}
+
+// rpcTransaction is a transaction in the shape returned by
+// eth_getTransactionByHash
+type rpcTransaction struct {
+	BlockHash            string                       `json:"blockHash"`
+	BlockNumber          hexutil.Uint64               `json:"blockNumber"`
+	From                 string                       `json:"from"`
+	Gas                  hexutil.Uint64               `json:"gas"`
+	GasPrice             *hexutil.Big                 `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big                 `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big                 `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerBlobGas     *hexutil.Big                 `json:"maxFeePerBlobGas,omitempty"`
+	Hash                 string                       `json:"hash"`
+	Input                string                       `json:"input"`
+	Nonce                hexutil.Uint64               `json:"nonce"`
+	To                   *string                      `json:"to"`
+	TransactionIndex     hexutil.Uint64               `json:"transactionIndex"`
+	Value                *hexutil.Big                 `json:"value"`
+	Type                 hexutil.Uint64               `json:"type"`
+	AccessList           *model.TransactionAccessList `json:"accessList,omitempty"`
+	ChainID              *hexutil.Big                 `json:"chainId,omitempty"`
+	BlobVersionedHashes  []string                     `json:"blobVersionedHashes,omitempty"`
+	V                    string                       `json:"v"`
+	R                    string                       `json:"r"`
+	S                    string                       `json:"s"`
+	YParity              string                       `json:"yParity,omitempty"`
+}
+
+// rpcReceipt is a receipt in the shape returned by eth_getTransactionReceipt
+type rpcReceipt struct {
+	TransactionHash   string          `json:"transactionHash"`
+	TransactionIndex  hexutil.Uint64  `json:"transactionIndex"`
+	BlockHash         string          `json:"blockHash"`
+	BlockNumber       hexutil.Uint64  `json:"blockNumber"`
+	From              string          `json:"from"`
+	To                *string         `json:"to"`
+	CumulativeGasUsed hexutil.Uint64  `json:"cumulativeGasUsed"`
+	GasUsed           hexutil.Uint64  `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big    `json:"effectiveGasPrice"`
+	ContractAddress   *string         `json:"contractAddress"`
+	Logs              []rpcLog        `json:"logs"`
+	LogsBloom         string          `json:"logsBloom"`
+	Type              hexutil.Uint64  `json:"type"`
+	Status            hexutil.Uint64  `json:"status"`
+	BlobGasUsed       *hexutil.Uint64 `json:"blobGasUsed,omitempty"`
+	BlobGasPrice      *hexutil.Big    `json:"blobGasPrice,omitempty"`
+}
+
+// rpcLog is a log in the shape returned by eth_getLogs
+type rpcLog struct {
+	Address          string         `json:"address"`
+	Topics           []string       `json:"topics"`
+	Data             string         `json:"data"`
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	TransactionHash  string         `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	BlockHash        string         `json:"blockHash"`
+	LogIndex         hexutil.Uint   `json:"logIndex"`
+	Removed          bool           `json:"removed"`
+}
+
+// decimalToHex converts a decimal string stored in the database to a hex
+// quantity, empty values are nil
+func decimalToHex(value string) *hexutil.Big {
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return nil
+	}
+	return (*hexutil.Big)(n)
+}
+
+// orZero returns zero instead of nil for the quantities a node always returns
+func orZero(value *hexutil.Big) *hexutil.Big {
+	if value == nil {
+		return (*hexutil.Big)(new(big.Int))
+	}
+	return value
+}
+
+// optionalAddress returns nil for an empty address, a node returns null
+func optionalAddress(address string) *string {
+	if address == "" {
+		return nil
+	}
+	return &address
+}
+
+func toRPCTransaction(tx *model.Transaction) *rpcTransaction {
+	result := &rpcTransaction{
+		BlockHash:            tx.BlockHash,
+		BlockNumber:          hexutil.Uint64(tx.BlockNumber),
+		From:                 tx.From,
+		Gas:                  hexutil.Uint64(tx.Gas),
+		GasPrice:             orZero(decimalToHex(tx.GasPrice)),
+		MaxFeePerGas:         decimalToHex(tx.MaxFeePerGas),
+		MaxPriorityFeePerGas: decimalToHex(tx.MaxPriorityFeePerGas),
+		MaxFeePerBlobGas:     decimalToHex(tx.MaxFeePerBlobGas),
+		Hash:                 tx.Hash,
+		Input:                tx.Data,
+		Nonce:                hexutil.Uint64(tx.Nonce),
+		To:                   optionalAddress(tx.To),
+		TransactionIndex:     hexutil.Uint64(tx.Index),
+		Value:                orZero(decimalToHex(tx.Value)),
+		Type:                 hexutil.Uint64(tx.Type),
+		ChainID:              decimalToHex(tx.ChainID),
+		BlobVersionedHashes:  tx.BlobVersionedHashes,
+		V:                    tx.V,
+		R:                    tx.R,
+		S:                    tx.S,
+	}
+	// typed transactions have an access list and sign with the y parity
+	if tx.Type > 0 {
+		accessList := tx.AccessList
+		if accessList == nil {
+			accessList = model.TransactionAccessList{}
+		}
+		result.AccessList = &accessList
+		result.YParity = tx.V
+	}
+	return result
+}
+
+func toRPCLogs(tx *model.Transaction, removed bool) []rpcLog {
+	logs := make([]rpcLog, len(tx.Logs))
+	for i, l := range tx.Logs {
+		topics := l.Topics
+		if topics == nil {
+			topics = []string{}
+		}
+		logs[i] = rpcLog{
+			Address:          l.Address,
+			Topics:           topics,
+			Data:             l.Data,
+			BlockNumber:      hexutil.Uint64(tx.BlockNumber),
+			TransactionHash:  tx.Hash,
+			TransactionIndex: hexutil.Uint64(tx.Index),
+			BlockHash:        tx.BlockHash,
+			LogIndex:         hexutil.Uint(l.Index),
+			Removed:          removed,
+		}
+	}
+	return logs
+}
+
+func toRPCReceipt(tx *model.Transaction) *rpcReceipt {
+	receipt := &rpcReceipt{
+		TransactionHash:   tx.Hash,
+		TransactionIndex:  hexutil.Uint64(tx.Index),
+		BlockHash:         tx.BlockHash,
+		BlockNumber:       hexutil.Uint64(tx.BlockNumber),
+		From:              tx.From,
+		To:                optionalAddress(tx.To),
+		CumulativeGasUsed: hexutil.Uint64(tx.CumulativeGasUsed),
+		GasUsed:           hexutil.Uint64(tx.GasUsed),
+		EffectiveGasPrice: orZero(decimalToHex(tx.GasPrice)),
+		ContractAddress:   optionalAddress(tx.ContractAddress),
+		Logs:              toRPCLogs(tx, false),
+		LogsBloom:         tx.LogsBloom,
+		Type:              hexutil.Uint64(tx.Type),
+		Status:            hexutil.Uint64(tx.Status),
+		BlobGasPrice:      decimalToHex(tx.BlobGasPrice),
+	}
+	if receipt.BlobGasPrice != nil {
+		blobGasUsed := hexutil.Uint64(tx.BlobGasUsed)
+		receipt.BlobGasUsed = &blobGasUsed
+	}
+	return receipt
+}
+
+// block tags accepted in place of a block number
+const (
+	tagLatest    = "latest"
+	tagPending   = "pending"
+	tagEarliest  = "earliest"
+	tagSafe      = "safe"
+	tagFinalized = "finalized"
+)
+
+// parseBlockTag parses a block parameter, either a tag or a hex number. An
+// omitted parameter is the latest block.
+func parseBlockTag(param json.RawMessage) (tag string, number uint64, err error) {
+	if len(param) == 0 || string(param) == "null" {
+		return tagLatest, 0, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(param, &value); err != nil {
+		return "", 0, invalidParams("invalid block number: %s", param)
+	}
+	switch value {
+	case tagLatest, tagPending, tagEarliest, tagSafe, tagFinalized:
+		return value, 0, nil
+	}
+
+	number, err = hexutil.DecodeUint64(value)
+	if err != nil {
+		return "", 0, invalidParams("invalid block number %q: %v", value, err)
+	}
+	return "", number, nil
+}
+
+// logFilter is the filter object of eth_getLogs
+type logFilter struct {
+	FromBlock json.RawMessage   `json:"fromBlock"`
+	ToBlock   json.RawMessage   `json:"toBlock"`
+	BlockHash *string           `json:"blockHash"`
+	Address   json.RawMessage   `json:"address"`
+	Topics    []json.RawMessage `json:"topics"`
+
+	addresses []string
+	// topics holds the accepted values of each position, nil accepts any
+	topics [][]string
+}
+
+// parseLogFilter parses and normalizes an eth_getLogs filter object
+func parseLogFilter(param json.RawMessage) (*logFilter, error) {
+	var filter logFilter
+	if err := json.Unmarshal(param, &filter); err != nil {
+		return nil, invalidParams("invalid filter: %v", err)
+	}
+	if filter.BlockHash != nil && (len(filter.FromBlock) > 0 || len(filter.ToBlock) > 0) {
+		return nil, invalidParams("blockHash cannot be used with fromBlock or toBlock")
+	}
+
+	addresses, err := stringOrList(filter.Address)
+	if err != nil {
+		return nil, invalidParams("invalid address: %v", err)
+	}
+	for _, address := range addresses {
+		if !common.IsHexAddress(address) {
+			return nil, invalidParams("invalid address %q", address)
+		}
+		filter.addresses = append(filter.addresses, common.HexToAddress(address).Hex())
+	}
+
+	filter.topics = make([][]string, len(filter.Topics))
+	for i, raw := range filter.Topics {
+		topics, err := stringOrList(raw)
+		if err != nil {
+			return nil, invalidParams("invalid topic: %v", err)
+		}
+		for _, topic := range topics {
+			if len(strings.TrimPrefix(topic, "0x")) != 2*common.HashLength {
+				return nil, invalidParams("invalid topic %q", topic)
+			}
+			filter.topics[i] = append(filter.topics[i], common.HexToHash(topic).Hex())
+		}
+	}
+
+	return &filter, nil
+}
+
+// stringOrList decodes a null, a string or a list of strings
+func stringOrList(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return []string{value}, nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, errors.New("expected a string or a list of strings")
+	}
+	return values, nil
+}
+
+// sqlConditions narrows the transactions read by eth_getLogs to the ones with
+// a log of an accepted address and a log of an accepted topic for each
+// position, matches still checks every log. args are the arguments already
+// bound by the query.
+func (f *logFilter) sqlConditions(args []any) (string, []any) {
+	var conditions strings.Builder
+	containsAny := func(values []string) {
+		args = append(args, pq.Array(values))
+		fmt.Fprintf(&conditions, " AND t.logs @> ANY($%d::jsonb[])", len(args))
+	}
+
+	if len(f.addresses) > 0 {
+		values := make([]string, len(f.addresses))
+		for i, address := range f.addresses {
+			values[i] = fmt.Sprintf(`[{"address":%q}]`, address)
+		}
+		containsAny(values)
+	}
+	for _, accepted := range f.topics {
+		if len(accepted) == 0 {
+			continue
+		}
+		values := make([]string, len(accepted))
+		for i, topic := range accepted {
+			values[i] = fmt.Sprintf(`[{"topics":[%q]}]`, topic)
+		}
+		containsAny(values)
+	}
+	return conditions.String(), args
+}
+
+// matches reports whether the log passes the address and topic filters
+func (f *logFilter) matches(log model.TransactionLog) bool {
+	if len(f.addresses) > 0 && !contains(f.addresses, log.Address) {
+		return false
+	}
+	if len(f.topics) > len(log.Topics) {
+		return false
+	}
+	for i, accepted := range f.topics {
+		if len(accepted) > 0 && !contains(accepted, log.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
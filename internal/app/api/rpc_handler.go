@@ -0,0 +1,455 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gin-gonic/gin"
+	"github.com/korprulu/interview-homework-b/internal/model"
+)
+
+// errBlockNotIndexed is returned when a block parameter refers to a block the
+// index does not have, the method answers null like a node does
+var errBlockNotIndexed = errors.New("block not indexed")
+
+// rpcTransactionColumns are the columns scanned by scanRPCTransaction,
+// receipts stored before the receipt columns existed read as zero
+const rpcTransactionColumns = "t.hash, t.index, t.block_hash, t.block_number, t.from_address, COALESCE(t.to_address, ''), t.nonce, t.data, t.value, t.logs, COALESCE(t.contract_address, ''), " +
+	"t.type, t.gas, " + feeColumns + ", t.blob_versioned_hashes, t.access_list, COALESCE(t.chain_id::text, ''), t.v, t.r, t.s, " +
+	"COALESCE(t.status, 0), COALESCE(t.gas_used, 0), COALESCE(t.cumulative_gas_used, 0), COALESCE(t.logs_bloom, ''), COALESCE(t.blob_gas_used, 0), COALESCE(t.blob_gas_price::text, '')"
+
+func scanRPCTransaction(row rowScanner) (*model.Transaction, error) {
+	var tx model.Transaction
+	err := row.Scan(
+		&tx.Hash, &tx.Index, &tx.BlockHash, &tx.BlockNumber, &tx.From, &tx.To, &tx.Nonce, &tx.Data, &tx.Value, &tx.Logs, &tx.ContractAddress,
+		&tx.Type, &tx.Gas, &tx.GasPrice, &tx.MaxFeePerGas, &tx.MaxPriorityFeePerGas, &tx.MaxFeePerBlobGas, &tx.BlobVersionedHashes, &tx.AccessList, &tx.ChainID, &tx.V, &tx.R, &tx.S,
+		&tx.Status, &tx.GasUsed, &tx.CumulativeGasUsed, &tx.LogsBloom, &tx.BlobGasUsed, &tx.BlobGasPrice,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// HandleRPC answers Ethereum JSON-RPC requests, single or batched. Block,
+// transaction, receipt and log methods are served from the index, the other
+// read methods are proxied to the upstream provider.
+func (h *Server) HandleRPC(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			c.JSON(http.StatusOK, rpcResponse{Error: &rpcError{Code: rpcParseError, Message: "parse error"}})
+			return
+		}
+		c.JSON(http.StatusOK, h.callRPC(ctx, req))
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		c.JSON(http.StatusOK, rpcResponse{Error: &rpcError{Code: rpcParseError, Message: "parse error"}})
+		return
+	}
+	if len(batch) == 0 {
+		c.JSON(http.StatusOK, rpcResponse{Error: &rpcError{Code: rpcInvalidRequest, Message: "empty batch"}})
+		return
+	}
+	if len(batch) > maxRPCBatchSize {
+		c.JSON(http.StatusOK, rpcResponse{Error: &rpcError{Code: rpcLimitExceeded, Message: "batch too large"}})
+		return
+	}
+
+	responses := make([]rpcResponse, len(batch))
+	for i, raw := range batch {
+		var req rpcRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			responses[i] = rpcResponse{Error: &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}}
+			continue
+		}
+		responses[i] = h.callRPC(ctx, req)
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// callRPC answers a single request
+func (h *Server) callRPC(ctx context.Context, req rpcRequest) rpcResponse {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcResponse{ID: req.ID, Error: &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}}
+	}
+
+	var result any
+	var err error
+	switch req.Method {
+	case "eth_blockNumber":
+		result, err = h.rpcBlockNumber(ctx)
+	case "eth_getBlockByNumber":
+		result, err = h.rpcGetBlockByNumber(ctx, req.Params)
+	case "eth_getBlockByHash":
+		result, err = h.rpcGetBlockByHash(ctx, req.Params)
+	case "eth_getTransactionByHash":
+		result, err = h.rpcGetTransactionByHash(ctx, req.Params)
+	case "eth_getTransactionReceipt":
+		result, err = h.rpcGetTransactionReceipt(ctx, req.Params)
+	case "eth_getLogs":
+		result, err = h.rpcGetLogs(ctx, req.Params)
+	default:
+		if !proxiedMethods[req.Method] {
+			return rpcResponse{ID: req.ID, Error: &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("the method %s does not exist/is not available", req.Method)}}
+		}
+		result, err = h.ethClient.RawCall(ctx, req.Method, req.Params...)
+	}
+	if err != nil {
+		return rpcResponse{ID: req.ID, Error: toRPCError(err)}
+	}
+	return rpcResponse{ID: req.ID, Result: result}
+}
+
+// toRPCError keeps the code and data of the errors answered by the upstream
+// provider
+func toRPCError(err error) *rpcError {
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+
+	result := &rpcError{Code: rpcInternalError, Message: err.Error()}
+	var upstreamErr rpc.Error
+	if errors.As(err, &upstreamErr) {
+		result.Code = upstreamErr.ErrorCode()
+	}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		result.Data = dataErr.ErrorData()
+	}
+	return result
+}
+
+// param returns the i-th param or nil when it is omitted
+func param(params []json.RawMessage, i int) json.RawMessage {
+	if i < len(params) {
+		return params[i]
+	}
+	return nil
+}
+
+// requiredHash decodes the i-th param as a 32-byte hash, lowercase like the
+// stored hashes
+func requiredHash(params []json.RawMessage, i int) (string, error) {
+	var hash string
+	if err := json.Unmarshal(param(params, i), &hash); err != nil {
+		return "", invalidParams("missing value for required argument %d", i)
+	}
+	decoded, err := hexutil.Decode(hash)
+	if err != nil || len(decoded) != common.HashLength {
+		return "", invalidParams("invalid hash %q", hash)
+	}
+	return common.BytesToHash(decoded).Hex(), nil
+}
+
+// fullTransactions decodes the optional boolean of the block methods
+func fullTransactions(params []json.RawMessage, i int) (bool, error) {
+	raw := param(params, i)
+	if len(raw) == 0 {
+		return false, nil
+	}
+	var full bool
+	if err := json.Unmarshal(raw, &full); err != nil {
+		return false, invalidParams("invalid full transactions flag: %s", raw)
+	}
+	return full, nil
+}
+
+// completeBlock is the condition on a block b whose transactions are all
+// stored, the block is stored before the tx processors store its transactions
+const completeBlock = "b.transaction_count = (SELECT COUNT(*) FROM transactions t WHERE t.block_hash = b.hash AND t.block_number = b.number)"
+
+// resolveBlockNumber returns the canonical block number of a block parameter,
+// the tags resolve to the newest complete block
+func (h *Server) resolveBlockNumber(ctx context.Context, raw json.RawMessage) (uint64, error) {
+	tag, number, err := parseBlockTag(raw)
+	if err != nil {
+		return 0, err
+	}
+
+	var statement string
+	switch tag {
+	case "":
+		return number, nil
+	case tagEarliest:
+		return 0, nil
+	case tagLatest, tagPending:
+		statement = "SELECT b.number FROM blocks b WHERE b.is_uncle = false AND " + completeBlock + " ORDER BY b.number DESC LIMIT 1"
+	case tagSafe, tagFinalized:
+		statement = "SELECT b.number FROM blocks b WHERE b.is_uncle = false AND b.status = 'finalized' AND " + completeBlock + " ORDER BY b.number DESC LIMIT 1"
+	}
+
+	var latest uint64
+	err = h.dbClient.QueryRowContext(ctx, statement).Scan(&latest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errBlockNotIndexed
+	}
+	if err != nil {
+		return 0, err
+	}
+	return latest, nil
+}
+
+func (h *Server) rpcBlockNumber(ctx context.Context) (any, error) {
+	number, err := h.resolveBlockNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return hexutil.Uint64(number), nil
+}
+
+func (h *Server) rpcGetBlockByNumber(ctx context.Context, params []json.RawMessage) (any, error) {
+	if len(params) == 0 {
+		return nil, invalidParams("missing value for required argument 0")
+	}
+	full, err := fullTransactions(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := h.resolveBlockNumber(ctx, params[0])
+	if errors.Is(err, errBlockNotIndexed) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	row := h.dbClient.QueryRowContext(ctx, "SELECT "+blockColumns+" FROM blocks WHERE number = $1 AND is_uncle = false", number)
+	return h.queryRPCBlock(ctx, row, full)
+}
+
+func (h *Server) rpcGetBlockByHash(ctx context.Context, params []json.RawMessage) (any, error) {
+	hash, err := requiredHash(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	full, err := fullTransactions(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	row := h.dbClient.QueryRowContext(ctx, "SELECT "+blockColumns+" FROM blocks WHERE hash = $1 ORDER BY is_uncle LIMIT 1", hash)
+	return h.queryRPCBlock(ctx, row, full)
+}
+
+// queryRPCBlock scans a block row and queries its transactions, withdrawals
+// and uncles. A missing block is a nil result.
+func (h *Server) queryRPCBlock(ctx context.Context, row *sql.Row, full bool) (any, error) {
+	var block Block
+	if err := scanBlock(row, &block); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	result := &rpcBlock{
+		Number:                hexutil.Uint64(block.BlockNum),
+		Hash:                  block.BlockHash,
+		ParentHash:            block.ParentHash,
+		Nonce:                 block.Nonce,
+		MixHash:               block.MixHash,
+		Sha3Uncles:            block.Sha3Uncles,
+		LogsBloom:             block.LogsBloom,
+		TransactionsRoot:      block.TransactionsRoot,
+		StateRoot:             block.StateRoot,
+		ReceiptsRoot:          block.ReceiptsRoot,
+		Miner:                 block.Miner,
+		Difficulty:            orZero(decimalToHex(block.Difficulty)),
+		ExtraData:             block.ExtraData,
+		Size:                  hexutil.Uint64(block.Size),
+		GasLimit:              hexutil.Uint64(block.GasLimit),
+		GasUsed:               hexutil.Uint64(block.GasUsed),
+		Timestamp:             hexutil.Uint64(block.BlockTime),
+		BaseFeePerGas:         decimalToHex(block.BaseFeePerGas),
+		WithdrawalsRoot:       block.WithdrawalsRoot,
+		BlobGasUsed:           (*hexutil.Uint64)(block.BlobGasUsed),
+		ExcessBlobGas:         (*hexutil.Uint64)(block.ExcessBlobGas),
+		ParentBeaconBlockRoot: block.ParentBeaconBlockRoot,
+		Transactions:          []any{},
+		Uncles:                []string{},
+	}
+
+	rows, err := h.dbClient.QueryContext(ctx, "SELECT "+rpcTransactionColumns+" FROM transactions t WHERE t.block_hash = $1 ORDER BY t.index", block.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		tx, err := scanRPCTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		if full {
+			result.Transactions = append(result.Transactions, toRPCTransaction(tx))
+		} else {
+			result.Transactions = append(result.Transactions, tx.Hash)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// blocks after Shanghai always have a withdrawals list
+	if block.WithdrawalsRoot != "" {
+		withdrawals := []rpcWithdrawal{}
+		withdrawalRows, err := h.dbClient.QueryContext(ctx, "SELECT index, validator_index, address, amount FROM withdrawals WHERE block_hash = $1 ORDER BY index", block.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		defer withdrawalRows.Close()
+		for withdrawalRows.Next() {
+			var w rpcWithdrawal
+			if err := withdrawalRows.Scan(&w.Index, &w.ValidatorIndex, &w.Address, &w.Amount); err != nil {
+				return nil, err
+			}
+			withdrawals = append(withdrawals, w)
+		}
+		if err := withdrawalRows.Err(); err != nil {
+			return nil, err
+		}
+		result.Withdrawals = &withdrawals
+	}
+
+	uncleRows, err := h.dbClient.QueryContext(ctx, "SELECT hash FROM uncles WHERE block_hash = $1 ORDER BY index", block.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	defer uncleRows.Close()
+	for uncleRows.Next() {
+		var hash string
+		if err := uncleRows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		result.Uncles = append(result.Uncles, hash)
+	}
+	if err := uncleRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// queryCanonicalTransaction returns a transaction of a canonical block, nil
+// when it is not indexed or its block was orphaned
+func (h *Server) queryCanonicalTransaction(ctx context.Context, hash string) (*model.Transaction, error) {
+	row := h.dbClient.QueryRowContext(ctx, "SELECT "+rpcTransactionColumns+" FROM transactions t JOIN blocks b ON b.hash = t.block_hash AND b.number = t.block_number WHERE t.hash = $1 AND b.is_uncle = false ORDER BY t.block_number DESC LIMIT 1", hash)
+	tx, err := scanRPCTransaction(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return tx, err
+}
+
+func (h *Server) rpcGetTransactionByHash(ctx context.Context, params []json.RawMessage) (any, error) {
+	hash, err := requiredHash(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := h.queryCanonicalTransaction(ctx, hash)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	return toRPCTransaction(tx), nil
+}
+
+func (h *Server) rpcGetTransactionReceipt(ctx context.Context, params []json.RawMessage) (any, error) {
+	hash, err := requiredHash(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := h.queryCanonicalTransaction(ctx, hash)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	return toRPCReceipt(tx), nil
+}
+
+func (h *Server) rpcGetLogs(ctx context.Context, params []json.RawMessage) (any, error) {
+	if len(params) == 0 {
+		return nil, invalidParams("missing value for required argument 0")
+	}
+	filter, err := parseLogFilter(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	const logColumns = "t.hash, t.index, t.block_hash, t.block_number, t.logs"
+	var rows *sql.Rows
+	if filter.BlockHash != nil {
+		conditions, args := filter.sqlConditions([]any{*filter.BlockHash})
+		rows, err = h.dbClient.QueryContext(ctx, "SELECT "+logColumns+" FROM transactions t WHERE t.block_hash = $1 AND jsonb_array_length(t.logs) > 0"+conditions+" ORDER BY t.index", args...)
+	} else {
+		var from, to uint64
+		from, err = h.resolveBlockNumber(ctx, filter.FromBlock)
+		if err == nil {
+			to, err = h.resolveBlockNumber(ctx, filter.ToBlock)
+		}
+		if errors.Is(err, errBlockNotIndexed) {
+			return []rpcLog{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if from > to {
+			return nil, invalidParams("fromBlock is after toBlock")
+		}
+		if to-from >= maxLogsBlockRange {
+			return nil, &rpcError{Code: rpcLimitExceeded, Message: "block range is limited to 10000 blocks"}
+		}
+
+		conditions, args := filter.sqlConditions([]any{from, to})
+		rows, err = h.dbClient.QueryContext(ctx, "SELECT "+logColumns+" FROM transactions t JOIN blocks b ON b.hash = t.block_hash AND b.number = t.block_number WHERE b.is_uncle = false AND t.block_number BETWEEN $1 AND $2 AND jsonb_array_length(t.logs) > 0"+conditions+" ORDER BY t.block_number, t.index", args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []rpcLog{}
+	for rows.Next() {
+		var tx model.Transaction
+		if err := rows.Scan(&tx.Hash, &tx.Index, &tx.BlockHash, &tx.BlockNumber, &tx.Logs); err != nil {
+			return nil, err
+		}
+		for i, log := range toRPCLogs(&tx, false) {
+			if !filter.matches(tx.Logs[i]) {
+				continue
+			}
+			if len(logs) == maxLogsResults {
+				return nil, &rpcError{Code: rpcLimitExceeded, Message: "query returned more than 10000 results"}
+			}
+			logs = append(logs, log)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
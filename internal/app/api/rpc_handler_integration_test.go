@@ -0,0 +1,57 @@
+//go:build integration
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/korprulu/interview-homework-b/internal/config"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+func TestResolveBlockNumberComplete(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		User:     cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbClient.Close()
+
+	// numbers above any other block of the database
+	const number = uint64(1) << 40
+	ctx := context.Background()
+	complete := &model.Block{Number: number, Hash: "0xcomplete", ParentHash: "0xparent", Timestamp: uint64(time.Now().Unix()), Status: "unfinalized"}
+	// the transaction of the newest block is not stored yet
+	incomplete := &model.Block{Number: number + 1, Hash: "0xincomplete", ParentHash: complete.Hash, Timestamp: uint64(time.Now().Unix()), Status: "unfinalized",
+		Transactions: model.Transactions{{Hash: "0xtx", BlockNumber: number + 1, BlockHash: "0xincomplete"}},
+	}
+	for _, block := range []*model.Block{complete, incomplete} {
+		if err := block.Save(ctx, dbClient); err != nil {
+			t.Fatal(err)
+		}
+		defer dbClient.ExecContext(ctx, "DELETE FROM blocks WHERE number = $1 AND hash = $2", block.Number, block.Hash)
+	}
+
+	server := &Server{dbClient: dbClient}
+	latest, err := server.resolveBlockNumber(ctx, json.RawMessage(`"latest"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != number {
+		t.Errorf("Expected the newest complete block %d, got %d", number, latest)
+	}
+}
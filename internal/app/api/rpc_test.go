@@ -0,0 +1,295 @@
+package api
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+func TestParseBlockTag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		param  string
+		tag    string
+		number uint64
+		err    bool
+	}{
+		{param: "", tag: tagLatest},
+		{param: `"latest"`, tag: tagLatest},
+		{param: `"finalized"`, tag: tagFinalized},
+		{param: `"0x10"`, number: 16},
+		{param: `"16"`, err: true},
+		{param: `16`, err: true},
+	}
+
+	for _, test := range tests {
+		tag, number, err := parseBlockTag(json.RawMessage(test.param))
+		if (err != nil) != test.err {
+			t.Errorf("Expected error %v for %s, got %v", test.err, test.param, err)
+			continue
+		}
+		if tag != test.tag || number != test.number {
+			t.Errorf("Expected %q %d for %s, got %q %d", test.tag, test.number, test.param, tag, number)
+		}
+	}
+}
+
+func TestRequiredHash(t *testing.T) {
+	t.Parallel()
+
+	hash := common.HexToHash("0xabcdef").Hex()
+	tests := []struct {
+		param    string
+		expected string
+		failed   bool
+	}{
+		{param: `"` + hash + `"`, expected: hash},
+		{param: `"0x` + strings.ToUpper(hash[2:]) + `"`, expected: hash},
+		{param: `"0xabcdef"`, failed: true},
+		{param: `1`, failed: true},
+	}
+
+	for _, test := range tests {
+		got, err := requiredHash([]json.RawMessage{json.RawMessage(test.param)}, 0)
+		if test.failed {
+			if err == nil {
+				t.Errorf("Expected an error for %s, got %s", test.param, got)
+			}
+			continue
+		}
+		if err != nil || got != test.expected {
+			t.Errorf("Expected %s for %s, got %s %v", test.expected, test.param, got, err)
+		}
+	}
+}
+
+func TestLogFilterMatches(t *testing.T) {
+	t.Parallel()
+
+	token := common.HexToAddress("0x01").Hex()
+	transfer := model.TransferEventTopic.Hex()
+	receiver := common.HexToHash("0x02").Hex()
+	log := model.TransactionLog{
+		Address: token,
+		Topics:  []string{transfer, common.HexToHash("0x03").Hex(), receiver},
+	}
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected bool
+	}{
+		{name: "empty filter", filter: `{}`, expected: true},
+		{name: "lowercase address", filter: `{"address": "` + strings.ToLower(token) + `"}`, expected: true},
+		{name: "address list", filter: `{"address": ["` + common.HexToAddress("0x04").Hex() + `", "` + token + `"]}`, expected: true},
+		{name: "other address", filter: `{"address": "` + common.HexToAddress("0x04").Hex() + `"}`, expected: false},
+		{name: "wildcard position", filter: `{"topics": ["` + transfer + `", null, "` + receiver + `"]}`, expected: true},
+		{name: "topic alternatives", filter: `{"topics": [["` + receiver + `", "` + transfer + `"]]}`, expected: true},
+		{name: "other topic", filter: `{"topics": [null, "` + receiver + `"]}`, expected: false},
+		{name: "more topics than the log", filter: `{"topics": [null, null, null, null]}`, expected: false},
+	}
+
+	for _, test := range tests {
+		filter, err := parseLogFilter(json.RawMessage(test.filter))
+		if err != nil {
+			t.Errorf("%s: Expected no error, got %v", test.name, err)
+			continue
+		}
+		if got := filter.matches(log); got != test.expected {
+			t.Errorf("%s: Expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+
+	if _, err := parseLogFilter(json.RawMessage(`{"blockHash": "0x01", "fromBlock": "0x1"}`)); err == nil {
+		t.Errorf("Expected error for blockHash with fromBlock")
+	}
+}
+
+func TestLogFilterSQLConditions(t *testing.T) {
+	t.Parallel()
+
+	token := common.HexToAddress("0x01").Hex()
+	transfer := model.TransferEventTopic.Hex()
+	filter, err := parseLogFilter(json.RawMessage(`{"address": "` + strings.ToLower(token) + `", "topics": [null, "` + transfer + `"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conditions, args := filter.sqlConditions([]any{uint64(1), uint64(2)})
+	expected := " AND t.logs @> ANY($3::jsonb[]) AND t.logs @> ANY($4::jsonb[])"
+	if conditions != expected {
+		t.Errorf("Expected %q, got %q", expected, conditions)
+	}
+	if len(args) != 4 {
+		t.Fatalf("Expected 4 args, got %d", len(args))
+	}
+	for i, value := range []string{
+		`{"[{\"address\":\"` + token + `\"}]"}`,
+		`{"[{\"topics\":[\"` + transfer + `\"]}]"}`,
+	} {
+		got, err := args[i+2].(driver.Valuer).Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != value {
+			t.Errorf("Expected %s, got %v", value, got)
+		}
+	}
+
+	if conditions, args := (&logFilter{}).sqlConditions(nil); conditions != "" || len(args) != 0 {
+		t.Errorf("Expected no condition, got %q %v", conditions, args)
+	}
+}
+
+func TestRPCResponseJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		response rpcResponse
+		expected string
+	}{
+		{response: rpcResponse{ID: json.RawMessage("1")}, expected: `{"jsonrpc":"2.0","id":1,"result":null}`},
+		{response: rpcResponse{ID: json.RawMessage(`"a"`), Result: "0x1"}, expected: `{"jsonrpc":"2.0","id":"a","result":"0x1"}`},
+		{response: rpcResponse{Error: &rpcError{Code: rpcParseError, Message: "parse error"}}, expected: `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`},
+	}
+
+	for _, test := range tests {
+		got, err := json.Marshal(test.response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, got)
+		}
+	}
+}
+
+func TestToRPCReceipt(t *testing.T) {
+	t.Parallel()
+
+	tx := &model.Transaction{
+		Hash:              common.HexToHash("0x01").Hex(),
+		Index:             2,
+		BlockHash:         common.HexToHash("0x02").Hex(),
+		BlockNumber:       100,
+		From:              common.HexToAddress("0x03").Hex(),
+		ContractAddress:   common.HexToAddress("0x04").Hex(),
+		Type:              2,
+		GasPrice:          "1000000000",
+		Status:            1,
+		GasUsed:           21000,
+		CumulativeGasUsed: 42000,
+		Logs:              model.TransactionLogs{{Index: 5, Address: common.HexToAddress("0x05").Hex(), Data: "0x"}},
+	}
+
+	got, err := json.Marshal(toRPCReceipt(tx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var receipt map[string]any
+	if err := json.Unmarshal(got, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"to":                nil,
+		"contractAddress":   tx.ContractAddress,
+		"blockNumber":       "0x64",
+		"transactionIndex":  "0x2",
+		"gasUsed":           "0x5208",
+		"cumulativeGasUsed": "0xa410",
+		"effectiveGasPrice": "0x3b9aca00",
+		"status":            "0x1",
+		"type":              "0x2",
+	}
+	for key, value := range expected {
+		if receipt[key] != value {
+			t.Errorf("Expected %s %v, got %v", key, value, receipt[key])
+		}
+	}
+
+	logs := receipt["logs"].([]any)
+	log := logs[0].(map[string]any)
+	if log["logIndex"] != "0x5" || log["transactionHash"] != tx.Hash || log["removed"] != false {
+		t.Errorf("Expected log index 0x5 of %s, got %v", tx.Hash, log)
+	}
+	if topics, ok := log["topics"].([]any); !ok || len(topics) != 0 {
+		t.Errorf("Expected empty topics, got %v", log["topics"])
+	}
+}
+
+func TestHandleRPCProxy(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case "eth_chainId":
+			json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": "0x1"})
+		case "eth_call":
+			json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "error": map[string]any{"code": 3, "message": "execution reverted", "data": "0x"}})
+		default:
+			t.Errorf("Expected %s not to be proxied", req.Method)
+		}
+	}))
+	defer upstream.Close()
+
+	ethClient, err := pkg.NewEthClient(pkg.EthClientConfig{URL: upstream.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ethClient.Close()
+
+	server := &Server{ethClient: ethClient}
+	tests := []struct {
+		request  rpcRequest
+		expected string
+	}{
+		{request: rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "eth_chainId"}, expected: `{"jsonrpc":"2.0","id":1,"result":"0x1"}`},
+		{request: rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("2"), Method: "eth_unknown"}, expected: `{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"the method eth_unknown does not exist/is not available"}}`},
+		{request: rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("4"), Method: "eth_sendRawTransaction"}, expected: `{"jsonrpc":"2.0","id":4,"error":{"code":-32601,"message":"the method eth_sendRawTransaction does not exist/is not available"}}`},
+		{request: rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("5"), Method: "eth_call"}, expected: `{"jsonrpc":"2.0","id":5,"error":{"code":3,"message":"execution reverted","data":"0x"}}`},
+		{request: rpcRequest{ID: json.RawMessage("3"), Method: "eth_chainId"}, expected: `{"jsonrpc":"2.0","id":3,"error":{"code":-32600,"message":"invalid request"}}`},
+	}
+
+	for _, test := range tests {
+		got, err := json.Marshal(server.callRPC(context.Background(), test.request))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, got)
+		}
+	}
+}
+
+func TestHandleRPCBatchLimit(t *testing.T) {
+	t.Parallel()
+
+	batch := "[" + strings.Repeat(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},`, maxRPCBatchSize) + `{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}]`
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(batch))
+	(&Server{}).HandleRPC(c)
+
+	body, _ := io.ReadAll(recorder.Body)
+	if !strings.Contains(string(body), `"code":-32005`) {
+		t.Errorf("Expected limit exceeded error, got %s", body)
+	}
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/rs/zerolog"
+)
+
+// notification channels filled by the triggers in scripts/initdb/triggers.sql
+const (
+	newBlocksChannel       = "new_blocks"
+	newTransactionsChannel = "new_transactions"
+	reorgedBlocksChannel   = "reorged_blocks"
+)
+
+// StreamChannels are the Postgres channels the live feed listens to
+var StreamChannels = []string{newBlocksChannel, newTransactionsChannel, reorgedBlocksChannel}
+
+// stream event types
+const (
+	blockEvent       = "block"
+	transactionEvent = "transaction"
+	reorgEvent       = "reorg"
+)
+
+// subscriberBuffer is the number of events queued for a subscriber, a
+// subscriber falling further behind is disconnected
+const subscriberBuffer = 64
+
+// StreamEvent is an event pushed to the live feed clients
+type StreamEvent struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// StreamBlock is the DTO of a stored block in the live feed
+type StreamBlock struct {
+	BlockNum   uint64 `json:"block_num"`
+	BlockHash  string `json:"block_hash"`
+	ParentHash string `json:"parent_hash"`
+	BlockTime  uint64 `json:"block_time"`
+}
+
+// StreamReorg is the DTO of a block that became an uncle in the live feed
+type StreamReorg struct {
+	BlockNum  uint64 `json:"block_num"`
+	BlockHash string `json:"block_hash"`
+}
+
+// StreamTransaction is the DTO of a stored transaction in the live feed
+type StreamTransaction struct {
+	TxHash    string           `json:"tx_hash"`
+	BlockNum  uint64           `json:"block_num"`
+	BlockHash string           `json:"block_hash"`
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	Value     string           `json:"value"`
+	Logs      []TransactionLog `json:"logs"`
+}
+
+// streamFilter selects the events sent to a subscriber. Transactions are
+// only sent when an address or a topic is given.
+type streamFilter struct {
+	blocks  bool
+	reorgs  bool
+	address string
+	topic   string
+}
+
+// newStreamFilter builds a filter from the events, address and topic query
+// parameters, events is a comma separated list of block, transaction and reorg
+func newStreamFilter(events, address, topic string) streamFilter {
+	filter := streamFilter{blocks: true, reorgs: true}
+	if events != "" {
+		filter.blocks, filter.reorgs = false, false
+		for _, event := range strings.Split(events, ",") {
+			switch strings.TrimSpace(event) {
+			case blockEvent:
+				filter.blocks = true
+			case reorgEvent:
+				filter.reorgs = true
+			}
+		}
+	}
+	if common.IsHexAddress(address) {
+		filter.address = common.HexToAddress(address).Hex()
+	}
+	if topic != "" {
+		filter.topic = common.HexToHash(topic).Hex()
+	}
+	return filter
+}
+
+// wantsTransactions reports whether transactions should be fetched for the
+// subscriber at all
+func (f streamFilter) wantsTransactions() bool {
+	return f.address != "" || f.topic != ""
+}
+
+func (f streamFilter) match(event StreamEvent) bool {
+	switch event.Type {
+	case blockEvent:
+		return f.blocks
+	case reorgEvent:
+		return f.reorgs
+	case transactionEvent:
+		tx, ok := event.Data.(*StreamTransaction)
+		if !ok || !f.wantsTransactions() {
+			return false
+		}
+		return f.matchTransaction(tx)
+	}
+	return false
+}
+
+// matchTransaction reports whether the transaction involves the address and
+// emitted a log with the topic, an empty address or topic matches anything
+func (f streamFilter) matchTransaction(tx *StreamTransaction) bool {
+	addressMatched := f.address == "" || strings.EqualFold(tx.From, f.address) || strings.EqualFold(tx.To, f.address)
+	topicMatched := f.topic == ""
+	for _, log := range tx.Logs {
+		if !addressMatched && strings.EqualFold(log.Address, f.address) {
+			addressMatched = true
+		}
+		for _, topic := range log.Topics {
+			if !topicMatched && strings.EqualFold(topic, f.topic) {
+				topicMatched = true
+			}
+		}
+	}
+	return addressMatched && topicMatched
+}
+
+type subscriber struct {
+	filter streamFilter
+	events chan StreamEvent
+}
+
+// streamHub fans the Postgres notifications out to the live feed subscribers
+type streamHub struct {
+	dbClient *pkg.DBClient
+	listener *pkg.DBListener
+	logger   *zerolog.Logger
+
+	mu          sync.RWMutex
+	subscribers map[*subscriber]struct{}
+}
+
+func newStreamHub(dbClient *pkg.DBClient, listener *pkg.DBListener, logger *zerolog.Logger) *streamHub {
+	return &streamHub{
+		dbClient:    dbClient,
+		listener:    listener,
+		logger:      logger,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// subscribe registers a subscriber, its channel is closed when it is
+// unsubscribed or falls behind
+func (h *streamHub) subscribe(filter streamFilter) *subscriber {
+	sub := &subscriber{
+		filter: filter,
+		events: make(chan StreamEvent, subscriberBuffer),
+	}
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *streamHub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// run dispatches notifications until the context is canceled
+func (h *streamHub) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification, ok := <-h.listener.Notify:
+			if !ok {
+				return
+			}
+			// a nil notification is sent after the listener reconnected,
+			// notifications sent in between are lost
+			if notification == nil {
+				h.logger.Warn().Msg("stream listener reconnected")
+				continue
+			}
+
+			event, err := h.toEvent(ctx, notification.Channel, notification.Extra)
+			if err != nil {
+				h.logger.Error().Err(err).Msgf("failed to handle %s notification", notification.Channel)
+				continue
+			}
+			if event != nil {
+				h.broadcast(*event)
+			}
+		}
+	}
+}
+
+func (h *streamHub) toEvent(ctx context.Context, channel, payload string) (*StreamEvent, error) {
+	switch channel {
+	case newBlocksChannel:
+		var block StreamBlock
+		if err := json.Unmarshal([]byte(payload), &block); err != nil {
+			return nil, err
+		}
+		return &StreamEvent{Type: blockEvent, Data: block}, nil
+	case reorgedBlocksChannel:
+		var reorg StreamReorg
+		if err := json.Unmarshal([]byte(payload), &reorg); err != nil {
+			return nil, err
+		}
+		return &StreamEvent{Type: reorgEvent, Data: reorg}, nil
+	case newTransactionsChannel:
+		if !h.hasTransactionSubscribers() {
+			return nil, nil
+		}
+		var notification struct {
+			TxHash    string `json:"tx_hash"`
+			BlockHash string `json:"block_hash"`
+		}
+		if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+			return nil, err
+		}
+		tx, err := h.queryTransaction(ctx, notification.TxHash, notification.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		return &StreamEvent{Type: transactionEvent, Data: tx}, nil
+	}
+	return nil, nil
+}
+
+func (h *streamHub) hasTransactionSubscribers() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subscribers {
+		if sub.filter.wantsTransactions() {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *streamHub) queryTransaction(ctx context.Context, hash, blockHash string) (*StreamTransaction, error) {
+	var tx model.Transaction
+	row := h.dbClient.QueryRowContext(ctx, "SELECT hash, block_number, block_hash, from_address, to_address, value, logs FROM transactions WHERE hash = $1 AND block_hash = $2", hash, blockHash)
+	if err := row.Scan(&tx.Hash, &tx.BlockNumber, &tx.BlockHash, &tx.From, &tx.To, &tx.Value, &tx.Logs); err != nil {
+		return nil, err
+	}
+
+	streamTx := &StreamTransaction{
+		TxHash:    tx.Hash,
+		BlockNum:  tx.BlockNumber,
+		BlockHash: tx.BlockHash,
+		From:      tx.From,
+		To:        tx.To,
+		Value:     tx.Value,
+		Logs:      make([]TransactionLog, len(tx.Logs)),
+	}
+	for i, log := range tx.Logs {
+		streamTx.Logs[i] = TransactionLog{
+			Index:   log.Index,
+			Address: log.Address,
+			Topics:  log.Topics,
+			Data:    log.Data,
+		}
+	}
+	return streamTx, nil
+}
+
+func (h *streamHub) broadcast(event StreamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers {
+		if !sub.filter.match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			h.logger.Warn().Msg("disconnecting slow stream subscriber")
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// close disconnects all subscribers
+func (h *streamHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// streamKeepAlive is the interval of the keep-alive messages that stop
+// proxies from closing idle streams
+const streamKeepAlive = 30 * time.Second
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// StreamBlocks pushes the live feed as Server-Sent Events. The events query
+// parameter selects block and reorg events, and transactions involving the
+// address query parameter or emitting a log with the topic query parameter
+// are pushed as well.
+func (h *Server) StreamBlocks(c *gin.Context) {
+	sub := h.hub.subscribe(newStreamFilter(c.Query("events"), c.Query("address"), c.Query("topic")))
+	defer h.hub.unsubscribe(sub)
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-sub.events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event.Data)
+			return true
+		case <-ticker.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		}
+	})
+}
+
+// StreamWebSocket pushes the live feed over a WebSocket, it accepts the same
+// query parameters as StreamBlocks and sends every event as a JSON message
+func (h *Server) StreamWebSocket(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to upgrade websocket")
+		return
+	}
+	defer conn.Close()
+
+	sub := h.hub.subscribe(newStreamFilter(c.Query("events"), c.Query("address"), c.Query("topic")))
+	defer h.hub.unsubscribe(sub)
+
+	// the client doesn't send anything, reading only detects the close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-sub.events:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog"
+)
+
+func TestStreamFilter(t *testing.T) {
+	t.Parallel()
+
+	address := common.HexToAddress("0x01").Hex()
+	topic := common.HexToHash("0x02").Hex()
+	tx := &StreamTransaction{
+		From: common.HexToAddress("0x03").Hex(),
+		To:   common.HexToAddress("0x04").Hex(),
+		Logs: []TransactionLog{{Address: address, Topics: []string{topic}}},
+	}
+
+	tests := []struct {
+		name     string
+		filter   streamFilter
+		event    StreamEvent
+		expected bool
+	}{
+		{name: "blocks by default", filter: newStreamFilter("", "", ""), event: StreamEvent{Type: blockEvent}, expected: true},
+		{name: "reorgs by default", filter: newStreamFilter("", "", ""), event: StreamEvent{Type: reorgEvent}, expected: true},
+		{name: "no transactions without filter", filter: newStreamFilter("", "", ""), event: StreamEvent{Type: transactionEvent, Data: tx}, expected: false},
+		{name: "only reorgs", filter: newStreamFilter("reorg", "", ""), event: StreamEvent{Type: blockEvent}, expected: false},
+		{name: "log address", filter: newStreamFilter("", "0x0000000000000000000000000000000000000001", ""), event: StreamEvent{Type: transactionEvent, Data: tx}, expected: true},
+		{name: "sender address", filter: newStreamFilter("", tx.From, ""), event: StreamEvent{Type: transactionEvent, Data: tx}, expected: true},
+		{name: "other address", filter: newStreamFilter("", common.HexToAddress("0x05").Hex(), ""), event: StreamEvent{Type: transactionEvent, Data: tx}, expected: false},
+		{name: "topic", filter: newStreamFilter("", "", topic), event: StreamEvent{Type: transactionEvent, Data: tx}, expected: true},
+		{name: "address and other topic", filter: newStreamFilter("", address, common.HexToHash("0x06").Hex()), event: StreamEvent{Type: transactionEvent, Data: tx}, expected: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.filter.match(tt.event); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestStreamHubDisconnectsSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	logger := zerolog.Nop()
+	hub := newStreamHub(nil, nil, &logger)
+	sub := hub.subscribe(newStreamFilter("", "", ""))
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		hub.broadcast(StreamEvent{Type: blockEvent, Data: StreamBlock{BlockNum: uint64(i)}})
+	}
+
+	count := 0
+	for range sub.events {
+		count++
+	}
+	if count != subscriberBuffer {
+		t.Errorf("Expected %d events before disconnect, got %d", subscriberBuffer, count)
+	}
+
+	// unsubscribing a disconnected subscriber must not panic
+	hub.unsubscribe(sub)
+}
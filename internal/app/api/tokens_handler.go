@@ -0,0 +1,192 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/korprulu/interview-homework-b/internal/model"
+)
+
+// holdersOrder sorts the holders of a token from the largest balance
+var holdersOrder = pageOrder{
+	columns: []keyColumn{{name: "balance", numeric: true}, {name: "holder"}},
+	desc:    true,
+}
+
+// addressTokensOrder sorts the tokens held by an address by token address
+var addressTokensOrder = pageOrder{
+	columns: []keyColumn{{name: "b.token"}},
+}
+
+// Token is the token metadata DTO, the metadata is empty until the token is
+// enriched
+type Token struct {
+	Address     string `json:"address"`
+	Name        string `json:"name,omitempty"`
+	Symbol      string `json:"symbol,omitempty"`
+	Decimals    *uint8 `json:"decimals,omitempty"`
+	TotalSupply string `json:"total_supply,omitempty"`
+}
+
+// TokenHolder is the DTO of a holder balance of a token
+type TokenHolder struct {
+	Holder  string `json:"holder"`
+	Balance string `json:"balance"`
+	// FormattedBalance is the balance in whole tokens, it is empty when the
+	// decimals of the token are unknown
+	FormattedBalance string `json:"formatted_balance,omitempty"`
+}
+
+// TokenBalance is the DTO of a token balance of an address
+type TokenBalance struct {
+	Token
+	Balance          string `json:"balance"`
+	FormattedBalance string `json:"formatted_balance,omitempty"`
+}
+
+// formatBalance formats a balance with the decimals of its token
+func formatBalance(balance string, decimals *uint8) string {
+	if decimals == nil {
+		return ""
+	}
+	return model.FormatUnits(balance, *decimals)
+}
+
+// queryToken returns the metadata of a token, only the address is set when
+// the token is not enriched yet
+func (h *Server) queryToken(c *gin.Context, address string) (Token, error) {
+	token := Token{Address: address}
+	var decimals sql.NullInt16
+	row := h.dbClient.QueryRowContext(c.Request.Context(), "SELECT name, symbol, decimals, total_supply FROM tokens WHERE address = $1", address)
+	err := row.Scan(&token.Name, &token.Symbol, &decimals, &token.TotalSupply)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return token, nil
+		}
+		return token, err
+	}
+	if decimals.Valid {
+		d := uint8(decimals.Int16)
+		token.Decimals = &d
+	}
+	return token, nil
+}
+
+// GetToken returns the metadata of an ERC-20 token
+func (h *Server) GetToken(c *gin.Context) {
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address"})
+		return
+	}
+
+	token, err := h.queryToken(c, common.HexToAddress(address).Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// GetTokenHolders returns the top holders of an ERC-20 token
+func (h *Server) GetTokenHolders(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address"})
+		return
+	}
+
+	params, err := parsePageParams(c, holdersOrder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.queryToken(c, common.HexToAddress(address).Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	statement, args := params.paginate("SELECT holder, balance::TEXT FROM token_balances",
+		[]string{"token = $1", "balance > 0"}, []any{token.Address})
+	rows, err := h.dbClient.QueryContext(ctx, statement, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	var holders []TokenHolder
+
+	for rows.Next() {
+		var holder TokenHolder
+		err := rows.Scan(&holder.Holder, &holder.Balance)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		holder.FormattedBalance = formatBalance(holder.Balance, token.Decimals)
+
+		holders = append(holders, holder)
+	}
+
+	c.JSON(http.StatusOK, newPage(holders, params, func(holder TokenHolder) []string {
+		return []string{holder.Balance, holder.Holder}
+	}))
+}
+
+// GetAddressTokens returns the ERC-20 token balances of an address
+func (h *Server) GetAddressTokens(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address"})
+		return
+	}
+
+	params, err := parsePageParams(c, addressTokensOrder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statement, args := params.paginate("SELECT b.token, b.balance::TEXT, COALESCE(t.name, ''), COALESCE(t.symbol, ''), t.decimals, COALESCE(t.total_supply, '') FROM token_balances b LEFT JOIN tokens t ON t.address = b.token",
+		[]string{"b.holder = $1", "b.balance > 0"}, []any{common.HexToAddress(address).Hex()})
+	rows, err := h.dbClient.QueryContext(ctx, statement, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	var balances []TokenBalance
+
+	for rows.Next() {
+		var balance TokenBalance
+		var decimals sql.NullInt16
+		err := rows.Scan(&balance.Address, &balance.Balance, &balance.Name, &balance.Symbol, &decimals, &balance.TotalSupply)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if decimals.Valid {
+			d := uint8(decimals.Int16)
+			balance.Decimals = &d
+		}
+		balance.FormattedBalance = formatBalance(balance.Balance, balance.Decimals)
+
+		balances = append(balances, balance)
+	}
+
+	c.JSON(http.StatusOK, newPage(balances, params, func(balance TokenBalance) []string {
+		return []string{balance.Address}
+	}))
+}
@@ -4,37 +4,152 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 	"github.com/korprulu/interview-homework-b/internal/model"
 )
 
 // Transaction is a DTO for a transaction
 type Transaction struct {
-	TxHash string           `json:"tx_hash"`
-	From   string           `json:"from"`
-	To     string           `json:"to"`
-	Nonce  uint64           `json:"nonce"`
-	Data   string           `json:"data"`
-	Value  string           `json:"value"`
-	Logs   []TransactionLog `json:"logs"`
+	TxHash      string `json:"tx_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	// BlockStatus is unfinalized, finalized, or orphaned when the block was
+	// replaced by a reorg
+	BlockStatus string `json:"block_status"`
+
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Nonce uint64 `json:"nonce"`
+	Data  string `json:"data"`
+	Value string `json:"value"`
+	// Logs are null in a block response without expand=logs
+	Logs []TransactionLog `json:"logs"`
+
+	ContractAddress string `json:"contract_address,omitempty"`
+
+	Type                 uint8         `json:"type"`
+	Gas                  uint64        `json:"gas"`
+	GasPrice             string        `json:"gas_price"`
+	MaxFeePerGas         string        `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string        `json:"max_priority_fee_per_gas,omitempty"`
+	MaxFeePerBlobGas     string        `json:"max_fee_per_blob_gas,omitempty"`
+	BlobVersionedHashes  []string      `json:"blob_versioned_hashes,omitempty"`
+	AccessList           []AccessTuple `json:"access_list"`
+	ChainID              string        `json:"chain_id,omitempty"`
+	V                    string        `json:"v"`
+	R                    string        `json:"r"`
+	S                    string        `json:"s"`
+}
+
+// AccessTuple is a DTO for an access list entry
+type AccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storage_keys"`
 }
 
 // TransactionLog is a DTO for a transaction log
 type TransactionLog struct {
-	Index uint   `json:"index"`
-	Data  string `json:"data"`
+	Index   uint     `json:"index"`
+	Address string   `json:"address,omitempty"`
+	Topics  []string `json:"topics,omitempty"`
+	Data    string   `json:"data"`
+}
+
+// transactionColumns are the columns scanned by scanTransaction from
+// transactions t joined with blocks b
+const transactionColumns = "t.hash, t.block_number, t.block_hash, " + blockStatusColumn + ", t.from_address, t.to_address, t.nonce, t.data, t.value, t.logs, t.contract_address, t.type, t.gas, " + feeColumns + ", t.blob_versioned_hashes, t.access_list, COALESCE(t.chain_id::text, ''), t.v, t.r, t.s"
+
+// feeColumns are the fee caps of transactions t, NULL when the type of the
+// transaction doesn't have them
+const feeColumns = "COALESCE(t.gas_price::text, ''), COALESCE(t.max_fee_per_gas::text, ''), COALESCE(t.max_priority_fee_per_gas::text, ''), COALESCE(t.max_fee_per_blob_gas::text, '')"
+
+// canonicalFirst orders the copies of a transaction re-included by a reorg,
+// the one in the canonical chain first, of transactions t joined with blocks b
+const canonicalFirst = "COALESCE(b.is_uncle, false), t.block_number DESC"
+
+// blockStatusColumn is the status of the block b, orphaned when it was
+// replaced by a reorg
+const blockStatusColumn = "CASE WHEN b.is_uncle THEN 'orphaned' ELSE COALESCE(b.status, '') END"
+
+// scanTransaction scans a row selected with transactionColumns
+func scanTransaction(row rowScanner) (*model.Transaction, string, error) {
+	var tx model.Transaction
+	var blockStatus string
+	err := row.Scan(&tx.Hash, &tx.BlockNumber, &tx.BlockHash, &blockStatus, &tx.From, &tx.To, &tx.Nonce, &tx.Data, &tx.Value, &tx.Logs, &tx.ContractAddress, &tx.Type, &tx.Gas, &tx.GasPrice, &tx.MaxFeePerGas, &tx.MaxPriorityFeePerGas, &tx.MaxFeePerBlobGas, &tx.BlobVersionedHashes, &tx.AccessList, &tx.ChainID, &tx.V, &tx.R, &tx.S)
+	if err != nil {
+		return nil, "", err
+	}
+	return &tx, blockStatus, nil
+}
+
+// toTransactionDTO converts a transaction to its DTO, the logs are only set
+// with withLogs
+func toTransactionDTO(tx *model.Transaction, blockStatus string, withLogs bool) Transaction {
+	txDTO := Transaction{
+		TxHash:      tx.Hash,
+		BlockNumber: tx.BlockNumber,
+		BlockHash:   tx.BlockHash,
+		BlockStatus: blockStatus,
+		From:        tx.From,
+		To:          tx.To,
+		Nonce:       tx.Nonce,
+		Data:        tx.Data,
+		Value:       tx.Value,
+
+		ContractAddress: tx.ContractAddress,
+
+		Type:                 tx.Type,
+		Gas:                  tx.Gas,
+		GasPrice:             tx.GasPrice,
+		MaxFeePerGas:         tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+		MaxFeePerBlobGas:     tx.MaxFeePerBlobGas,
+		BlobVersionedHashes:  tx.BlobVersionedHashes,
+		AccessList:           make([]AccessTuple, len(tx.AccessList)),
+		ChainID:              tx.ChainID,
+		V:                    tx.V,
+		R:                    tx.R,
+		S:                    tx.S,
+	}
+
+	if withLogs {
+		txDTO.Logs = make([]TransactionLog, len(tx.Logs))
+		for i, log := range tx.Logs {
+			txDTO.Logs[i] = TransactionLog{
+				Index:   log.Index,
+				Address: log.Address,
+				Topics:  log.Topics,
+				Data:    log.Data,
+			}
+		}
+	}
+
+	for i, tuple := range tx.AccessList {
+		storageKeys := make([]string, len(tuple.StorageKeys))
+		for j, key := range tuple.StorageKeys {
+			storageKeys[j] = key.Hex()
+		}
+		txDTO.AccessList[i] = AccessTuple{
+			Address:     tuple.Address.Hex(),
+			StorageKeys: storageKeys,
+		}
+	}
+
+	return txDTO
 }
 
 // GetTransactionByHash returns a transaction by hash
 func (h *Server) GetTransactionByHash(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	txHash := c.Param("txHash")
-	row := h.dbClient.QueryRowContext(ctx, "SELECT hash, from_address, to_address, nonce, data, value, logs FROM transactions WHERE hash = $1", txHash)
+	// the stored hashes are lowercase, the route accepts any case
+	txHash := common.HexToHash(c.Param("txHash")).Hex()
+	row := h.dbClient.QueryRowContext(ctx, "SELECT "+transactionColumns+" FROM transactions t LEFT JOIN blocks b ON b.hash = t.block_hash AND b.number = t.block_number WHERE t.hash = $1 ORDER BY "+canonicalFirst+" LIMIT 1", txHash)
 
-	var tx model.Transaction
-	err := row.Scan(&tx.Hash, &tx.From, &tx.To, &tx.Nonce, &tx.Data, &tx.Value, &tx.Logs)
+	tx, blockStatus, err := scanTransaction(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
@@ -44,21 +159,75 @@ func (h *Server) GetTransactionByHash(c *gin.Context) {
 		return
 	}
 
-	txDTO := Transaction{
-		TxHash: tx.Hash,
-		From:   tx.From,
-		To:     tx.To,
-		Nonce:  tx.Nonce,
-		Data:   tx.Data,
-		Value:  tx.Value,
-		Logs:   make([]TransactionLog, len(tx.Logs)),
+	setCachePolicy(c, blockStatus == "finalized", tx.BlockHash)
+	c.JSON(http.StatusOK, toTransactionDTO(tx, blockStatus, true))
+}
+
+// InternalTransaction is a DTO for a call made during a transaction
+type InternalTransaction struct {
+	Index        uint64 `json:"index"`
+	TraceAddress []int  `json:"trace_address"`
+	Depth        int    `json:"depth"`
+	Type         string `json:"type"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Value        string `json:"value"`
+	Gas          uint64 `json:"gas"`
+	GasUsed      uint64 `json:"gas_used"`
+	Error        string `json:"error,omitempty"`
+}
+
+// internalTransactionsOrder sorts the internal transactions of a transaction
+// in execution order
+var internalTransactionsOrder = pageOrder{
+	columns: []keyColumn{{name: "it.index", numeric: true}},
+}
+
+// GetInternalTransactions returns the internal transactions of a transaction
+// in the canonical chain
+func (h *Server) GetInternalTransactions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	params, err := parsePageParams(c, internalTransactionsOrder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statement, args := params.paginate("SELECT it.index, it.trace_address, it.depth, it.type, it.from_address, it.to_address, it.value, it.gas, it.gas_used, it.error FROM internal_transactions it JOIN blocks b ON b.hash = it.block_hash AND b.number = it.block_number",
+		[]string{"it.tx_hash = $1", "b.is_uncle = false"}, []any{common.HexToHash(c.Param("txHash")).Hex()})
+	rows, err := h.dbClient.QueryContext(ctx, statement, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	for i, log := range tx.Logs {
-		txDTO.Logs[i] = TransactionLog{
-			Index: log.Index,
-			Data:  log.Data,
+	defer rows.Close()
+
+	var internalTxs []InternalTransaction
+
+	for rows.Next() {
+		var itx model.InternalTransaction
+		err := rows.Scan(&itx.Index, &itx.TraceAddress, &itx.Depth, &itx.Type, &itx.From, &itx.To, &itx.Value, &itx.Gas, &itx.GasUsed, &itx.Error)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+
+		internalTxs = append(internalTxs, InternalTransaction{
+			Index:        itx.Index,
+			TraceAddress: itx.TraceAddress,
+			Depth:        itx.Depth,
+			Type:         itx.Type,
+			From:         itx.From,
+			To:           itx.To,
+			Value:        itx.Value,
+			Gas:          itx.Gas,
+			GasUsed:      itx.GasUsed,
+			Error:        itx.Error,
+		})
 	}
 
-	c.JSON(http.StatusOK, txDTO)
+	c.JSON(http.StatusOK, newPage(internalTxs, params, func(itx InternalTransaction) []string {
+		return []string{strconv.FormatUint(itx.Index, 10)}
+	}))
 }
@@ -0,0 +1,71 @@
+package dispatcher
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	const secret = "secret"
+	body := []byte(`{"event":"transaction"}`)
+
+	received := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if err != nil || r.Header.Get(SignatureHeader) != Sign(secret, timestamp, payload) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	logger := zerolog.Nop()
+	d := NewDispatcher(Config{Logger: &logger, TimeoutSecs: 5})
+
+	if err := d.send(context.Background(), receiver.URL, secret, TransactionEvent, 7, body); err != nil {
+		t.Fatal(err)
+	}
+	r := <-received
+	if r.Header.Get(EventHeader) != TransactionEvent {
+		t.Errorf("Expected event %s, got %s", TransactionEvent, r.Header.Get(EventHeader))
+	}
+	if r.Header.Get(DeliveryHeader) != "7" {
+		t.Errorf("Expected delivery 7, got %s", r.Header.Get(DeliveryHeader))
+	}
+
+	if err := d.send(context.Background(), receiver.URL, "wrong secret", TransactionEvent, 8, body); err == nil {
+		t.Error("Expected an error for a rejected webhook")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 10 * time.Second},
+		{attempts: 2, expected: 20 * time.Second},
+		{attempts: 4, expected: 80 * time.Second},
+		{attempts: 20, expected: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts, retryBaseDelay, retryMaxDelay); got != tt.expected {
+			t.Errorf("Expected delay %s after %d attempts, got %s", tt.expected, tt.attempts, got)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+// Package dispatcher ...
+package dispatcher
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/rs/zerolog"
+)
+
+// webhook events
+const (
+	TransactionEvent = "transaction"
+	ReorgEvent       = "reorg"
+)
+
+// base and max delay between two attempts of a delivery
+const (
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// Dispatcher matches newly indexed transactions against the webhook
+// subscriptions and delivers them
+type Dispatcher struct {
+	dbClient   *pkg.DBClient
+	httpClient *http.Client
+	logger     *zerolog.Logger
+
+	batchSize      int
+	lookbackBlocks uint64
+	maxAttempts    int
+	timeout        time.Duration
+	watchInterval  time.Duration
+
+	cancelFunc context.CancelFunc
+}
+
+// Config is the configuration for the dispatcher
+type Config struct {
+	DBClient *pkg.DBClient
+	Logger   *zerolog.Logger
+	// BatchSize is the number of deliveries sent at a time
+	BatchSize int
+	// LookbackBlocks is how many blocks below the last matched block are
+	// matched again, it covers blocks replaced by a reorg after they were
+	// matched. A new subscription starts this many blocks below the head.
+	LookbackBlocks uint64
+	// MaxAttempts is the number of attempts before a delivery is failed
+	MaxAttempts       int
+	TimeoutSecs       int
+	WatchIntervalSecs int
+}
+
+// Payload is the body of a webhook request
+type Payload struct {
+	Event          string `json:"event"`
+	DeliveryID     int64  `json:"delivery_id"`
+	SubscriptionID int64  `json:"subscription_id"`
+	// CorrectsDeliveryID is set on reorg events to the delivery of the
+	// transaction whose block is no longer canonical
+	CorrectsDeliveryID int64  `json:"corrects_delivery_id,omitempty"`
+	TxHash             string `json:"tx_hash"`
+	BlockNum           uint64 `json:"block_num"`
+	BlockHash          string `json:"block_hash"`
+	// Transaction is only set on transaction events
+	Transaction *PayloadTransaction `json:"transaction,omitempty"`
+}
+
+// PayloadTransaction is the transaction sent in a webhook request
+type PayloadTransaction struct {
+	From            string                `json:"from"`
+	To              string                `json:"to"`
+	Value           string                `json:"value"`
+	ContractAddress string                `json:"contract_address,omitempty"`
+	Logs            model.TransactionLogs `json:"logs"`
+}
+
+type delivery struct {
+	id                 int64
+	subscriptionID     int64
+	event              string
+	txHash             string
+	blockNumber        uint64
+	blockHash          string
+	attempts           int
+	correctsDeliveryID int64
+	url                string
+	secret             string
+}
+
+// NewDispatcher creates a new dispatcher
+func NewDispatcher(cfg Config) *Dispatcher {
+	timeout := time.Duration(cfg.TimeoutSecs) * time.Second
+	return &Dispatcher{
+		dbClient:       cfg.DBClient,
+		httpClient:     &http.Client{Timeout: timeout},
+		logger:         cfg.Logger,
+		batchSize:      cfg.BatchSize,
+		lookbackBlocks: cfg.LookbackBlocks,
+		maxAttempts:    cfg.MaxAttempts,
+		timeout:        timeout,
+		watchInterval:  time.Duration(cfg.WatchIntervalSecs) * time.Second,
+	}
+}
+
+// Start starts the dispatcher
+func (d *Dispatcher) Start(ctx context.Context) {
+	newCtx, cancelFunc := context.WithCancel(ctx)
+	d.cancelFunc = cancelFunc
+
+	for {
+		if err := d.process(newCtx); err != nil {
+			d.logger.Error().Err(err).Msg("failed to process")
+		}
+
+		select {
+		case <-newCtx.Done():
+			return
+		case <-time.After(d.watchInterval):
+		}
+	}
+}
+
+// Close closes the dispatcher
+func (d *Dispatcher) Close() {
+	if d.cancelFunc != nil {
+		d.cancelFunc()
+	}
+	d.dbClient.Close()
+}
+
+func (d *Dispatcher) process(ctx context.Context) error {
+	if err := d.matchTransactions(ctx); err != nil {
+		return err
+	}
+
+	if err := d.correctReorgs(ctx); err != nil {
+		return err
+	}
+
+	return d.deliver(ctx)
+}
+
+// maxMatchBlocks caps the blocks a subscription is advanced over in a pass
+const maxMatchBlocks = 1000
+
+// completeBlocksStatement returns the last block of the range $1 to $2 up to
+// which every canonical block is stored with all its transactions
+const completeBlocksStatement = `SELECT COALESCE(MIN(n) - 1, $2) FROM generate_series($1::BIGINT, $2::BIGINT) n
+	WHERE NOT EXISTS (
+		SELECT 1 FROM blocks b WHERE b.number = n AND b.is_uncle = false
+		AND b.transaction_count = (SELECT COUNT(*) FROM transactions t WHERE t.block_hash = b.hash AND t.block_number = b.number)
+	)`
+
+// matchStatement creates the deliveries of the transactions of the canonical
+// blocks $3 to $4 that match a subscription
+const matchStatement = `INSERT INTO webhook_deliveries (subscription_id, event, tx_hash, block_number, block_hash)
+	SELECT $1, $2, t.hash, t.block_number, t.block_hash
+	FROM transactions t JOIN blocks b ON b.hash = t.block_hash AND b.number = t.block_number
+	WHERE b.is_uncle = false AND t.block_number BETWEEN $3 AND $4 AND b.timestamp >= $5
+	AND ($6 = '' OR t.from_address = $6 OR t.to_address = $6 OR t.contract_address = $6
+		OR EXISTS (SELECT 1 FROM jsonb_array_elements(t.logs) l WHERE l->>'address' = $6 OR l->'topics' ? $7))
+	AND ($8 = '' OR EXISTS (SELECT 1 FROM jsonb_array_elements(t.logs) l WHERE l->'topics' ? $8))
+	ON CONFLICT DO NOTHING`
+
+type subscription struct {
+	id               int64
+	address          string
+	topic            string
+	minConfirmations int64
+	createdAt        int64
+	// matchedBlock is the last block matched, -1 before the first match
+	matchedBlock int64
+}
+
+// matchTransactions creates the deliveries of the transactions in confirmed
+// canonical blocks that match a subscription. A transaction matches when the
+// address sent it, received it, was created by it, emitted one of its logs
+// or is indexed in one of its logs, and when one of its logs has the topic.
+func (d *Dispatcher) matchTransactions(ctx context.Context) error {
+	var head int64
+	row := d.dbClient.QueryRowContext(ctx, "SELECT COALESCE(MAX(number), 0) FROM blocks WHERE is_uncle = false")
+	if err := row.Scan(&head); err != nil {
+		return err
+	}
+
+	rows, err := d.dbClient.QueryContext(ctx, "SELECT id, address, topic, min_confirmations, EXTRACT(EPOCH FROM created_at)::BIGINT, COALESCE(matched_block, -1) FROM webhook_subscriptions WHERE active = true")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var subscriptions []subscription
+	for rows.Next() {
+		var s subscription
+		if err := rows.Scan(&s.id, &s.address, &s.topic, &s.minConfirmations, &s.createdAt, &s.matchedBlock); err != nil {
+			return err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range subscriptions {
+		if head < s.minConfirmations {
+			continue
+		}
+		if err := d.matchSubscription(ctx, s, head-s.minConfirmations); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchSubscription matches the blocks after the cursor of the subscription
+// up to the confirmed head to, and advances the cursor over the blocks whose
+// transactions are all stored, so a transaction stored late is still matched.
+// The lookback blocks below the cursor are matched again for the blocks
+// replaced by a reorg after they were matched. A new subscription starts
+// lookback blocks below to.
+func (d *Dispatcher) matchSubscription(ctx context.Context, s subscription, to int64) error {
+	lookback := int64(d.lookbackBlocks)
+
+	next := s.matchedBlock + 1
+	from := next - lookback
+	if s.matchedBlock < 0 {
+		next = to - lookback
+		from = next
+	}
+	if next < 0 {
+		next = 0
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > next+maxMatchBlocks-1 {
+		to = next + maxMatchBlocks - 1
+	}
+
+	tx, err := d.dbClient.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	complete := next - 1
+	if next <= to {
+		if err := tx.QueryRowContext(ctx, completeBlocksStatement, next, to).Scan(&complete); err != nil {
+			return err
+		}
+	}
+	if complete < from {
+		return nil
+	}
+
+	var address, indexedAddress, topic string
+	if s.address != "" {
+		address = common.HexToAddress(s.address).Hex()
+		indexedAddress = common.BytesToHash(common.HexToAddress(s.address).Bytes()).Hex()
+	}
+	if s.topic != "" {
+		topic = common.HexToHash(s.topic).Hex()
+	}
+
+	_, err = tx.ExecContext(ctx, matchStatement, s.id, TransactionEvent, from, complete, s.createdAt, address, indexedAddress, topic)
+	if err != nil {
+		return err
+	}
+
+	if complete > s.matchedBlock {
+		_, err = tx.ExecContext(ctx, "UPDATE webhook_subscriptions SET matched_block = $2 WHERE id = $1", s.id, complete)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// correctReorgs creates a reorg delivery for every transaction delivery that
+// may have reached its webhook and whose block became an uncle, and cancels
+// the transaction deliveries that were never attempted
+func (d *Dispatcher) correctReorgs(ctx context.Context) error {
+	_, err := d.dbClient.ExecContext(ctx, `INSERT INTO webhook_deliveries (subscription_id, event, tx_hash, block_number, block_hash, corrects_delivery_id)
+		SELECT d.subscription_id, $1, d.tx_hash, d.block_number, d.block_hash, d.id
+		FROM webhook_deliveries d JOIN blocks b ON b.hash = d.block_hash AND b.number = d.block_number
+		WHERE d.event = $2 AND b.is_uncle = true AND (d.status = 'delivered' OR d.attempts > 0)
+		ON CONFLICT DO NOTHING`, ReorgEvent, TransactionEvent)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.dbClient.ExecContext(ctx, `UPDATE webhook_deliveries d SET status = 'cancelled'
+		FROM blocks b
+		WHERE b.hash = d.block_hash AND b.number = d.block_number AND b.is_uncle = true
+		AND d.event = $1 AND d.status = 'pending' AND d.attempts = 0`, TransactionEvent)
+	return err
+}
+
+// deliver sends the due deliveries. Deliveries are claimed by pushing their
+// next attempt past the request timeout, so several dispatchers can run.
+func (d *Dispatcher) deliver(ctx context.Context) error {
+	rows, err := d.dbClient.QueryContext(ctx, `UPDATE webhook_deliveries d SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= NOW() ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.subscription_id, d.event, d.tx_hash, d.block_number, d.block_hash, d.attempts, COALESCE(d.corrects_delivery_id, 0), s.url, s.secret`,
+		d.batchSize, int(2*d.timeout/time.Second))
+	if err != nil {
+		return err
+	}
+
+	var deliveries []delivery
+	for rows.Next() {
+		var dv delivery
+		err := rows.Scan(&dv.id, &dv.subscriptionID, &dv.event, &dv.txHash, &dv.blockNumber, &dv.blockHash, &dv.attempts, &dv.correctsDeliveryID, &dv.url, &dv.secret)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		deliveries = append(deliveries, dv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, dv := range deliveries {
+		body, err := d.payload(ctx, dv)
+		if err == nil {
+			err = d.send(ctx, dv.url, dv.secret, dv.event, dv.id, body)
+		}
+		if err := d.updateDelivery(ctx, dv, err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) payload(ctx context.Context, dv delivery) ([]byte, error) {
+	payload := Payload{
+		Event:              dv.event,
+		DeliveryID:         dv.id,
+		SubscriptionID:     dv.subscriptionID,
+		CorrectsDeliveryID: dv.correctsDeliveryID,
+		TxHash:             dv.txHash,
+		BlockNum:           dv.blockNumber,
+		BlockHash:          dv.blockHash,
+	}
+
+	if dv.event == TransactionEvent {
+		var tx PayloadTransaction
+		row := d.dbClient.QueryRowContext(ctx, "SELECT from_address, to_address, value, contract_address, logs FROM transactions WHERE hash = $1 AND block_hash = $2", dv.txHash, dv.blockHash)
+		if err := row.Scan(&tx.From, &tx.To, &tx.Value, &tx.ContractAddress, &tx.Logs); err != nil {
+			return nil, err
+		}
+		payload.Transaction = &tx
+	}
+
+	return json.Marshal(payload)
+}
+
+// updateDelivery records the result of an attempt, a failed delivery is
+// retried with exponential backoff until it runs out of attempts
+func (d *Dispatcher) updateDelivery(ctx context.Context, dv delivery, sendErr error) error {
+	attempts := dv.attempts + 1
+	if sendErr == nil {
+		_, err := d.dbClient.ExecContext(ctx, "UPDATE webhook_deliveries SET status = 'delivered', attempts = $2, last_error = '', delivered_at = NOW() WHERE id = $1", dv.id, attempts)
+		return err
+	}
+
+	d.logger.Warn().Err(sendErr).Msgf("failed to deliver webhook %d to %s", dv.id, dv.url)
+
+	status := "pending"
+	if attempts >= d.maxAttempts {
+		status = "failed"
+	}
+	delay := backoff(attempts, retryBaseDelay, retryMaxDelay)
+	_, err := d.dbClient.ExecContext(ctx, "UPDATE webhook_deliveries SET status = $2, attempts = $3, last_error = $4, next_attempt_at = NOW() + $5 * INTERVAL '1 second' WHERE id = $1 AND status = 'pending'",
+		dv.id, status, attempts, sendErr.Error(), int(delay/time.Second))
+	return err
+}
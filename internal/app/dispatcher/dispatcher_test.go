@@ -0,0 +1,217 @@
+//go:build integration
+
+package dispatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/korprulu/interview-homework-b/internal/config"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/rs/zerolog"
+)
+
+func dbClient(t *testing.T) *pkg.DBClient {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		User:     cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbClient
+}
+
+// deliveredTransactions returns the hashes of the transaction deliveries of
+// a subscription
+func deliveredTransactions(ctx context.Context, t *testing.T, db *pkg.DBClient, subscriptionID int64) map[string]bool {
+	rows, err := db.QueryContext(ctx, "SELECT tx_hash FROM webhook_deliveries WHERE subscription_id = $1 AND event = $2", subscriptionID, TransactionEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]bool)
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			t.Fatal(err)
+		}
+		hashes[hash] = true
+	}
+	return hashes
+}
+
+func TestMatchTransactions(t *testing.T) {
+	db := dbClient(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	dispatcher := NewDispatcher(Config{DBClient: db, Logger: &logger, LookbackBlocks: 10})
+
+	address := common.HexToAddress("0xd1")
+	indexedAddress := common.BytesToHash(address.Bytes()).Hex()
+	timestamp := uint64(time.Now().Add(time.Minute).Unix())
+	number := uint64(300001)
+
+	tx := func(hash string, block *model.Block, index uint64) *model.Transaction {
+		return &model.Transaction{Hash: hash, Index: index, From: "0x01", To: "0x02", Value: "0", BlockHash: block.Hash, BlockNumber: block.Number}
+	}
+	blocks := []*model.Block{
+		{Number: number, Hash: "0xd10", ParentHash: "0xd0f", Timestamp: timestamp, Status: "unfinalized"},
+		{Number: number + 1, Hash: "0xd11", ParentHash: "0xd10", Timestamp: timestamp, Status: "unfinalized"},
+		{Number: number + 2, Hash: "0xd12", ParentHash: "0xd11", Timestamp: timestamp, Status: "unfinalized"},
+	}
+	sent := tx("0xd100", blocks[0], 0)
+	sent.From = address.Hex()
+	unrelated := tx("0xd101", blocks[0], 1)
+	received := tx("0xd110", blocks[1], 0)
+	received.Logs = model.TransactionLogs{{Index: 0, Address: "0x03", Topics: []string{model.TransferEventTopic.Hex(), indexedAddress}, Data: "0x"}}
+	late := tx("0xd111", blocks[1], 1)
+	created := tx("0xd120", blocks[2], 0)
+	created.ContractAddress = address.Hex()
+	blocks[0].Transactions = model.Transactions{sent, unrelated}
+	blocks[1].Transactions = model.Transactions{received, late}
+	blocks[2].Transactions = model.Transactions{created}
+
+	for _, block := range blocks {
+		if err := block.Save(ctx, db); err != nil {
+			t.Fatal(err)
+		}
+		defer db.ExecContext(ctx, "DELETE FROM blocks WHERE number = $1 AND hash = $2", block.Number, block.Hash)
+		defer db.ExecContext(ctx, "DELETE FROM transactions WHERE block_hash = $1", block.Hash)
+	}
+	// the second transaction of the second block is not stored yet
+	if err := (model.Transactions{sent, unrelated, received, created}).Save(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	var subscriptionID int64
+	err := db.QueryRowContext(ctx, "INSERT INTO webhook_subscriptions (url, address, secret, matched_block) VALUES ('http://localhost', $1, 'secret', $2) RETURNING id", address.Hex(), number-1).Scan(&subscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", subscriptionID)
+	defer db.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE subscription_id = $1", subscriptionID)
+
+	matchedBlock := func() uint64 {
+		var matched uint64
+		if err := db.QueryRowContext(ctx, "SELECT matched_block FROM webhook_subscriptions WHERE id = $1", subscriptionID).Scan(&matched); err != nil {
+			t.Fatal(err)
+		}
+		return matched
+	}
+
+	if err := dispatcher.matchTransactions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := deliveredTransactions(ctx, t, db, subscriptionID); len(got) != 1 || !got[sent.Hash] {
+		t.Errorf("Expected only %s matched, got %v", sent.Hash, got)
+	}
+	if got := matchedBlock(); got != number {
+		t.Errorf("Expected the cursor to stop at the incomplete block, got %d", got)
+	}
+
+	if err := (model.Transactions{late}).Save(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := dispatcher.matchTransactions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := deliveredTransactions(ctx, t, db, subscriptionID)
+	for _, hash := range []string{sent.Hash, received.Hash, created.Hash} {
+		if !got[hash] {
+			t.Errorf("Expected %s matched, got %v", hash, got)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("Expected 3 deliveries, got %v", got)
+	}
+	if got := matchedBlock(); got != number+2 {
+		t.Errorf("Expected the cursor at %d, got %d", number+2, got)
+	}
+}
+
+func TestCorrectReorgs(t *testing.T) {
+	db := dbClient(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	dispatcher := NewDispatcher(Config{DBClient: db, Logger: &logger})
+
+	block := &model.Block{Number: uint64(300101), Hash: "0xe10", ParentHash: "0xe0f", Status: "unfinalized"}
+	if err := block.Save(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	defer db.ExecContext(ctx, "DELETE FROM blocks WHERE number = $1 AND hash = $2", block.Number, block.Hash)
+
+	var subscriptionID int64
+	err := db.QueryRowContext(ctx, "INSERT INTO webhook_subscriptions (url, address, secret, active) VALUES ('http://localhost', '0xe1', 'secret', false) RETURNING id").Scan(&subscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", subscriptionID)
+	defer db.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE subscription_id = $1", subscriptionID)
+
+	insertDelivery := func(txHash, status string, attempts int) int64 {
+		var id int64
+		err := db.QueryRowContext(ctx, "INSERT INTO webhook_deliveries (subscription_id, event, tx_hash, block_number, block_hash, status, attempts) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+			subscriptionID, TransactionEvent, txHash, block.Number, block.Hash, status, attempts).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	delivered := insertDelivery("0xe100", "delivered", 1)
+	retrying := insertDelivery("0xe101", "pending", 2)
+	pending := insertDelivery("0xe102", "pending", 0)
+
+	if _, err := db.ExecContext(ctx, "UPDATE blocks SET is_uncle = true WHERE number = $1 AND hash = $2", block.Number, block.Hash); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second pass doesn't correct the deliveries twice
+	for i := 0; i < 2; i++ {
+		if err := dispatcher.correctReorgs(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT corrects_delivery_id FROM webhook_deliveries WHERE subscription_id = $1 AND event = $2", subscriptionID, ReorgEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	corrected := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		corrected[id]++
+	}
+	if len(corrected) != 2 || corrected[delivered] != 1 || corrected[retrying] != 1 {
+		t.Errorf("Expected one reorg delivery for %d and %d, got %v", delivered, retrying, corrected)
+	}
+
+	var status string
+	if err := db.QueryRowContext(ctx, "SELECT status FROM webhook_deliveries WHERE id = $1", pending).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != "cancelled" {
+		t.Errorf("Expected the unattempted delivery cancelled, got %s", status)
+	}
+}
@@ -0,0 +1,125 @@
+// Package enricher ...
+package enricher
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/rs/zerolog"
+)
+
+// Enricher fetches the metadata of newly seen token contracts
+type Enricher struct {
+	dbClient  *pkg.DBClient
+	ethClient *pkg.EthClient
+	logger    *zerolog.Logger
+
+	batchSize     int
+	watchInterval time.Duration
+
+	cancelFunc context.CancelFunc
+}
+
+// Config is the configuration for the enricher
+type Config struct {
+	DBClient          *pkg.DBClient
+	EthClient         *pkg.EthClient
+	Logger            *zerolog.Logger
+	BatchSize         int
+	WatchIntervalSecs int
+}
+
+// NewEnricher creates a new enricher
+func NewEnricher(cfg Config) *Enricher {
+	return &Enricher{
+		dbClient:      cfg.DBClient,
+		ethClient:     cfg.EthClient,
+		logger:        cfg.Logger,
+		batchSize:     cfg.BatchSize,
+		watchInterval: time.Duration(cfg.WatchIntervalSecs) * time.Second,
+	}
+}
+
+// Start starts the enricher
+func (e *Enricher) Start(ctx context.Context) {
+	newCtx, cancelFunc := context.WithCancel(ctx)
+	e.cancelFunc = cancelFunc
+
+	for {
+		count, err := e.process(newCtx)
+		if err != nil {
+			e.logger.Error().Err(err).Msg("failed to process")
+		}
+
+		// keep going while there is a backlog of tokens
+		if err == nil && count == e.batchSize {
+			continue
+		}
+
+		select {
+		case <-newCtx.Done():
+			return
+		case <-time.After(e.watchInterval):
+		}
+	}
+}
+
+// Close closes the enricher
+func (e *Enricher) Close() {
+	if e.cancelFunc != nil {
+		e.cancelFunc()
+	}
+	e.dbClient.Close()
+	e.ethClient.Close()
+}
+
+// process enriches a batch of tokens without metadata and returns how many
+// tokens were enriched, a token that fails is skipped and retried by a later
+// batch
+func (e *Enricher) process(ctx context.Context) (int, error) {
+	addresses, err := e.queryNewTokens(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	enriched := 0
+	for _, address := range addresses {
+		metadata, err := e.ethClient.TokenMetadata(ctx, common.HexToAddress(address))
+		if err != nil {
+			e.logger.Error().Err(err).Msgf("failed to get metadata of token %s", address)
+			continue
+		}
+
+		if err := model.ToToken(address, metadata).Save(ctx, e.dbClient); err != nil {
+			e.logger.Error().Err(err).Msgf("failed to save token %s", address)
+			continue
+		}
+		enriched++
+	}
+
+	return enriched, nil
+}
+
+// queryNewTokens returns the tokens stored with their transfers that have no
+// metadata yet
+func (e *Enricher) queryNewTokens(ctx context.Context) ([]string, error) {
+	rows, err := e.dbClient.QueryContext(ctx, "SELECT address FROM tokens WHERE enriched_at IS NULL ORDER BY address LIMIT $1", e.batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+
+	return addresses, rows.Err()
+}
@@ -3,19 +3,33 @@ package processor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/korprulu/interview-homework-b/internal/model"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// retryIdle is how long a message stays unacknowledged after its processing
+// failed before it is processed again
+const retryIdle = time.Minute
+
 type (
 	// BlockProcessor processes blocks
 	BlockProcessor struct {
 		redisClient     *pkg.RedisClient
 		ethClient       *pkg.EthClient
+		verifyEthClient *pkg.EthClient
+		integrityCheck  bool
+		traceMode       string
+		trackBalances   bool
 		dbClient        *pkg.DBClient
 		logger          *zerolog.Logger
 		concurrentCount int
@@ -40,6 +54,19 @@ type (
 		Logger          *zerolog.Logger
 		ConcurrentCount int
 
+		// VerifyEthClient is an optional second provider, when set every block
+		// header is compared with it before the block is stored
+		VerifyEthClient *pkg.EthClient
+		// VerifyIntegrity enables recomputing the transactions and receipts
+		// roots of every block before it is stored
+		VerifyIntegrity bool
+		// TraceMode enables indexing internal transactions, it is empty,
+		// TraceModeDebug or TraceModeParity
+		TraceMode string
+		// TrackBalances enables deriving the ETH balance changes of every
+		// block, internal transfers are only counted when TraceMode is set
+		TrackBalances bool
+
 		BlockConsumerSteamName string
 		BlockConsumerGroupName string
 		TxProducerStreamName   string
@@ -53,11 +80,17 @@ type (
 		number blockNumber
 		id     string
 		status string
+		// spanContext is the span of the scanner that enqueued the block
+		spanContext trace.SpanContext
 	}
 )
 
 // NewBlockProcessor creates a new processor
 func NewBlockProcessor(ctx context.Context, config BlockProcessorConfig) (*BlockProcessor, error) {
+	if err := validateTraceMode(config.TraceMode); err != nil {
+		return nil, err
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		return nil, err
@@ -70,6 +103,7 @@ func NewBlockProcessor(ctx context.Context, config BlockProcessorConfig) (*Block
 		StreamName:   config.BlockConsumerSteamName,
 		GroupName:    config.BlockConsumerGroupName,
 		ConsumerName: consumerName,
+		ClaimMinIdle: retryIdle,
 	})
 	if err != nil {
 		return nil, err
@@ -86,6 +120,10 @@ func NewBlockProcessor(ctx context.Context, config BlockProcessorConfig) (*Block
 	processor := &BlockProcessor{
 		redisClient:             config.RedisClient,
 		ethClient:               config.EthClient,
+		verifyEthClient:         config.VerifyEthClient,
+		integrityCheck:          config.VerifyIntegrity,
+		traceMode:               config.TraceMode,
+		trackBalances:           config.TrackBalances,
 		dbClient:                config.DBClient,
 		logger:                  config.Logger,
 		blockConsumerStreamName: config.BlockConsumerSteamName,
@@ -120,9 +158,10 @@ func (p *BlockProcessor) fetchRecords(ctx context.Context) <-chan blockRecordDTO
 
 				for _, message := range messages {
 					ch <- blockRecordDTO{
-						id:     message.ID,
-						number: blockNumber(message.Values["number"].(string)),
-						status: message.Values["status"].(string),
+						id:          message.ID,
+						number:      blockNumber(message.Values["number"].(string)),
+						status:      message.Values["status"].(string),
+						spanContext: trace.SpanContextFromContext(pkg.ExtractTraceContext(ctx, message.Values)),
 					}
 				}
 			}
@@ -131,16 +170,19 @@ func (p *BlockProcessor) fetchRecords(ctx context.Context) <-chan blockRecordDTO
 	return ch
 }
 
-func (p *BlockProcessor) getBlockByNumber(ctx context.Context, number blockNumber) (*model.Block, error) {
+func (p *BlockProcessor) getBlockByNumber(ctx context.Context, number blockNumber) (*types.Block, error) {
 	convertedNum, err := hexutil.DecodeBig(string(number))
 	if err != nil {
 		return nil, err
 	}
 
-	block, err := p.ethClient.BlockByNumber(ctx, convertedNum)
-	if err != nil {
-		return nil, err
-	}
+	return p.ethClient.BlockByNumber(ctx, convertedNum)
+}
+
+// toBlockModel converts a block with its receipts, it fails when any of its
+// transactions fails to convert so a block is never stored partially
+func (p *BlockProcessor) toBlockModel(ctx context.Context, block *types.Block, receipts types.Receipts) (*model.Block, error) {
+	var err error
 
 	blockModel := model.ToBlockModel(block)
 	transactions := make(model.Transactions, len(block.Transactions()))
@@ -149,8 +191,9 @@ func (p *BlockProcessor) getBlockByNumber(ctx context.Context, number blockNumbe
 	for i, tx := range block.Transactions() {
 		transactions[i], err = model.ToTransaction(ctx, p.ethClient, tx, block, i)
 		if err != nil {
-			p.logger.Error().Err(err).Msg("failed to convert transaction")
+			return nil, fmt.Errorf("failed to convert transaction %s: %w", tx.Hash().Hex(), err)
 		}
+		transactions[i].SetReceipt(receipts[i])
 	}
 
 	return blockModel, nil
@@ -158,7 +201,25 @@ func (p *BlockProcessor) getBlockByNumber(ctx context.Context, number blockNumbe
 
 // storeData stores block data in the database
 func (p *BlockProcessor) storeData(ctx context.Context, data *model.Block) error {
-	return data.Save(ctx, p.dbClient)
+	ctx, end := pkg.StartDBWrite(ctx, "blocks")
+	err := data.Save(ctx, p.dbClient)
+	end(err)
+	return err
+}
+
+// quarantine stores a block that failed verification so it can be inspected
+// and re-enqueued later
+func (p *BlockProcessor) quarantine(ctx context.Context, block *types.Block, status string, reason error) error {
+	quarantined := &model.QuarantinedBlock{
+		Number: block.NumberU64(),
+		Hash:   block.Hash().Hex(),
+		Status: status,
+		Reason: reason.Error(),
+	}
+	ctx, end := pkg.StartDBWrite(ctx, "quarantined_blocks")
+	err := quarantined.Save(ctx, p.dbClient)
+	end(err)
+	return err
 }
 
 // acknowledge acknowledges the successful processing of a block
@@ -168,7 +229,7 @@ func (p *BlockProcessor) acknowledge(ctx context.Context, id string) error {
 
 func (p *BlockProcessor) sendTransactions(ctx context.Context, block *model.Block) error {
 	for _, tx := range block.Transactions {
-		_, err := p.txProducer.Add(ctx, tx.StreamValue())
+		_, err := p.txProducer.Add(ctx, pkg.InjectTraceContext(ctx, tx.StreamValue()))
 		if err != nil {
 			return err
 		}
@@ -186,6 +247,9 @@ func (p *BlockProcessor) Close() {
 	}
 	p.redisClient.Close()
 	p.ethClient.Close()
+	if p.verifyEthClient != nil {
+		p.verifyEthClient.Close()
+	}
 	p.dbClient.Close()
 	p.blockConsumer.Close()
 }
@@ -216,12 +280,71 @@ func (p *BlockProcessor) Start(ctx context.Context) {
 }
 
 func (p *BlockProcessor) process(ctx context.Context, record blockRecordDTO) {
-	block, err := p.getBlockByNumber(ctx, record.number)
+	ctx, span := pkg.StartSpan(trace.ContextWithRemoteSpanContext(ctx, record.spanContext), "block_processor.process", trace.WithAttributes(
+		attribute.String("block.number", string(record.number)),
+		attribute.String("block.status", record.status),
+	))
+	defer span.End()
+
+	ethBlock, err := p.getBlockByNumber(ctx, record.number)
 	if err != nil {
 		p.logger.Error().Err(err).Msg("failed to get block by number")
 		return
 	}
 
+	// the receipts are verified with the block and sent along with the
+	// transactions, so the tx processor doesn't fetch them again
+	receipts, err := p.getBlockReceipts(ctx, ethBlock)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to get block receipts")
+		return
+	}
+
+	err = p.verifyBlock(ctx, ethBlock, receipts)
+	if err != nil {
+		var verifyErr *verificationError
+		if !errors.As(err, &verifyErr) {
+			p.logger.Error().Err(err).Msg("failed to verify block")
+			return
+		}
+
+		p.logger.Warn().Err(err).Msgf("quarantining block %d %s", ethBlock.NumberU64(), ethBlock.Hash().Hex())
+		if err := p.quarantine(ctx, ethBlock, record.status, verifyErr); err != nil {
+			p.logger.Error().Err(err).Msg("failed to quarantine block")
+			return
+		}
+		blocksProcessed.WithLabelValues("quarantined").Inc()
+		p.acknowledge(ctx, record.id)
+		return
+	}
+
+	block, err := p.toBlockModel(ctx, ethBlock, receipts)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to convert block")
+		return
+	}
+
+	block.InternalTransactions, err = p.traceBlock(ctx, ethBlock)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to trace block")
+		return
+	}
+
+	block.Contracts = model.ToContractsFromInternalTransactions(block.InternalTransactions)
+	err = setBytecodeHashes(ctx, p.ethClient, block.Contracts)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to get contract bytecode")
+		return
+	}
+
+	if p.trackBalances {
+		block.BalanceChanges, err = model.ToBalanceChanges(ethBlock, block.Transactions, receipts, block.InternalTransactions)
+		if err != nil {
+			p.logger.Error().Err(err).Msg("failed to derive balance changes")
+			return
+		}
+	}
+
 	block.Status = record.status
 
 	err = p.storeData(ctx, block)
@@ -236,5 +359,6 @@ func (p *BlockProcessor) process(ctx context.Context, record blockRecordDTO) {
 		return
 	}
 
+	blocksProcessed.WithLabelValues("stored").Inc()
 	p.acknowledge(ctx, record.id)
 }
@@ -0,0 +1,54 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/korprulu/interview-homework-b/internal/model"
+)
+
+const (
+	// TraceModeDebug traces blocks with debug_traceBlockByHash and the
+	// callTracer, supported by geth, erigon, nethermind and reth
+	TraceModeDebug = "debug"
+	// TraceModeParity traces blocks with trace_block, supported by erigon,
+	// nethermind, reth and openethereum
+	TraceModeParity = "parity"
+)
+
+func validateTraceMode(mode string) error {
+	switch mode {
+	case "", TraceModeDebug, TraceModeParity:
+		return nil
+	default:
+		return fmt.Errorf("unknown trace mode %q", mode)
+	}
+}
+
+// traceBlock returns the flattened call trees of the block transactions, it
+// returns nil when tracing is disabled
+func (p *BlockProcessor) traceBlock(ctx context.Context, block *types.Block) (model.InternalTransactions, error) {
+	blockHash := block.Hash().Hex()
+	txHashes := make([]string, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		txHashes[i] = tx.Hash().Hex()
+	}
+
+	switch p.traceMode {
+	case TraceModeDebug:
+		traces, err := p.ethClient.TraceBlockCalls(ctx, block.Hash())
+		if err != nil {
+			return nil, err
+		}
+		return model.ToInternalTransactionsFromCallFrames(block.NumberU64(), blockHash, txHashes, traces)
+	case TraceModeParity:
+		traces, err := p.ethClient.TraceBlock(ctx, block.NumberU64())
+		if err != nil {
+			return nil, err
+		}
+		return model.ToInternalTransactionsFromParityTraces(block.NumberU64(), blockHash, txHashes, traces)
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// verificationError is returned when a block fails verification, the block
+// should be quarantined instead of stored
+type verificationError struct {
+	reasons []string
+}
+
+func (e *verificationError) Error() string {
+	return "block verification failed: " + strings.Join(e.reasons, "; ")
+}
+
+// compareHeaders returns the differences between two headers of the same
+// block number fetched from different providers
+func compareHeaders(primary, secondary *types.Header) []string {
+	var reasons []string
+	if primary.Hash() != secondary.Hash() {
+		reasons = append(reasons, fmt.Sprintf("hash mismatch: %s != %s", primary.Hash().Hex(), secondary.Hash().Hex()))
+	}
+	if primary.Root != secondary.Root {
+		reasons = append(reasons, fmt.Sprintf("stateRoot mismatch: %s != %s", primary.Root.Hex(), secondary.Root.Hex()))
+	}
+	if primary.ReceiptHash != secondary.ReceiptHash {
+		reasons = append(reasons, fmt.Sprintf("receiptsRoot mismatch: %s != %s", primary.ReceiptHash.Hex(), secondary.ReceiptHash.Hex()))
+	}
+	return reasons
+}
+
+// compareRoots recomputes the transactions and receipts trie roots and returns
+// the differences with the block header
+func compareRoots(block *types.Block, receipts types.Receipts) []string {
+	var reasons []string
+	if root := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); root != block.TxHash() {
+		reasons = append(reasons, fmt.Sprintf("transactionsRoot mismatch: computed %s, header %s", root.Hex(), block.TxHash().Hex()))
+	}
+	if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != block.ReceiptHash() {
+		reasons = append(reasons, fmt.Sprintf("receiptsRoot mismatch: computed %s, header %s", root.Hex(), block.ReceiptHash().Hex()))
+	}
+	return reasons
+}
+
+// verifyBlock runs the enabled verifications, a *verificationError is
+// returned when the block must not be stored, any other error when the block
+// can't be verified yet and must be retried. receipts are fetched by the
+// caller when the integrity check is enabled.
+func (p *BlockProcessor) verifyBlock(ctx context.Context, block *types.Block, receipts types.Receipts) error {
+	if err := p.verifyProviders(ctx, block); err != nil {
+		return err
+	}
+	return p.verifyIntegrity(block, receipts)
+}
+
+// verifyProviders compares the block header with the one returned by the
+// verification provider
+func (p *BlockProcessor) verifyProviders(ctx context.Context, block *types.Block) error {
+	if p.verifyEthClient == nil {
+		return nil
+	}
+
+	header, err := p.verifyEthClient.HeaderByNumber(ctx, block.Number())
+	if err != nil {
+		// the verification provider is usually a few blocks behind, the block
+		// is retried once it catches up
+		if errors.Is(err, ethereum.NotFound) {
+			return fmt.Errorf("block %d not found on verification provider yet: %w", block.NumberU64(), err)
+		}
+		return err
+	}
+
+	if reasons := compareHeaders(block.Header(), header); len(reasons) > 0 {
+		return &verificationError{reasons: reasons}
+	}
+
+	return nil
+}
+
+// verifyIntegrity checks the transactions and receipts returned by the
+// provider against the roots committed in the block header
+func (p *BlockProcessor) verifyIntegrity(block *types.Block, receipts types.Receipts) error {
+	if !p.integrityCheck {
+		return nil
+	}
+
+	if reasons := compareRoots(block, receipts); len(reasons) > 0 {
+		return &verificationError{reasons: reasons}
+	}
+
+	return nil
+}
+
+// getBlockReceipts returns the receipts of all transactions in the block
+func (p *BlockProcessor) getBlockReceipts(ctx context.Context, block *types.Block) (types.Receipts, error) {
+	hashes := make([]common.Hash, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		hashes[i] = tx.Hash()
+	}
+
+	results, err := p.ethClient.BatchTransactionReceipts(ctx, hashes...)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts := make(types.Receipts, len(results))
+	for i, r := range results {
+		if r.Err != nil {
+			return nil, fmt.Errorf("failed to get receipt of transaction %s: %w", hashes[i].Hex(), r.Err)
+		}
+		receipts[i] = r.Receipt
+	}
+
+	return receipts, nil
+}
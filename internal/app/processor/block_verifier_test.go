@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestCompareHeaders(t *testing.T) {
+	t.Parallel()
+
+	primary := &types.Header{
+		Number:      big.NewInt(1),
+		Root:        common.HexToHash("0x01"),
+		ReceiptHash: common.HexToHash("0x02"),
+		Difficulty:  big.NewInt(0),
+	}
+
+	same := types.CopyHeader(primary)
+	if reasons := compareHeaders(primary, same); len(reasons) != 0 {
+		t.Errorf("expected no differences, got %v", reasons)
+	}
+
+	divergent := types.CopyHeader(primary)
+	divergent.ReceiptHash = common.HexToHash("0x03")
+	reasons := compareHeaders(primary, divergent)
+	if len(reasons) != 2 {
+		t.Fatalf("expected hash and receiptsRoot differences, got %v", reasons)
+	}
+}
+
+func TestCompareRoots(t *testing.T) {
+	t.Parallel()
+
+	to := common.HexToAddress("0x01")
+	txs := types.Transactions{
+		types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1), nil),
+		types.NewTransaction(1, to, big.NewInt(2), 21000, big.NewInt(1), nil),
+	}
+	receipts := types.Receipts{
+		{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000, Logs: []*types.Log{}},
+		{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 42000, Logs: []*types.Log{}},
+	}
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(0)}
+	block := types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
+
+	if reasons := compareRoots(block, receipts); len(reasons) != 0 {
+		t.Errorf("expected no differences, got %v", reasons)
+	}
+
+	tampered := types.Receipts{receipts[0], {Status: types.ReceiptStatusFailed, CumulativeGasUsed: 42000, Logs: []*types.Log{}}}
+	if reasons := compareRoots(block, tampered); len(reasons) != 1 {
+		t.Errorf("expected a receiptsRoot difference, got %v", reasons)
+	}
+}
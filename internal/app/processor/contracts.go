@@ -0,0 +1,30 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/korprulu/interview-homework-b/internal/model"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+// setBytecodeHashes fetches the code of the created contracts with eth_getCode
+// and sets their bytecode hashes. The hash is left empty, stored as NULL, when
+// the provider pruned the state of the block, any other error is returned so
+// the block is retried.
+func setBytecodeHashes(ctx context.Context, ethClient *pkg.EthClient, contracts model.Contracts) error {
+	for _, contract := range contracts {
+		hash, err := ethClient.CodeHashAt(ctx, common.HexToAddress(contract.Address), new(big.Int).SetUint64(contract.BlockNumber))
+		if errors.Is(err, pkg.ErrStateUnavailable) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get code of contract %s: %w", contract.Address, err)
+		}
+		contract.BytecodeHash = hash.Hex()
+	}
+	return nil
+}
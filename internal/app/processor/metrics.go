@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+)
+
+var (
+	blocksProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "indexer",
+		Name:      "blocks_processed_total",
+		Help:      "Blocks processed by the block processor, by result.",
+	}, []string{"result"})
+	transactionsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "indexer",
+		Name:      "transactions_processed_total",
+		Help:      "Transactions processed by the transaction processor, by result.",
+	}, []string{"result"})
+)
+
+// headLagCollector reports how far the indexed blocks are behind the chain
+// when scraped
+type headLagCollector struct {
+	dbClient  *pkg.DBClient
+	ethClient *pkg.EthClient
+	logger    *zerolog.Logger
+
+	chainHead   *prometheus.Desc
+	indexedHead *prometheus.Desc
+	lag         *prometheus.Desc
+}
+
+// NewHeadLagCollector creates a collector of the chain head, the indexed head
+// and the lag between them
+func NewHeadLagCollector(dbClient *pkg.DBClient, ethClient *pkg.EthClient, logger *zerolog.Logger) prometheus.Collector {
+	return &headLagCollector{
+		dbClient:    dbClient,
+		ethClient:   ethClient,
+		logger:      logger,
+		chainHead:   prometheus.NewDesc("indexer_chain_head_block", "Latest block number of the chain.", nil, nil),
+		indexedHead: prometheus.NewDesc("indexer_indexed_head_block", "Latest indexed block number.", nil, nil),
+		lag:         prometheus.NewDesc("indexer_head_lag_blocks", "Blocks the indexed head is behind the chain head.", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *headLagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.chainHead
+	ch <- c.indexedHead
+	ch <- c.lag
+}
+
+// Collect implements prometheus.Collector
+func (c *headLagCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	chainHead, err := c.ethClient.BlockNumber(ctx)
+	if err != nil {
+		c.logger.Warn().Err(err).Msg("failed to collect chain head")
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.chainHead, prometheus.GaugeValue, float64(chainHead))
+
+	var indexedHead uint64
+	err = c.dbClient.QueryRowContext(ctx, "SELECT COALESCE(MAX(number), 0) FROM blocks WHERE is_uncle = false").Scan(&indexedHead)
+	if err != nil {
+		c.logger.Warn().Err(err).Msg("failed to collect indexed head")
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.indexedHead, prometheus.GaugeValue, float64(indexedHead))
+	ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(chainHead)-float64(indexedHead))
+}
@@ -6,12 +6,18 @@ import (
 	"os"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/korprulu/interview-homework-b/internal/model"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// deadLetterSuffix names the stream of the messages of a stream that can't be
+// decoded
+const deadLetterSuffix = ":dead"
+
 type (
 	// TxProcessor processes blocks
 	TxProcessor struct {
@@ -50,6 +56,9 @@ type (
 	txRecordDTO struct {
 		id    string
 		model *model.Transaction
+		// spanContext is the span of the block processor that sent the
+		// transaction
+		spanContext trace.SpanContext
 	}
 )
 
@@ -67,6 +76,7 @@ func NewTxProcessor(ctx context.Context, config TxProcessorConfig) (*TxProcessor
 		StreamName:   config.TxConsumerSteamName,
 		GroupName:    config.TxConsumerGroupName,
 		ConsumerName: consumerName,
+		ClaimMinIdle: retryIdle,
 	})
 	if err != nil {
 		return nil, err
@@ -109,47 +119,93 @@ func (p *TxProcessor) fetchRecords(ctx context.Context) <-chan []txRecordDTO {
 
 				txRecordDTOs := make([]txRecordDTO, 0, len(messages))
 				for _, message := range messages {
-					nounce, err := hexutil.DecodeUint64(message.Values["nonce"].(string))
-					if err != nil {
-						p.logger.Error().Err(err).Msgf("failed to decode nonce %v", message.Values["nonce"])
-					}
-					blockNumber, err := hexutil.DecodeUint64(message.Values["block_number"].(string))
+					tx, err := model.ToTransactionFromStreamValue(message.Values)
 					if err != nil {
-						p.logger.Error().Err(err).Msgf("failed to decode block number %v", message.Values["block_number"])
+						p.logger.Error().Err(err).Msgf("failed to decode transaction message %s", message.ID)
+						if err := p.deadLetter(ctx, message, err); err != nil {
+							p.logger.Error().Err(err).Msgf("failed to dead letter transaction message %s", message.ID)
+						}
+						continue
 					}
 					txRecordDTOs = append(txRecordDTOs, txRecordDTO{
-						id: message.ID,
-						model: &model.Transaction{
-							Hash:        message.Values["tx_hash"].(string),
-							From:        message.Values["from"].(string),
-							To:          message.Values["to"].(string),
-							Nonce:       nounce,
-							Data:        message.Values["data"].(string),
-							Value:       message.Values["value"].(string),
-							BlockHash:   message.Values["block_hash"].(string),
-							BlockNumber: blockNumber,
-						},
+						id:          message.ID,
+						model:       tx,
+						spanContext: trace.SpanContextFromContext(pkg.ExtractTraceContext(ctx, message.Values)),
 					})
 				}
-				ch <- txRecordDTOs
+				if len(txRecordDTOs) > 0 {
+					ch <- txRecordDTOs
+				}
 			}
 		}
 	}()
 	return ch
 }
 
-func (p *TxProcessor) getTxReceipts(ctx context.Context, hash ...string) ([]pkg.BatchTransctionReceiptsResult, error) {
-	txHashes := make([]common.Hash, len(hash))
-	for i, h := range hash {
-		txHashes[i] = common.HexToHash(h)
+// setMissingReceipts fetches the receipts of the transactions sent without
+// one, by a block processor older than the receipts in the stream messages.
+// It returns which transactions failed to get a receipt.
+func (p *TxProcessor) setMissingReceipts(ctx context.Context, txs model.Transactions) ([]bool, error) {
+	failed := make([]bool, len(txs))
+
+	var missing model.Transactions
+	var hashes []common.Hash
+	for _, tx := range txs {
+		if !tx.HasReceipt() {
+			missing = append(missing, tx)
+			hashes = append(hashes, common.HexToHash(tx.Hash))
+		}
+	}
+	if len(missing) == 0 {
+		return failed, nil
+	}
+
+	receipts, err := p.ethClient.BatchTransactionReceipts(ctx, hashes...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, r := range receipts {
+		if r.Err != nil {
+			p.logger.Error().Err(r.Err).Msgf("failed to get receipt in transaction %s", missing[i].Hash)
+			continue
+		}
+		missing[i].SetReceipt(r.Receipt)
+	}
+	for i, tx := range txs {
+		failed[i] = !tx.HasReceipt()
 	}
+	return failed, nil
+}
 
-	return p.ethClient.BatchTransactionReceipts(ctx, txHashes...)
+// storeData stores the transactions with their contracts and token transfers
+// in the database
+func (p *TxProcessor) storeData(ctx context.Context, data *model.TransactionBatch) error {
+	ctx, end := pkg.StartDBWrite(ctx, "transactions")
+	err := data.Save(ctx, p.dbClient)
+	end(err)
+	return err
 }
 
-// storeData stores block data in the database
-func (p *TxProcessor) storeData(ctx context.Context, data model.Transactions) error {
-	return data.Save(ctx, p.dbClient)
+// deadLetter moves a message that can't be decoded to the dead letter stream,
+// where it is kept for inspection instead of being claimed again forever
+func (p *TxProcessor) deadLetter(ctx context.Context, message pkg.StreamMessage, reason error) error {
+	values := make(map[string]any, len(message.Values)+2)
+	for k, v := range message.Values {
+		values[k] = v
+	}
+	values["message_id"] = message.ID
+	values["error"] = reason.Error()
+
+	err := p.redisClient.XAdd(ctx, &goredis.XAddArgs{
+		Stream: p.txConsumerStreamName + deadLetterSuffix,
+		Values: values,
+	}).Err()
+	if err != nil {
+		return err
+	}
+	transactionsProcessed.WithLabelValues("dead_lettered").Inc()
+	return p.acknowledge(ctx, message.ID)
 }
 
 // acknowledge acknowledges the successful processing of a block
@@ -193,37 +249,73 @@ func (p *TxProcessor) Start(ctx context.Context) {
 	}
 }
 
+// batchSpanContext returns the parent of the span of a batch, the span of
+// its first traced record, and links to the spans of the other blocks
+func batchSpanContext(records []txRecordDTO) (trace.SpanContext, []trace.Link) {
+	var parent trace.SpanContext
+	var links []trace.Link
+	seen := map[trace.SpanID]bool{}
+	for _, r := range records {
+		if !r.spanContext.IsValid() || seen[r.spanContext.SpanID()] {
+			continue
+		}
+		seen[r.spanContext.SpanID()] = true
+		if !parent.IsValid() {
+			parent = r.spanContext
+			continue
+		}
+		links = append(links, trace.Link{SpanContext: r.spanContext})
+	}
+	return parent, links
+}
+
 func (p *TxProcessor) process(ctx context.Context, records []txRecordDTO) {
-	hashes := make([]string, len(records))
+	// a batch holds transactions of several blocks, so of several traces
+	parent, links := batchSpanContext(records)
+	ctx, span := pkg.StartSpan(trace.ContextWithRemoteSpanContext(ctx, parent), "tx_processor.process",
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("transactions.count", len(records))),
+	)
+	defer span.End()
+
 	models := make(model.Transactions, len(records))
 	for i, r := range records {
-		hashes[i] = r.model.Hash
 		models[i] = r.model
 	}
 
-	receipts, err := p.getTxReceipts(ctx, hashes...)
+	failed, err := p.setMissingReceipts(ctx, models)
 	if err != nil {
 		p.logger.Error().Err(err).Msg("failed to batch get transaction receipts")
 		return
 	}
 
-	for i, r := range receipts {
-		if r.Err != nil {
-			p.logger.Error().Err(r.Err).Msgf("failed to get receipt in transaction %s", hashes[i])
+	// transactions without a receipt are retried
+	var stored model.Transactions
+	var contracts model.Contracts
+	for i, tx := range models {
+		if failed[i] {
 			continue
 		}
-
-		logs := make([]model.TransactionLog, len(r.Receipt.Logs))
-		for j, l := range r.Receipt.Logs {
-			logs[j] = model.TransactionLog{
-				Index: l.Index,
-				Data:  hexutil.Encode(l.Data),
-			}
+		stored = append(stored, tx)
+		if tx.ContractAddress == "" {
+			continue
 		}
-		models[i].Logs = logs
+		contracts = append(contracts, &model.Contract{
+			Address:        tx.ContractAddress,
+			Creator:        tx.From,
+			CreationTxHash: tx.Hash,
+			BlockNumber:    tx.BlockNumber,
+			BlockHash:      tx.BlockHash,
+		})
+	}
+
+	err = setBytecodeHashes(ctx, p.ethClient, contracts)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to get contract bytecode")
+		return
 	}
 
-	err = p.storeData(ctx, models)
+	err = p.storeData(ctx, &model.TransactionBatch{Transactions: stored, Contracts: contracts})
 	if err != nil {
 		p.logger.Error().Err(err).Msg("failed to store data")
 		return
@@ -232,8 +324,11 @@ func (p *TxProcessor) process(ctx context.Context, records []txRecordDTO) {
 	for i, r := range records {
 		// TODO handles different types of errors, some errors, we may need to
 		// retry, some errors may not.
-		if receipts[i].Err == nil {
+		if !failed[i] {
+			transactionsProcessed.WithLabelValues("stored").Inc()
 			p.acknowledge(ctx, r.id)
+		} else {
+			transactionsProcessed.WithLabelValues("failed").Inc()
 		}
 	}
 }
@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestBatchSpanContext(t *testing.T) {
+	t.Parallel()
+
+	spanContext := func(span byte) trace.SpanContext {
+		return trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{span},
+			SpanID:     trace.SpanID{span},
+			TraceFlags: trace.FlagsSampled,
+		})
+	}
+
+	records := []txRecordDTO{
+		{id: "untraced"},
+		{id: "1-0", spanContext: spanContext(1)},
+		{id: "1-1", spanContext: spanContext(1)},
+		{id: "2-0", spanContext: spanContext(2)},
+		{id: "3-0", spanContext: spanContext(3)},
+	}
+
+	parent, links := batchSpanContext(records)
+	if !parent.Equal(spanContext(1)) {
+		t.Errorf("Expected the first traced record as parent, got %v", parent)
+	}
+	if len(links) != 2 || !links[0].SpanContext.Equal(spanContext(2)) || !links[1].SpanContext.Equal(spanContext(3)) {
+		t.Errorf("Expected links to the other blocks, got %v", links)
+	}
+
+	if parent, links := batchSpanContext([]txRecordDTO{{id: "untraced"}}); parent.IsValid() || len(links) != 0 {
+		t.Errorf("Expected no parent, got %v %v", parent, links)
+	}
+}
@@ -7,7 +7,11 @@ import (
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Scanner scan blocks from startBlockNumber to latest block number
@@ -95,6 +99,19 @@ func (s *Scanner) Close() {
 	s.ethClient.Close()
 }
 
+var (
+	scannerHead = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "indexer",
+		Name:      "scanner_head_block",
+		Help:      "Latest block number enqueued by the scanner.",
+	})
+	blocksEnqueued = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "indexer",
+		Name:      "blocks_enqueued_total",
+		Help:      "Blocks added to the block stream by the scanner.",
+	})
+)
+
 func (s *Scanner) blockNumber(ctx context.Context) (uint64, error) {
 	return s.ethClient.BlockNumber(ctx)
 }
@@ -116,13 +133,23 @@ func (s *Scanner) produce(ctx context.Context, startNumber, lastNumber uint64) {
 			if i+uint64(s.reorgCheckCount) >= lastNumber {
 				status = "unfinalized"
 			}
-			_, err := s.blockProducer.Add(ctx, pkg.StreamValue{
+			// every block starts a trace, carried in the stream message
+			// through the processors
+			enqueueCtx, span := pkg.StartSpan(ctx, "scanner.enqueue", trace.WithAttributes(
+				attribute.Int64("block.number", int64(i)),
+				attribute.String("block.status", status),
+			))
+			_, err := s.blockProducer.Add(enqueueCtx, pkg.InjectTraceContext(enqueueCtx, pkg.StreamValue{
 				"number": num,
 				"status": status,
-			})
+			}))
+			pkg.EndSpan(span, err)
 			if err != nil {
 				s.logger.Error().Err(err).Msgf("failed to add block %s to stream", num)
+				continue
 			}
+			blocksEnqueued.Inc()
+			scannerHead.Set(float64(i))
 		}
 	}
 }
@@ -9,9 +9,24 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/korprulu/interview-homework-b/internal/model"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rs/zerolog"
 )
 
+var (
+	reorgs = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "indexer",
+		Name:      "reorgs_total",
+		Help:      "Indexed blocks found replaced on the chain and marked as uncles.",
+	})
+	blocksFinalized = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "indexer",
+		Name:      "blocks_finalized_total",
+		Help:      "Unfinalized blocks confirmed by the validator.",
+	})
+)
+
 // Validator checks if the block has become an uncle block
 type Validator struct {
 	dbClient    *pkg.DBClient
@@ -20,6 +35,7 @@ type Validator struct {
 	logger      *zerolog.Logger
 
 	blockProducer pkg.StreamProducer
+	responseCache *pkg.ResponseCache
 
 	blockStreamName string
 	reorgCheckCount int
@@ -57,6 +73,7 @@ func NewValidator(ctx context.Context, cfg Config) (*Validator, error) {
 		blockStreamName: cfg.BlockStreamName,
 		reorgCheckCount: cfg.ReorgCheckCount,
 		blockProducer:   producer,
+		responseCache:   pkg.NewResponseCache(cfg.RedisClient),
 		watchInterval:   time.Duration(cfg.WatchIntervalSecs) * time.Second,
 	}, nil
 }
@@ -143,6 +160,12 @@ func (v *Validator) validateBlock(ctx context.Context, blocks []*model.Block) (f
 	}
 
 	for i, header := range headers {
+		// a header that failed, like one still throttled after the retries,
+		// leaves its block unfinalized until the next check
+		if header.Err != nil {
+			v.logger.Warn().Err(header.Err).Msgf("failed to get header of block %d", blocks[i].Number)
+			continue
+		}
 		if header.Header.Hash().Hex() == blocks[i].Hash {
 			finalizedBlocks = append(finalizedBlocks, blocks[i])
 		} else {
@@ -153,7 +176,10 @@ func (v *Validator) validateBlock(ctx context.Context, blocks []*model.Block) (f
 	return finalizedBlocks, uncleBlocks, nil
 }
 
-func (v *Validator) updateFinalizedBlocks(ctx context.Context, blocks []*model.Block) error {
+func (v *Validator) updateFinalizedBlocks(ctx context.Context, blocks []*model.Block) (err error) {
+	ctx, end := pkg.StartDBWrite(ctx, "finalize_blocks")
+	defer func() { end(err) }()
+
 	stmt, err := v.dbClient.PrepareContext(ctx, "UPDATE blocks SET status = 'finalized' WHERE number = $1 AND hash = $2 AND status = 'unfinalized'")
 	if err != nil {
 		return err
@@ -165,39 +191,66 @@ func (v *Validator) updateFinalizedBlocks(ctx context.Context, blocks []*model.B
 		if err != nil {
 			return err
 		}
+		blocksFinalized.Inc()
 	}
 
 	return nil
 }
 
 func (v *Validator) reorgUncleBlocks(ctx context.Context, uncleBlocks []*model.Block) error {
-	for _, model := range uncleBlocks {
+	for _, block := range uncleBlocks {
+		ctx, end := pkg.StartDBWrite(ctx, "reorg_block")
 		tx, err := v.dbClient.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
 		if err != nil {
+			end(err)
 			return err
 		}
 
-		_, err = tx.ExecContext(ctx, "UPDATE blocks SET status = 'finalized', is_uncle = true WHERE number = $1 AND hash = $2 AND status = 'unfinalized'", model.Number, model.Hash)
+		result, err := tx.ExecContext(ctx, "UPDATE blocks SET status = 'finalized', is_uncle = true WHERE number = $1 AND hash = $2 AND status = 'unfinalized'", block.Number, block.Hash)
 		if err != nil {
 			if rollbackErr := tx.Rollback(); rollbackErr != nil {
 				v.logger.Error().Err(rollbackErr).Msg("failed to rollback")
 			}
+			end(err)
 			return err
 		}
 
-		_, err = v.blockProducer.Add(ctx, pkg.StreamValue{
-			"number": hexutil.EncodeUint64(model.Number),
+		// the token balances are reverted only by the update that marks the
+		// block, so they are never reverted twice
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			if err := model.ReverseTokenTransfers(ctx, tx, block.Hash); err != nil {
+				if rollbackErr := tx.Rollback(); rollbackErr != nil {
+					v.logger.Error().Err(rollbackErr).Msg("failed to rollback")
+				}
+				end(err)
+				return err
+			}
+		}
+
+		_, err = v.blockProducer.Add(ctx, pkg.InjectTraceContext(ctx, pkg.StreamValue{
+			"number": hexutil.EncodeUint64(block.Number),
 			"status": "finalized",
-		})
+		}))
 		if err != nil {
 			if rollbackErr := tx.Rollback(); rollbackErr != nil {
 				v.logger.Error().Err(rollbackErr).Msg("failed to rollback")
 			}
+			end(err)
 			return err
 		}
 
-		if err := tx.Commit(); err != nil {
+		err = tx.Commit()
+		end(err)
+		if err != nil {
 			v.logger.Error().Err(err).Msg("failed to commit")
+			continue
+		}
+		reorgs.Inc()
+
+		// the API caches responses of unfinalized blocks briefly, drop the
+		// ones of the replaced block
+		if err := v.responseCache.InvalidateBlock(ctx, block.Hash); err != nil {
+			v.logger.Error().Err(err).Str("hash", block.Hash).Msg("failed to invalidate cached responses")
 		}
 	}
 
@@ -25,7 +25,14 @@ type Redis struct {
 
 // Ethereum ...
 type Ethereum struct {
-	URL string `env:"ETHEREUM_RPC_URL" env-default:"http://localhost:8545"`
+	URL          string  `env:"ETHEREUM_RPC_URL" env-default:"http://localhost:8545"`
+	RateLimit    float64 `env:"ETHEREUM_RPC_RATE_LIMIT" env-default:"0"`
+	RateBurst    int     `env:"ETHEREUM_RPC_RATE_BURST" env-default:"10"`
+	MaxBatchSize int     `env:"ETHEREUM_RPC_MAX_BATCH_SIZE" env-default:"100"`
+
+	// VerificationURL is an optional second provider used to cross-check
+	// block headers in the block processor
+	VerificationURL string `env:"ETHEREUM_VERIFICATION_RPC_URL"`
 }
 
 // BlockProcessor ...
@@ -34,6 +41,9 @@ type BlockProcessor struct {
 	ConsumerGroup         string `env:"BLOCK_PROCESSOR_CONSUMER_GROUP" env-default:"block-processors"`
 	TransactionStreamName string `env:"TRANSACTION_STREAM_NAME" env-default:"transactions"`
 	ConcurrentCount       int    `env:"BLOCK_PROCESSOR_CONCURRENT_COUNT" env-default:"10"`
+	VerifyIntegrity       bool   `env:"BLOCK_PROCESSOR_VERIFY_INTEGRITY" env-default:"false"`
+	TraceMode             string `env:"BLOCK_PROCESSOR_TRACE_MODE"`
+	TrackBalances         bool   `env:"BLOCK_PROCESSOR_TRACK_BALANCES" env-default:"false"`
 }
 
 // TransactionProcessor ...
@@ -59,9 +69,42 @@ type Validator struct {
 	WatchIntervalSecs int    `env:"VALIDATOR_WATCH_INTERVAL_SECONDS" env-default:"300"`
 }
 
+// TokenEnricher ...
+type TokenEnricher struct {
+	BatchSize         int `env:"TOKEN_ENRICHER_BATCH_SIZE" env-default:"50"`
+	WatchIntervalSecs int `env:"TOKEN_ENRICHER_WATCH_INTERVAL_SECONDS" env-default:"60"`
+}
+
+// WebhookDispatcher ...
+type WebhookDispatcher struct {
+	BatchSize         int    `env:"WEBHOOK_DISPATCHER_BATCH_SIZE" env-default:"100"`
+	LookbackBlocks    uint64 `env:"WEBHOOK_DISPATCHER_LOOKBACK_BLOCKS" env-default:"100"`
+	MaxAttempts       int    `env:"WEBHOOK_DISPATCHER_MAX_ATTEMPTS" env-default:"10"`
+	TimeoutSecs       int    `env:"WEBHOOK_DISPATCHER_TIMEOUT_SECONDS" env-default:"10"`
+	WatchIntervalSecs int    `env:"WEBHOOK_DISPATCHER_WATCH_INTERVAL_SECONDS" env-default:"10"`
+}
+
 // API ...
 type API struct {
-	Port string `env:"API_PORT" env-default:"8080"`
+	Port                    string `env:"API_PORT" env-default:"8080"`
+	GraphQLMaxDepth         int    `env:"GRAPHQL_MAX_DEPTH" env-default:"8"`
+	GraphQLMaxComplexity    int    `env:"GRAPHQL_MAX_COMPLEXITY" env-default:"1000"`
+	AuthEnabled             bool   `env:"API_AUTH_ENABLED" env-default:"true"`
+	CacheEnabled            bool   `env:"API_CACHE_ENABLED" env-default:"true"`
+	CacheUnfinalizedTTLSecs int    `env:"API_CACHE_UNFINALIZED_TTL_SECONDS" env-default:"2"`
+}
+
+// Ops ...
+type Ops struct {
+	Port                  string `env:"OPS_HTTP_PORT" env-default:"9090"`
+	ReadyMaxHeadLagBlocks uint64 `env:"OPS_READY_MAX_HEAD_LAG_BLOCKS" env-default:"100"`
+	ReadyMaxStreamLag     int64  `env:"OPS_READY_MAX_STREAM_LAG" env-default:"10000"`
+}
+
+// Tracing ...
+type Tracing struct {
+	Exporter    string  `env:"TRACING_EXPORTER" env-default:"none"`
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1"`
 }
 
 // Config ...
@@ -73,7 +116,11 @@ type Config struct {
 	TransactionProcessor TransactionProcessor
 	Scanner              Scanner
 	Validator            Validator
+	TokenEnricher        TokenEnricher
+	WebhookDispatcher    WebhookDispatcher
 	API                  API
+	Ops                  Ops
+	Tracing              Tracing
 }
 
 var config *Config
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+// apiKeyPrefix starts every API key so leaked keys are easy to search for
+const apiKeyPrefix = "bik_"
+
+// ErrAPIKeyNotFound is returned when an API key doesn't exist or was revoked
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey is a key of the API, only the hash of the key is stored
+type APIKey struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Prefix is the start of the key, it tells keys apart without the key
+	Prefix string `json:"prefix"`
+	// RateLimit is the requests per second refilling the token bucket of
+	// the key, Burst is the size of the bucket
+	RateLimit float64 `json:"rate_limit"`
+	Burst     int     `json:"burst"`
+	// DailyQuota is the requests allowed per UTC day, 0 is unlimited
+	DailyQuota int64      `json:"daily_quota"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// HashAPIKey returns the hex sha256 hash a key is stored and looked up by
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIKey generates a key and stores the hash of it, the key itself is only
+// returned here
+func NewAPIKey(ctx context.Context, db *pkg.DBClient, apiKey *APIKey) (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+	apiKey.Prefix = key[:len(apiKeyPrefix)+8]
+
+	row := db.QueryRowContext(ctx, "INSERT INTO api_keys (name, key_hash, prefix, rate_limit, burst, daily_quota) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		apiKey.Name, HashAPIKey(key), apiKey.Prefix, apiKey.RateLimit, apiKey.Burst, apiKey.DailyQuota)
+	if err := row.Scan(&apiKey.ID, &apiKey.CreatedAt); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// apiKeyColumns are the columns scanned by scanAPIKey
+const apiKeyColumns = "id, name, prefix, rate_limit, burst, daily_quota, created_at, revoked_at"
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKey, error) {
+	var apiKey APIKey
+	var revokedAt sql.NullTime
+	if err := row.Scan(&apiKey.ID, &apiKey.Name, &apiKey.Prefix, &apiKey.RateLimit, &apiKey.Burst, &apiKey.DailyQuota, &apiKey.CreatedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		apiKey.RevokedAt = &revokedAt.Time
+	}
+	return &apiKey, nil
+}
+
+// GetAPIKey returns the active key of a key, ErrAPIKeyNotFound is returned
+// for an unknown or revoked key
+func GetAPIKey(ctx context.Context, db *pkg.DBClient, key string) (*APIKey, error) {
+	row := db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL", HashAPIKey(key))
+	apiKey, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return apiKey, err
+}
+
+// ListAPIKeys returns every key including the revoked ones
+func ListAPIKeys(ctx context.Context, db *pkg.DBClient) ([]*APIKey, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apiKeys []*APIKey
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys, rows.Err()
+}
+
+// RevokeAPIKey revokes a key by id, ErrAPIKeyNotFound is returned when there
+// is no active key with the id
+func RevokeAPIKey(ctx context.Context, db *pkg.DBClient, id int64) error {
+	result, err := db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
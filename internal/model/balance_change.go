@@ -0,0 +1,147 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// BalanceChange is the net change of the ETH balance of an address in a block
+type BalanceChange struct {
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	Address     string `json:"address"`
+	// Delta is the signed change in wei
+	Delta string `json:"delta"`
+}
+
+// BalanceChanges is a slice of BalanceChange
+type BalanceChanges []*BalanceChange
+
+// valueTransferTypes are the internal transaction types that move ETH, the
+// value of DELEGATECALL and CALLCODE frames stays in the calling contract
+var valueTransferTypes = map[string]bool{
+	"CALL":         true,
+	"CREATE":       true,
+	"CREATE2":      true,
+	"SELFDESTRUCT": true,
+}
+
+// ToBalanceChanges derives the balance changes of a block from its
+// transactions, receipts and withdrawals. Value transfers are taken from the
+// internal transactions when the block was traced, otherwise only the top
+// level value of successful transactions is counted. Block and uncle rewards
+// paid before the merge are not included.
+func ToBalanceChanges(ethBlock *types.Block, txs Transactions, receipts types.Receipts, itxs InternalTransactions) (BalanceChanges, error) {
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("got %d receipts for %d transactions", len(receipts), len(txs))
+	}
+
+	deltas := make(map[string]*big.Int)
+	add := func(address string, amount *big.Int) {
+		if address == "" || amount.Sign() == 0 {
+			return
+		}
+		if _, ok := deltas[address]; !ok {
+			deltas[address] = new(big.Int)
+		}
+		deltas[address].Add(deltas[address], amount)
+	}
+	transfer := func(from, to string, amount *big.Int) {
+		add(from, new(big.Int).Neg(amount))
+		add(to, amount)
+	}
+
+	miner := ethBlock.Coinbase().Hex()
+	baseFee := ethBlock.BaseFee()
+	for i, tx := range txs {
+		if tx == nil {
+			return nil, fmt.Errorf("missing transaction at index %d", i)
+		}
+		receipt := receipts[i]
+
+		gasPrice, ok := new(big.Int).SetString(tx.GasPrice, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid gas price %q of transaction %s", tx.GasPrice, tx.Hash)
+		}
+		gasUsed := new(big.Int).SetUint64(receipt.GasUsed)
+
+		fee := new(big.Int).Mul(gasUsed, gasPrice)
+		if receipt.BlobGasPrice != nil {
+			fee.Add(fee, new(big.Int).Mul(new(big.Int).SetUint64(receipt.BlobGasUsed), receipt.BlobGasPrice))
+		}
+		add(tx.From, new(big.Int).Neg(fee))
+
+		tip := gasPrice
+		if baseFee != nil {
+			tip = new(big.Int).Sub(gasPrice, baseFee)
+		}
+		add(miner, tip.Mul(tip, gasUsed))
+
+		if itxs != nil || receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		value, ok := new(big.Int).SetString(tx.Value, 10)
+		if !ok {
+			continue
+		}
+		to := tx.To
+		if to == "" {
+			to = receipt.ContractAddress.Hex()
+		}
+		transfer(tx.From, to, value)
+	}
+
+	for _, itx := range itxs.Successful() {
+		if !valueTransferTypes[itx.Type] {
+			continue
+		}
+		value, ok := new(big.Int).SetString(itx.Value, 10)
+		if !ok {
+			continue
+		}
+		transfer(itx.From, itx.To, value)
+	}
+
+	for _, w := range ethBlock.Withdrawals() {
+		amount := new(big.Int).SetUint64(w.Amount)
+		add(w.Address.Hex(), amount.Mul(amount, big.NewInt(params.GWei)))
+	}
+
+	changes := make(BalanceChanges, 0, len(deltas))
+	for address, delta := range deltas {
+		if delta.Sign() == 0 {
+			continue
+		}
+		changes = append(changes, &BalanceChange{
+			BlockNumber: ethBlock.NumberU64(),
+			BlockHash:   ethBlock.Hash().Hex(),
+			Address:     address,
+			Delta:       delta.String(),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Address < changes[j].Address
+	})
+
+	return changes, nil
+}
+
+// balanceChangeColumns are the columns written by BalanceChanges.save
+var balanceChangeColumns = []string{"block_number", "block_hash", "address", "delta"}
+
+func (bcs BalanceChanges) save(ctx context.Context, tx *sql.Tx) error {
+	rows := make([][]any, len(bcs))
+	for i, bc := range bcs {
+		rows[i] = []any{bc.BlockNumber, bc.BlockHash, bc.Address, bc.Delta}
+	}
+	if err := batchInsert(ctx, tx, "address_balance_changes", balanceChangeColumns, rows, nil); err != nil {
+		return fmt.Errorf("failed to save balance changes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestToBalanceChanges(t *testing.T) {
+	t.Parallel()
+
+	sender := common.HexToAddress("0x01").Hex()
+	receiver := common.HexToAddress("0x02").Hex()
+	contract := common.HexToAddress("0x03").Hex()
+	miner := common.HexToAddress("0x0f")
+	validator := common.HexToAddress("0x04")
+
+	ethBlock := types.NewBlockWithHeader(&types.Header{
+		Number:   big.NewInt(1),
+		Coinbase: miner,
+		BaseFee:  big.NewInt(10),
+	}).WithWithdrawals([]*types.Withdrawal{{Address: validator, Amount: 2}})
+
+	txs := Transactions{
+		{Hash: "0xaa", From: sender, To: receiver, Value: "1000", GasPrice: "12"},
+		{Hash: "0xbb", From: sender, To: contract, Value: "500", GasPrice: "11"},
+	}
+	receipts := types.Receipts{
+		{Status: types.ReceiptStatusSuccessful, GasUsed: 21000},
+		{Status: types.ReceiptStatusFailed, GasUsed: 30000},
+	}
+
+	t.Run("top level transfers", func(t *testing.T) {
+		t.Parallel()
+
+		changes, err := ToBalanceChanges(ethBlock, txs, receipts, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]string{
+			sender:          "-583000",
+			receiver:        "1000",
+			miner.Hex():     "72000",
+			validator.Hex(): "2000000000",
+		}
+		if len(changes) != len(expected) {
+			t.Fatalf("Expected %d changes, got %d", len(expected), len(changes))
+		}
+		for _, change := range changes {
+			if expected[change.Address] != change.Delta {
+				t.Errorf("Expected delta of %s to be %s, got %s", change.Address, expected[change.Address], change.Delta)
+			}
+		}
+	})
+
+	t.Run("internal transfers", func(t *testing.T) {
+		t.Parallel()
+
+		itxs := InternalTransactions{
+			{TxHash: "0xaa", TraceAddress: TraceAddress{}, Type: "CALL", From: sender, To: receiver, Value: "1000"},
+			{TxHash: "0xaa", TraceAddress: TraceAddress{0}, Type: "CALL", From: receiver, To: contract, Value: "400"},
+			{TxHash: "0xaa", TraceAddress: TraceAddress{1}, Type: "DELEGATECALL", From: receiver, To: contract, Value: "1000"},
+			{TxHash: "0xbb", TraceAddress: TraceAddress{}, Type: "CALL", From: sender, To: contract, Value: "500", Error: "execution reverted"},
+		}
+
+		changes, err := ToBalanceChanges(ethBlock, txs, receipts, itxs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]string{
+			sender:          "-583000",
+			receiver:        "600",
+			contract:        "400",
+			miner.Hex():     "72000",
+			validator.Hex(): "2000000000",
+		}
+		if len(changes) != len(expected) {
+			t.Fatalf("Expected %d changes, got %d", len(expected), len(changes))
+		}
+		for _, change := range changes {
+			if expected[change.Address] != change.Delta {
+				t.Errorf("Expected delta of %s to be %s, got %s", change.Address, expected[change.Address], change.Delta)
+			}
+		}
+	})
+}
@@ -2,7 +2,11 @@ package model
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
 )
@@ -16,21 +20,242 @@ type Block struct {
 	Status     string `json:"status"`
 	IsUncle    bool   `json:"is_uncle"`
 
+	Miner                 string  `json:"miner"`
+	GasUsed               uint64  `json:"gas_used"`
+	GasLimit              uint64  `json:"gas_limit"`
+	BaseFeePerGas         string  `json:"base_fee_per_gas"`
+	Difficulty            string  `json:"difficulty"`
+	ExtraData             string  `json:"extra_data"`
+	StateRoot             string  `json:"state_root"`
+	TransactionsRoot      string  `json:"transactions_root"`
+	ReceiptsRoot          string  `json:"receipts_root"`
+	LogsBloom             string  `json:"logs_bloom"`
+	Sha3Uncles            string  `json:"sha3_uncles"`
+	MixHash               string  `json:"mix_hash"`
+	Nonce                 string  `json:"nonce"`
+	Size                  uint64  `json:"size"`
+	WithdrawalsRoot       string  `json:"withdrawals_root"`
+	BlobGasUsed           *uint64 `json:"blob_gas_used"`
+	ExcessBlobGas         *uint64 `json:"excess_blob_gas"`
+	ParentBeaconBlockRoot string  `json:"parent_beacon_block_root"`
+
 	Transactions Transactions `json:"transactions,omitempty"`
+	Withdrawals  Withdrawals  `json:"withdrawals,omitempty"`
+	Uncles       Uncles       `json:"uncles,omitempty"`
+
+	InternalTransactions InternalTransactions `json:"internal_transactions,omitempty"`
+	Contracts            Contracts            `json:"contracts,omitempty"`
+	BalanceChanges       BalanceChanges       `json:"balance_changes,omitempty"`
+}
+
+// Withdrawal is a struct that represents a validator withdrawal included in a
+// block after the Shanghai upgrade
+type Withdrawal struct {
+	BlockNumber    uint64 `json:"block_number"`
+	BlockHash      string `json:"block_hash"`
+	Index          uint64 `json:"index"`
+	ValidatorIndex uint64 `json:"validator_index"`
+	Address        string `json:"address"`
+	// Amount is in gwei
+	Amount uint64 `json:"amount"`
+}
+
+// Withdrawals is a slice of Withdrawal
+type Withdrawals []*Withdrawal
+
+// Uncle is an ommer header referenced by a block. It is unrelated to
+// Block.IsUncle which marks blocks that were orphaned by a reorg.
+type Uncle struct {
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	Index       uint64 `json:"index"`
+	Number      uint64 `json:"number"`
+	Hash        string `json:"hash"`
+	ParentHash  string `json:"parent_hash"`
+	Miner       string `json:"miner"`
+	Timestamp   uint64 `json:"timestamp"`
+	Difficulty  string `json:"difficulty"`
+	GasLimit    uint64 `json:"gas_limit"`
+	GasUsed     uint64 `json:"gas_used"`
 }
 
+// Uncles is a slice of Uncle
+type Uncles []*Uncle
+
 // ToBlockModel converts an Ethereum block to a Block
 func ToBlockModel(ethBlock *types.Block) *Block {
-	return &Block{
-		Number:     ethBlock.NumberU64(),
-		Hash:       ethBlock.Hash().Hex(),
-		ParentHash: ethBlock.ParentHash().Hex(),
-		Timestamp:  ethBlock.Time(),
+	header := ethBlock.Header()
+	block := &Block{
+		Number:           ethBlock.NumberU64(),
+		Hash:             ethBlock.Hash().Hex(),
+		ParentHash:       ethBlock.ParentHash().Hex(),
+		Timestamp:        ethBlock.Time(),
+		Miner:            header.Coinbase.Hex(),
+		GasUsed:          header.GasUsed,
+		GasLimit:         header.GasLimit,
+		ExtraData:        hexutil.Encode(header.Extra),
+		StateRoot:        header.Root.Hex(),
+		TransactionsRoot: header.TxHash.Hex(),
+		ReceiptsRoot:     header.ReceiptHash.Hex(),
+		LogsBloom:        hexutil.Encode(header.Bloom.Bytes()),
+		Sha3Uncles:       header.UncleHash.Hex(),
+		MixHash:          header.MixDigest.Hex(),
+		Nonce:            hexutil.Encode(header.Nonce[:]),
+		Size:             ethBlock.Size(),
+		BlobGasUsed:      header.BlobGasUsed,
+		ExcessBlobGas:    header.ExcessBlobGas,
+	}
+	if header.Difficulty != nil {
+		block.Difficulty = header.Difficulty.String()
+	}
+	if header.BaseFee != nil {
+		block.BaseFeePerGas = header.BaseFee.String()
+	}
+	if header.WithdrawalsHash != nil {
+		block.WithdrawalsRoot = header.WithdrawalsHash.Hex()
+	}
+	if header.ParentBeaconRoot != nil {
+		block.ParentBeaconBlockRoot = header.ParentBeaconRoot.Hex()
+	}
+
+	block.Withdrawals = make(Withdrawals, len(ethBlock.Withdrawals()))
+	for i, w := range ethBlock.Withdrawals() {
+		block.Withdrawals[i] = &Withdrawal{
+			BlockNumber:    block.Number,
+			BlockHash:      block.Hash,
+			Index:          w.Index,
+			ValidatorIndex: w.Validator,
+			Address:        w.Address.Hex(),
+			Amount:         w.Amount,
+		}
+	}
+
+	// ethclient fetches the ommer headers with eth_getUncleByBlockHashAndIndex
+	// when the block references any
+	block.Uncles = make(Uncles, len(ethBlock.Uncles()))
+	for i, u := range ethBlock.Uncles() {
+		block.Uncles[i] = &Uncle{
+			BlockNumber: block.Number,
+			BlockHash:   block.Hash,
+			Index:       uint64(i),
+			Number:      u.Number.Uint64(),
+			Hash:        u.Hash().Hex(),
+			ParentHash:  u.ParentHash.Hex(),
+			Miner:       u.Coinbase.Hex(),
+			Timestamp:   u.Time,
+			GasLimit:    u.GasLimit,
+			GasUsed:     u.GasUsed,
+		}
+		if u.Difficulty != nil {
+			block.Uncles[i].Difficulty = u.Difficulty.String()
+		}
 	}
+
+	return block
 }
 
-// Save saves a block to the database
+// blockColumns are the columns written by Block.Save
+var blockColumns = []string{
+	"number", "hash", "parent_hash", "timestamp", "status",
+	"miner", "gas_used", "gas_limit", "base_fee_per_gas", "difficulty", "extra_data",
+	"state_root", "transactions_root", "receipts_root", "logs_bloom", "sha3_uncles", "mix_hash", "nonce",
+	"size", "withdrawals_root", "blob_gas_used", "excess_blob_gas", "parent_beacon_block_root",
+	"transaction_count",
+}
+
+func (b *Block) columnValues() []any {
+	return []any{
+		b.Number, b.Hash, b.ParentHash, b.Timestamp, b.Status,
+		b.Miner, b.GasUsed, b.GasLimit, nullNumeric(b.BaseFeePerGas), nullNumeric(b.Difficulty), b.ExtraData,
+		b.StateRoot, b.TransactionsRoot, b.ReceiptsRoot, b.LogsBloom, b.Sha3Uncles, b.MixHash, b.Nonce,
+		b.Size, b.WithdrawalsRoot, b.BlobGasUsed, b.ExcessBlobGas, b.ParentBeaconBlockRoot,
+		len(b.Transactions),
+	}
+}
+
+// Save saves a block with its withdrawals, uncles, internal transactions,
+// created contracts and balance changes to the database
 func (b *Block) Save(ctx context.Context, db *pkg.DBClient) error {
-	_, err := db.ExecContext(ctx, "INSERT INTO blocks (number, hash, parent_hash, timestamp, status) VALUES ($1, $2, $3, $4, $5)", b.Number, b.Hash, b.ParentHash, b.Timestamp, b.Status)
-	return err
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement := "INSERT INTO blocks (" + strings.Join(blockColumns, ", ") + ") VALUES " + placeholders(0, len(blockColumns))
+	if _, err := tx.ExecContext(ctx, statement, b.columnValues()...); err != nil {
+		return err
+	}
+
+	if err := b.Withdrawals.save(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := b.Uncles.save(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := b.InternalTransactions.save(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := b.Contracts.save(ctx, tx); err != nil {
+		return fmt.Errorf("failed to save contracts: %w", err)
+	}
+
+	if err := b.BalanceChanges.save(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withdrawalColumns are the columns written by Withdrawals.save
+var withdrawalColumns = []string{"block_number", "block_hash", "index", "validator_index", "address", "amount"}
+
+func (ws Withdrawals) save(ctx context.Context, tx *sql.Tx) error {
+	if len(ws) == 0 {
+		return nil
+	}
+
+	columnCount := len(withdrawalColumns)
+	statement := "INSERT INTO withdrawals (" + strings.Join(withdrawalColumns, ", ") + ") VALUES "
+	args := make([]any, 0, len(ws)*columnCount)
+	for i, w := range ws {
+		if i > 0 {
+			statement += ", "
+		}
+		statement += placeholders(i*columnCount, columnCount)
+		args = append(args, w.BlockNumber, w.BlockHash, w.Index, w.ValidatorIndex, w.Address, w.Amount)
+	}
+
+	if _, err := tx.ExecContext(ctx, statement, args...); err != nil {
+		return fmt.Errorf("failed to save withdrawals: %w", err)
+	}
+	return nil
+}
+
+// uncleColumns are the columns written by Uncles.save
+var uncleColumns = []string{"block_number", "block_hash", "index", "number", "hash", "parent_hash", "miner", "timestamp", "difficulty", "gas_limit", "gas_used"}
+
+func (us Uncles) save(ctx context.Context, tx *sql.Tx) error {
+	if len(us) == 0 {
+		return nil
+	}
+
+	columnCount := len(uncleColumns)
+	statement := "INSERT INTO uncles (" + strings.Join(uncleColumns, ", ") + ") VALUES "
+	args := make([]any, 0, len(us)*columnCount)
+	for i, u := range us {
+		if i > 0 {
+			statement += ", "
+		}
+		statement += placeholders(i*columnCount, columnCount)
+		args = append(args, u.BlockNumber, u.BlockHash, u.Index, u.Number, u.Hash, u.ParentHash, u.Miner, u.Timestamp, nullNumeric(u.Difficulty), u.GasLimit, u.GasUsed)
+	}
+
+	if _, err := tx.ExecContext(ctx, statement, args...); err != nil {
+		return fmt.Errorf("failed to save uncles: %w", err)
+	}
+	return nil
 }
@@ -7,8 +7,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/korprulu/interview-homework-b/internal/pkg"
 	"github.com/korprulu/interview-homework-b/internal/config"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
 )
 
 func TestBlockModelSave(t *testing.T) {
@@ -35,6 +35,14 @@ func TestBlockModelSave(t *testing.T) {
 		Hash:       "0x123",
 		ParentHash: "0x122",
 		Timestamp:  uint64(time.Now().Unix()),
+		Status:     "unfinalized",
+		Miner:      "0x124",
+		GasUsed:    uint64(21000),
+		GasLimit:   uint64(30000000),
+		Difficulty: "58750003716598352816469",
+		Withdrawals: Withdrawals{
+			{BlockNumber: uint64(1), BlockHash: "0x123", Index: uint64(1), ValidatorIndex: uint64(2), Address: "0x125", Amount: uint64(100)},
+		},
 	}
 
 	err = block.Save(ctx, dbClient)
@@ -42,11 +50,13 @@ func TestBlockModelSave(t *testing.T) {
 		t.Error(err)
 	}
 	defer dbClient.ExecContext(ctx, "DELETE FROM blocks WHERE number = $1 AND hash = $2", block.Number, block.Hash)
+	defer dbClient.ExecContext(ctx, "DELETE FROM withdrawals WHERE block_hash = $1", block.Hash)
 
-	row := dbClient.QueryRowContext(ctx, "SELECT * FROM blocks WHERE number = $1 AND hash = $2", block.Number, block.Hash)
+	row := dbClient.QueryRowContext(ctx, "SELECT number, hash, parent_hash, timestamp, miner, gas_used, gas_limit, difficulty::text, base_fee_per_gas IS NULL FROM blocks WHERE number = $1 AND hash = $2", block.Number, block.Hash)
 
 	var actual Block
-	err = row.Scan(&actual.Number, &actual.Hash, &actual.ParentHash, &actual.Timestamp)
+	var nullBaseFee bool
+	err = row.Scan(&actual.Number, &actual.Hash, &actual.ParentHash, &actual.Timestamp, &actual.Miner, &actual.GasUsed, &actual.GasLimit, &actual.Difficulty, &nullBaseFee)
 	if err != nil {
 		t.Error(err)
 	}
@@ -63,4 +73,93 @@ func TestBlockModelSave(t *testing.T) {
 	if actual.Timestamp != block.Timestamp {
 		t.Errorf("Expected timestamp %d, got %d", block.Timestamp, actual.Timestamp)
 	}
+	if actual.Miner != block.Miner {
+		t.Errorf("Expected miner %s, got %s", block.Miner, actual.Miner)
+	}
+	if actual.GasUsed != block.GasUsed {
+		t.Errorf("Expected gas used %d, got %d", block.GasUsed, actual.GasUsed)
+	}
+	if actual.GasLimit != block.GasLimit {
+		t.Errorf("Expected gas limit %d, got %d", block.GasLimit, actual.GasLimit)
+	}
+	if actual.Difficulty != block.Difficulty {
+		t.Errorf("Expected difficulty %s, got %s", block.Difficulty, actual.Difficulty)
+	}
+	if !nullBaseFee {
+		t.Errorf("Expected NULL base fee for a block before London")
+	}
+
+	var withdrawalCount int
+	err = dbClient.QueryRowContext(ctx, "SELECT COUNT(*) FROM withdrawals WHERE block_hash = $1", block.Hash).Scan(&withdrawalCount)
+	if err != nil {
+		t.Error(err)
+	}
+	if withdrawalCount != len(block.Withdrawals) {
+		t.Errorf("Expected %d withdrawals, got %d", len(block.Withdrawals), withdrawalCount)
+	}
+}
+
+func TestBlockModelSaveUncles(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		User:     cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbClient.Close()
+
+	ctx := context.Background()
+	block := &Block{
+		Number:     uint64(2),
+		Hash:       "0x456",
+		ParentHash: "0x455",
+		Timestamp:  uint64(time.Now().Unix()),
+		Status:     "unfinalized",
+		Miner:      "0x124",
+		Uncles: Uncles{
+			{BlockNumber: uint64(2), BlockHash: "0x456", Index: uint64(0), Number: uint64(1), Hash: "0x789", ParentHash: "0x454", Miner: "0x126", Timestamp: uint64(1), Difficulty: "17171480576", GasLimit: uint64(5000000), GasUsed: uint64(21000)},
+			{BlockNumber: uint64(2), BlockHash: "0x456", Index: uint64(1), Number: uint64(1), Hash: "0x78a", ParentHash: "0x454", Miner: "0x127", Timestamp: uint64(2)},
+		},
+	}
+
+	err = block.Save(ctx, dbClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbClient.ExecContext(ctx, "DELETE FROM blocks WHERE number = $1 AND hash = $2", block.Number, block.Hash)
+	defer dbClient.ExecContext(ctx, "DELETE FROM uncles WHERE block_hash = $1", block.Hash)
+
+	rows, err := dbClient.QueryContext(ctx, "SELECT block_number, block_hash, index, number, hash, parent_hash, miner, timestamp, COALESCE(difficulty::text, ''), gas_limit, gas_used FROM uncles WHERE block_hash = $1 ORDER BY index", block.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	actualUncles := make(Uncles, 0)
+	for rows.Next() {
+		var uncle Uncle
+		err = rows.Scan(&uncle.BlockNumber, &uncle.BlockHash, &uncle.Index, &uncle.Number, &uncle.Hash, &uncle.ParentHash, &uncle.Miner, &uncle.Timestamp, &uncle.Difficulty, &uncle.GasLimit, &uncle.GasUsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actualUncles = append(actualUncles, &uncle)
+	}
+
+	if len(actualUncles) != len(block.Uncles) {
+		t.Fatalf("Expected %d uncles, got %d", len(block.Uncles), len(actualUncles))
+	}
+	for i, actual := range actualUncles {
+		if *actual != *block.Uncles[i] {
+			t.Errorf("Expected uncle %+v, got %+v", *block.Uncles[i], *actual)
+		}
+	}
 }
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+// Contract is a contract created by a deployment transaction or by another
+// contract
+type Contract struct {
+	Address        string `json:"address"`
+	Creator        string `json:"creator"`
+	CreationTxHash string `json:"creation_tx_hash"`
+	BlockNumber    uint64 `json:"block_number"`
+	BlockHash      string `json:"block_hash"`
+	// BytecodeHash is the keccak256 hash of the runtime bytecode
+	BytecodeHash string `json:"bytecode_hash"`
+}
+
+// Contracts is a slice of Contract
+type Contracts []*Contract
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ToContractsFromInternalTransactions returns the contracts created by the
+// successful CREATE and CREATE2 calls
+func ToContractsFromInternalTransactions(itxs InternalTransactions) Contracts {
+	var contracts Contracts
+	for _, itx := range itxs.Successful() {
+		if (itx.Type != "CREATE" && itx.Type != "CREATE2") || itx.To == "" {
+			continue
+		}
+		contracts = append(contracts, &Contract{
+			Address:        itx.To,
+			Creator:        itx.From,
+			CreationTxHash: itx.TxHash,
+			BlockNumber:    itx.BlockNumber,
+			BlockHash:      itx.BlockHash,
+		})
+	}
+	return contracts
+}
+
+// Save saves a slice of Contract to the database, contracts already stored
+// are skipped
+func (cs Contracts) Save(ctx context.Context, db *pkg.DBClient) error {
+	return cs.save(ctx, db)
+}
+
+// contractColumns are the columns written by Contracts.save
+var contractColumns = []string{"address", "creator", "creation_tx_hash", "block_number", "block_hash", "bytecode_hash"}
+
+func (cs Contracts) save(ctx context.Context, db execer) error {
+	if len(cs) == 0 {
+		return nil
+	}
+
+	columnCount := len(contractColumns)
+	statement := "INSERT INTO contracts (" + strings.Join(contractColumns, ", ") + ") VALUES "
+	args := make([]any, 0, len(cs)*columnCount)
+	for i, c := range cs {
+		if i > 0 {
+			statement += ", "
+		}
+		statement += placeholders(i*columnCount, columnCount)
+		args = append(args, c.Address, c.Creator, c.CreationTxHash, c.BlockNumber, c.BlockHash, nullString(c.BytecodeHash))
+	}
+	statement += " ON CONFLICT DO NOTHING"
+
+	_, err := db.ExecContext(ctx, statement, args...)
+	return err
+}
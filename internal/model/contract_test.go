@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestToContractsFromInternalTransactions(t *testing.T) {
+	t.Parallel()
+
+	itxs := InternalTransactions{
+		{TxHash: "0xaa", TraceAddress: TraceAddress{}, Type: "CREATE", From: "0x01", To: "0x02"},
+		{TxHash: "0xaa", TraceAddress: TraceAddress{0}, Type: "CREATE2", From: "0x02", To: "0x03"},
+		{TxHash: "0xbb", TraceAddress: TraceAddress{}, Type: "CALL", From: "0x01", To: "0x04"},
+		{TxHash: "0xbb", TraceAddress: TraceAddress{0}, Type: "CALL", From: "0x04", To: "0x05", Error: "execution reverted"},
+		{TxHash: "0xbb", TraceAddress: TraceAddress{0, 0}, Type: "CREATE", From: "0x05", To: "0x06"},
+		{TxHash: "0xbb", TraceAddress: TraceAddress{1}, Type: "CREATE", From: "0x04", Error: "out of gas"},
+	}
+
+	contracts := ToContractsFromInternalTransactions(itxs)
+	if len(contracts) != 2 {
+		t.Fatalf("Expected 2 contracts, got %d", len(contracts))
+	}
+	if contracts[0].Address != "0x02" || contracts[0].Creator != "0x01" {
+		t.Errorf("Expected contract 0x02 created by 0x01, got %s created by %s", contracts[0].Address, contracts[0].Creator)
+	}
+	if contracts[1].Address != "0x03" || contracts[1].Creator != "0x02" {
+		t.Errorf("Expected contract 0x03 created by 0x02, got %s created by %s", contracts[1].Address, contracts[1].Creator)
+	}
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// placeholders returns a "($n, $n+1, ...)" group of count placeholders
+// starting after offset
+func placeholders(offset, count int) string {
+	params := make([]string, count)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", offset+i+1)
+	}
+	return "(" + strings.Join(params, ", ") + ")"
+}
+
+// maxInsertRows is the most rows inserted by a statement of batchInsert, it
+// keeps the statement below the postgres limit of 65535 parameters
+const maxInsertRows = 1000
+
+// batchInsert inserts the rows into the columns of the table with multi-row
+// statements of up to maxInsertRows rows. wrap, when set, returns the
+// statement executed for the INSERT of a chunk.
+func batchInsert(ctx context.Context, tx execer, table string, columns []string, rows [][]any, wrap func(insert string) string) error {
+	for start := 0; start < len(rows); start += maxInsertRows {
+		end := start + maxInsertRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		statement := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES "
+		args := make([]any, 0, (end-start)*len(columns))
+		for i, row := range rows[start:end] {
+			if i > 0 {
+				statement += ", "
+			}
+			statement += placeholders(i*len(columns), len(columns))
+			args = append(args, row...)
+		}
+		if wrap != nil {
+			statement = wrap(statement)
+		}
+
+		if _, err := tx.ExecContext(ctx, statement, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
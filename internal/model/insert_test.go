@@ -0,0 +1,59 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// recordingExecer records the statements executed and their args
+type recordingExecer struct {
+	statements []string
+	args       [][]any
+}
+
+func (e *recordingExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	e.statements = append(e.statements, query)
+	e.args = append(e.args, args)
+	return nil, nil
+}
+
+func TestBatchInsert(t *testing.T) {
+	t.Parallel()
+
+	rows := make([][]any, maxInsertRows+1)
+	for i := range rows {
+		rows[i] = []any{i, "value"}
+	}
+
+	var db recordingExecer
+	err := batchInsert(context.Background(), &db, "things", []string{"id", "name"}, rows, func(insert string) string {
+		return insert + " ON CONFLICT DO NOTHING"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.statements) != 2 {
+		t.Fatalf("Expected 2 statements, got %d", len(db.statements))
+	}
+	if len(db.args[0]) != 2*maxInsertRows || len(db.args[1]) != 2 {
+		t.Errorf("Expected %d and 2 args, got %d and %d", 2*maxInsertRows, len(db.args[0]), len(db.args[1]))
+	}
+	expected := "INSERT INTO things (id, name) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	if db.statements[1] != expected {
+		t.Errorf("Expected %s, got %s", expected, db.statements[1])
+	}
+	if !strings.HasPrefix(db.statements[0], "INSERT INTO things (id, name) VALUES ($1, $2), ($3, $4)") {
+		t.Errorf("Expected a multi-row insert, got %.80s", db.statements[0])
+	}
+	if db.args[1][0] != maxInsertRows {
+		t.Errorf("Expected the second statement to start at row %d, got %v", maxInsertRows, db.args[1][0])
+	}
+
+	db = recordingExecer{}
+	if err := batchInsert(context.Background(), &db, "things", []string{"id"}, nil, nil); err != nil || len(db.statements) != 0 {
+		t.Errorf("Expected no statement for no rows, got %v %v", db.statements, err)
+	}
+}
@@ -0,0 +1,225 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+// InternalTransaction is a call made during the execution of a transaction,
+// including the top level call itself
+type InternalTransaction struct {
+	TxHash      string `json:"tx_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	// Index is the position of the call in a depth-first walk of the call tree
+	Index        uint64       `json:"index"`
+	TraceAddress TraceAddress `json:"trace_address"`
+	Depth        int          `json:"depth"`
+	Type         string       `json:"type"`
+	From         string       `json:"from"`
+	To           string       `json:"to"`
+	Value        string       `json:"value"`
+	Gas          uint64       `json:"gas"`
+	GasUsed      uint64       `json:"gas_used"`
+	Error        string       `json:"error"`
+}
+
+// InternalTransactions is a slice of InternalTransaction
+type InternalTransactions []*InternalTransaction
+
+// Successful returns the calls that took effect, a call is reverted when it
+// or any of its parents failed
+func (itxs InternalTransactions) Successful() InternalTransactions {
+	failed := make(map[string]bool)
+	for _, itx := range itxs {
+		if itx.Error != "" {
+			failed[itx.TxHash+itx.TraceAddress.key()] = true
+		}
+	}
+
+	var result InternalTransactions
+	for _, itx := range itxs {
+		reverted := false
+		for depth := 0; depth <= len(itx.TraceAddress) && !reverted; depth++ {
+			reverted = failed[itx.TxHash+itx.TraceAddress[:depth].key()]
+		}
+		if !reverted {
+			result = append(result, itx)
+		}
+	}
+	return result
+}
+
+// TraceAddress is the path of a call in the call tree
+type TraceAddress []int
+
+func (a TraceAddress) key() string {
+	return fmt.Sprint([]int(a))
+}
+
+// Value returns the value of the trace address as a driver.Value
+func (a TraceAddress) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan scans the value into a TraceAddress
+func (a *TraceAddress) Scan(src interface{}) error {
+	return scanJSON(src, a)
+}
+
+// ToInternalTransactionsFromCallFrames flattens the callTracer output of a
+// block, txHashes are the hashes of the block transactions in order
+func ToInternalTransactionsFromCallFrames(blockNumber uint64, blockHash string, txHashes []string, traces []pkg.TxCallTrace) (InternalTransactions, error) {
+	if len(traces) != len(txHashes) {
+		return nil, fmt.Errorf("got %d traces for %d transactions", len(traces), len(txHashes))
+	}
+
+	var result InternalTransactions
+	for i, trace := range traces {
+		if trace.TxHash.Hex() != txHashes[i] {
+			return nil, fmt.Errorf("got the trace of transaction %s for transaction %s", trace.TxHash.Hex(), txHashes[i])
+		}
+		if trace.Result == nil {
+			return nil, fmt.Errorf("failed to trace transaction %s: %s", txHashes[i], trace.Error)
+		}
+
+		var index uint64
+		var walk func(frame *pkg.CallFrame, address TraceAddress)
+		walk = func(frame *pkg.CallFrame, address TraceAddress) {
+			itx := &InternalTransaction{
+				TxHash:       txHashes[i],
+				BlockNumber:  blockNumber,
+				BlockHash:    blockHash,
+				Index:        index,
+				TraceAddress: address,
+				Depth:        len(address),
+				Type:         strings.ToUpper(frame.Type),
+				From:         frame.From.Hex(),
+				Value:        "0",
+				Gas:          uint64(frame.Gas),
+				GasUsed:      uint64(frame.GasUsed),
+				Error:        frame.Error,
+			}
+			if frame.To != nil {
+				itx.To = frame.To.Hex()
+			}
+			if frame.Value != nil {
+				itx.Value = frame.Value.ToInt().String()
+			}
+			result = append(result, itx)
+			index++
+
+			for j := range frame.Calls {
+				child := make(TraceAddress, len(address), len(address)+1)
+				copy(child, address)
+				walk(&frame.Calls[j], append(child, j))
+			}
+		}
+		walk(trace.Result, TraceAddress{})
+	}
+
+	return result, nil
+}
+
+// ToInternalTransactionsFromParityTraces converts the trace_block output of a
+// block, block reward traces are skipped. txHashes are the hashes of the block
+// transactions, a trace of another transaction means the traces are of
+// another block.
+func ToInternalTransactionsFromParityTraces(blockNumber uint64, blockHash string, txHashes []string, traces []pkg.ParityTrace) (InternalTransactions, error) {
+	var result InternalTransactions
+	indexes := make(map[string]uint64, len(txHashes))
+	for _, txHash := range txHashes {
+		indexes[txHash] = 0
+	}
+
+	for _, trace := range traces {
+		if trace.TransactionHash == nil {
+			continue
+		}
+		txHash := trace.TransactionHash.Hex()
+		if _, ok := indexes[txHash]; !ok {
+			return nil, fmt.Errorf("got the trace of transaction %s not in block %s", txHash, blockHash)
+		}
+
+		itx := &InternalTransaction{
+			TxHash:       txHash,
+			BlockNumber:  blockNumber,
+			BlockHash:    blockHash,
+			Index:        indexes[txHash],
+			TraceAddress: TraceAddress(trace.TraceAddress),
+			Depth:        len(trace.TraceAddress),
+			Value:        "0",
+			Gas:          uint64(trace.Action.Gas),
+			Error:        trace.Error,
+		}
+		indexes[txHash]++
+		if itx.TraceAddress == nil {
+			itx.TraceAddress = TraceAddress{}
+		}
+		if trace.Result != nil {
+			itx.GasUsed = uint64(trace.Result.GasUsed)
+		}
+
+		switch trace.Type {
+		case "call":
+			itx.Type = strings.ToUpper(trace.Action.CallType)
+			if trace.Action.To != nil {
+				itx.To = trace.Action.To.Hex()
+			}
+		case "create":
+			itx.Type = "CREATE"
+			if trace.Action.CreationMethod != "" {
+				itx.Type = strings.ToUpper(trace.Action.CreationMethod)
+			}
+			if trace.Result != nil && trace.Result.Address != nil {
+				itx.To = trace.Result.Address.Hex()
+			}
+		case "suicide":
+			itx.Type = "SELFDESTRUCT"
+			if trace.Action.Address != nil {
+				itx.From = trace.Action.Address.Hex()
+			}
+			if trace.Action.RefundAddress != nil {
+				itx.To = trace.Action.RefundAddress.Hex()
+			}
+			if trace.Action.Balance != nil {
+				itx.Value = trace.Action.Balance.ToInt().String()
+			}
+		default:
+			itx.Type = strings.ToUpper(trace.Type)
+		}
+		if trace.Action.From != nil {
+			itx.From = trace.Action.From.Hex()
+		}
+		if trace.Action.Value != nil {
+			itx.Value = trace.Action.Value.ToInt().String()
+		}
+
+		result = append(result, itx)
+	}
+
+	return result, nil
+}
+
+// internalTransactionColumns are the columns written by InternalTransactions.save
+var internalTransactionColumns = []string{"tx_hash", "block_number", "block_hash", "index", "trace_address", "depth", "type", "from_address", "to_address", "value", "gas", "gas_used", "error"}
+
+func (itxs InternalTransactions) save(ctx context.Context, tx *sql.Tx) error {
+	rows := make([][]any, len(itxs))
+	for i, itx := range itxs {
+		rows[i] = []any{itx.TxHash, itx.BlockNumber, itx.BlockHash, itx.Index, itx.TraceAddress, itx.Depth, itx.Type, itx.From, itx.To, itx.Value, itx.Gas, itx.GasUsed, itx.Error}
+	}
+	if err := batchInsert(ctx, tx, "internal_transactions", internalTransactionColumns, rows, nil); err != nil {
+		return fmt.Errorf("failed to save internal transactions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+func TestToInternalTransactionsFromCallFrames(t *testing.T) {
+	t.Parallel()
+
+	var traces []pkg.TxCallTrace
+	err := json.Unmarshal([]byte(`[{
+		"txHash": "0x00000000000000000000000000000000000000000000000000000000000000aa",
+		"result": {
+			"type": "CALL", "from": "0x0000000000000000000000000000000000000001", "to": "0x0000000000000000000000000000000000000002",
+			"value": "0xa", "gas": "0x5208", "gasUsed": "0x5208",
+			"calls": [
+				{"type": "DELEGATECALL", "from": "0x0000000000000000000000000000000000000002", "to": "0x0000000000000000000000000000000000000003", "gas": "0x10", "gasUsed": "0x8",
+					"calls": [{"type": "CALL", "from": "0x0000000000000000000000000000000000000002", "to": "0x0000000000000000000000000000000000000004", "value": "0x1", "gas": "0x4", "gasUsed": "0x2", "error": "execution reverted"}]},
+				{"type": "CREATE2", "from": "0x0000000000000000000000000000000000000002", "to": "0x0000000000000000000000000000000000000005", "value": "0x0", "gas": "0x20", "gasUsed": "0x10"}
+			]
+		}
+	}]`), &traces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txHash := "0x00000000000000000000000000000000000000000000000000000000000000aa"
+	itxs, err := ToInternalTransactionsFromCallFrames(1, "0xbb", []string{txHash}, traces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		traceAddress TraceAddress
+		typ          string
+		value        string
+		err          string
+	}{
+		{TraceAddress{}, "CALL", "10", ""},
+		{TraceAddress{0}, "DELEGATECALL", "0", ""},
+		{TraceAddress{0, 0}, "CALL", "1", "execution reverted"},
+		{TraceAddress{1}, "CREATE2", "0", ""},
+	}
+
+	if len(itxs) != len(expected) {
+		t.Fatalf("Expected %d internal transactions, got %d", len(expected), len(itxs))
+	}
+	for i, exp := range expected {
+		itx := itxs[i]
+		if itx.Index != uint64(i) {
+			t.Errorf("Expected index %d, got %d", i, itx.Index)
+		}
+		if !reflect.DeepEqual(itx.TraceAddress, exp.traceAddress) {
+			t.Errorf("Expected trace address %v, got %v", exp.traceAddress, itx.TraceAddress)
+		}
+		if itx.Depth != len(exp.traceAddress) {
+			t.Errorf("Expected depth %d, got %d", len(exp.traceAddress), itx.Depth)
+		}
+		if itx.Type != exp.typ {
+			t.Errorf("Expected type %s, got %s", exp.typ, itx.Type)
+		}
+		if itx.Value != exp.value {
+			t.Errorf("Expected value %s, got %s", exp.value, itx.Value)
+		}
+		if itx.Error != exp.err {
+			t.Errorf("Expected error %q, got %q", exp.err, itx.Error)
+		}
+	}
+
+	if _, err := ToInternalTransactionsFromCallFrames(1, "0xbb", []string{"0x00000000000000000000000000000000000000000000000000000000000000cc"}, traces); err == nil {
+		t.Errorf("Expected an error for the trace of another transaction")
+	}
+}
+
+func TestToInternalTransactionsFromParityTraces(t *testing.T) {
+	t.Parallel()
+
+	var traces []pkg.ParityTrace
+	err := json.Unmarshal([]byte(`[
+		{"type": "call", "action": {"callType": "call", "from": "0x0000000000000000000000000000000000000001", "to": "0x0000000000000000000000000000000000000002", "value": "0xa", "gas": "0x5208"},
+			"result": {"gasUsed": "0x5208"}, "traceAddress": [], "transactionHash": "0x00000000000000000000000000000000000000000000000000000000000000aa", "transactionPosition": 0},
+		{"type": "create", "action": {"from": "0x0000000000000000000000000000000000000002", "value": "0x0", "gas": "0x20"},
+			"result": {"gasUsed": "0x10", "address": "0x0000000000000000000000000000000000000005"}, "traceAddress": [0], "transactionHash": "0x00000000000000000000000000000000000000000000000000000000000000aa", "transactionPosition": 0},
+		{"type": "suicide", "action": {"address": "0x0000000000000000000000000000000000000005", "refundAddress": "0x0000000000000000000000000000000000000001", "balance": "0x3"},
+			"traceAddress": [0, 0], "transactionHash": "0x00000000000000000000000000000000000000000000000000000000000000aa", "transactionPosition": 0},
+		{"type": "reward", "action": {"author": "0x0000000000000000000000000000000000000009", "value": "0x1"}, "traceAddress": []}
+	]`), &traces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	itxs, err := ToInternalTransactionsFromParityTraces(1, "0xbb", []string{"0x00000000000000000000000000000000000000000000000000000000000000aa"}, traces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(itxs) != 3 {
+		t.Fatalf("Expected 3 internal transactions, got %d", len(itxs))
+	}
+
+	if itxs[1].Type != "CREATE" || itxs[1].To != "0x0000000000000000000000000000000000000005" {
+		t.Errorf("Expected CREATE to the created address, got %s to %s", itxs[1].Type, itxs[1].To)
+	}
+	if itxs[2].Type != "SELFDESTRUCT" || itxs[2].Value != "3" || itxs[2].Depth != 2 {
+		t.Errorf("Expected SELFDESTRUCT of 3 at depth 2, got %s of %s at depth %d", itxs[2].Type, itxs[2].Value, itxs[2].Depth)
+	}
+	if itxs[2].Index != 2 {
+		t.Errorf("Expected index 2, got %d", itxs[2].Index)
+	}
+
+	if _, err := ToInternalTransactionsFromParityTraces(1, "0xbb", []string{"0x00000000000000000000000000000000000000000000000000000000000000cc"}, traces); err == nil {
+		t.Errorf("Expected an error for the traces of another block")
+	}
+}
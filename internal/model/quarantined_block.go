@@ -0,0 +1,23 @@
+package model
+
+import (
+	"context"
+
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+// QuarantinedBlock is a block that failed verification and was kept out of
+// the blocks table
+type QuarantinedBlock struct {
+	Number uint64 `json:"number"`
+	Hash   string `json:"hash"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// Save saves a quarantined block to the database, the reason is updated if
+// the block was quarantined before
+func (b *QuarantinedBlock) Save(ctx context.Context, db *pkg.DBClient) error {
+	_, err := db.ExecContext(ctx, "INSERT INTO quarantined_blocks (number, hash, status, reason) VALUES ($1, $2, $3, $4) ON CONFLICT (number, hash) DO UPDATE SET reason = EXCLUDED.reason, quarantined_at = NOW()", b.Number, b.Hash, b.Status, b.Reason)
+	return err
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+// Token is the ERC-20 metadata of a token contract
+type Token struct {
+	Address  string `json:"address"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals *uint8 `json:"decimals"`
+	// TotalSupply is the total supply when the token was enriched, it is
+	// empty when the token doesn't implement totalSupply
+	TotalSupply string `json:"total_supply"`
+}
+
+// ToToken converts the metadata returned by the token contract to a Token
+func ToToken(address string, metadata *pkg.TokenMetadata) *Token {
+	token := &Token{
+		Address:  address,
+		Name:     metadata.Name,
+		Symbol:   metadata.Symbol,
+		Decimals: metadata.Decimals,
+	}
+	if metadata.TotalSupply != nil {
+		token.TotalSupply = metadata.TotalSupply.String()
+	}
+	return token
+}
+
+// Save saves the token, the metadata of a token already stored is replaced
+func (t *Token) Save(ctx context.Context, db *pkg.DBClient) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO tokens (address, name, symbol, decimals, total_supply, enriched_at) VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (address) DO UPDATE SET name = EXCLUDED.name, symbol = EXCLUDED.symbol, decimals = EXCLUDED.decimals, total_supply = EXCLUDED.total_supply, enriched_at = NOW()`,
+		t.Address, t.Name, t.Symbol, t.Decimals, t.TotalSupply)
+	return err
+}
+
+// FormatUnits formats an integer amount of the smallest token unit as a
+// decimal number of whole tokens, e.g. 1500000 with 6 decimals is "1.5"
+func FormatUnits(amount string, decimals uint8) string {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return ""
+	}
+	if decimals == 0 {
+		return value.String()
+	}
+
+	sign := ""
+	if value.Sign() < 0 {
+		sign = "-"
+		value.Neg(value)
+	}
+
+	digits := value.String()
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	integer, fraction := digits[:len(digits)-int(decimals)], strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+	if fraction == "" {
+		return sign + integer
+	}
+	return sign + integer + "." + fraction
+}
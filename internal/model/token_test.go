@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestFormatUnits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		amount   string
+		decimals uint8
+		expected string
+	}{
+		{amount: "1500000", decimals: 6, expected: "1.5"},
+		{amount: "1000000000000000000", decimals: 18, expected: "1"},
+		{amount: "1", decimals: 18, expected: "0.000000000000000001"},
+		{amount: "0", decimals: 18, expected: "0"},
+		{amount: "-25", decimals: 1, expected: "-2.5"},
+		{amount: "42", decimals: 0, expected: "42"},
+		{amount: "abc", decimals: 6, expected: ""},
+	}
+
+	for _, tt := range tests {
+		if got := FormatUnits(tt.amount, tt.decimals); got != tt.expected {
+			t.Errorf("Expected %s with %d decimals to be %s, got %s", tt.amount, tt.decimals, tt.expected, got)
+		}
+	}
+}
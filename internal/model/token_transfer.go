@@ -0,0 +1,193 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+// TransferEventTopic is the topic of the ERC-20 Transfer(address,address,uint256) event
+var TransferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// TokenTransfer is an ERC-20 transfer decoded from a Transfer event
+type TokenTransfer struct {
+	TxHash      string `json:"tx_hash"`
+	LogIndex    uint   `json:"log_index"`
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	Token       string `json:"token"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Value       string `json:"value"`
+}
+
+// TokenTransfers is a slice of TokenTransfer
+type TokenTransfers []*TokenTransfer
+
+// ToTokenTransfers decodes the ERC-20 Transfer events in the logs of the
+// transactions. ERC-721 transfers share the event signature but index the
+// token id as a fourth topic, so they are skipped.
+func ToTokenTransfers(txs Transactions) TokenTransfers {
+	var transfers TokenTransfers
+	for _, tx := range txs {
+		for _, log := range tx.Logs {
+			from, to, value, ok := DecodeTransferLog(log)
+			if !ok {
+				continue
+			}
+			transfers = append(transfers, &TokenTransfer{
+				TxHash:      tx.Hash,
+				LogIndex:    log.Index,
+				BlockNumber: tx.BlockNumber,
+				BlockHash:   tx.BlockHash,
+				Token:       log.Address,
+				From:        from,
+				To:          to,
+				Value:       value,
+			})
+		}
+	}
+	return transfers
+}
+
+// DecodeTransferLog decodes the sender, receiver and value of an ERC-20
+// Transfer event, ok is false for any other log
+func DecodeTransferLog(log TransactionLog) (from, to, value string, ok bool) {
+	if len(log.Topics) != 3 || common.HexToHash(log.Topics[0]) != TransferEventTopic {
+		return "", "", "", false
+	}
+	data, err := hexutil.Decode(log.Data)
+	if err != nil || len(data) != 32 {
+		return "", "", "", false
+	}
+	return common.HexToAddress(log.Topics[1]).Hex(), common.HexToAddress(log.Topics[2]).Hex(), new(big.Int).SetBytes(data).String(), true
+}
+
+// tokenTransferColumns are the columns written by TokenTransfers.Save
+var tokenTransferColumns = []string{"tx_hash", "log_index", "block_number", "block_hash", "token", "from_address", "to_address", "value"}
+
+// applyTokenTransfersStatement adds the transfers inserted by the wrapped
+// statement to token_balances. Transfers already stored are not inserted
+// again, so they are applied once, and transfers of blocks already marked as
+// uncles are not applied at all. The zero address is not tracked as a holder.
+const applyTokenTransfersStatement = `WITH inserted AS (%s ON CONFLICT DO NOTHING RETURNING token, from_address, to_address, value, block_number, block_hash),
+canonical AS (
+	SELECT i.* FROM inserted i JOIN blocks b ON b.number = i.block_number AND b.hash = i.block_hash WHERE b.is_uncle = false
+),
+deltas AS (
+	SELECT token, from_address AS holder, -value AS delta FROM canonical
+	UNION ALL
+	SELECT token, to_address AS holder, value AS delta FROM canonical
+)
+INSERT INTO token_balances (token, holder, balance)
+SELECT token, holder, SUM(delta) FROM deltas WHERE holder <> '0x0000000000000000000000000000000000000000' GROUP BY token, holder
+ON CONFLICT (token, holder) DO UPDATE SET balance = token_balances.balance + EXCLUDED.balance`
+
+// reverseTokenTransfersStatement subtracts the transfers of a block from
+// token_balances
+const reverseTokenTransfersStatement = `WITH deltas AS (
+	SELECT token, from_address AS holder, value AS delta FROM token_transfers WHERE block_hash = $1
+	UNION ALL
+	SELECT token, to_address AS holder, -value AS delta FROM token_transfers WHERE block_hash = $1
+)
+UPDATE token_balances tb SET balance = tb.balance + d.delta
+FROM (SELECT token, holder, SUM(delta) AS delta FROM deltas GROUP BY token, holder) d
+WHERE tb.token = d.token AND tb.holder = d.holder`
+
+// Save saves the transfers and applies them to the token balances in a single
+// database transaction
+func (tts TokenTransfers) Save(ctx context.Context, db *pkg.DBClient) error {
+	if len(tts) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tts.save(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (tts TokenTransfers) save(ctx context.Context, tx execer) error {
+	if len(tts) == 0 {
+		return nil
+	}
+
+	// lock the blocks so they can't be marked as uncles before the transfers
+	// are applied, the validator reverses the transfers it can see
+	blockHashes := make(map[string]bool)
+	var lockArgs []any
+	var lockParams []string
+	for _, tt := range tts {
+		if !blockHashes[tt.BlockHash] {
+			blockHashes[tt.BlockHash] = true
+			lockArgs = append(lockArgs, tt.BlockHash)
+			lockParams = append(lockParams, fmt.Sprintf("$%d", len(lockArgs)))
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT 1 FROM blocks WHERE hash IN ("+strings.Join(lockParams, ", ")+") FOR SHARE", lockArgs...); err != nil {
+		return err
+	}
+
+	rows := make([][]any, len(tts))
+	for i, tt := range tts {
+		rows[i] = []any{tt.TxHash, tt.LogIndex, tt.BlockNumber, tt.BlockHash, tt.Token, tt.From, tt.To, tt.Value}
+	}
+	err := batchInsert(ctx, tx, "token_transfers", tokenTransferColumns, rows, func(insert string) string {
+		return fmt.Sprintf(applyTokenTransfersStatement, insert)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save token transfers: %w", err)
+	}
+
+	return savePendingTokens(ctx, tx, tts)
+}
+
+// savePendingTokens stores the tokens of the transfers not stored yet, the
+// enricher fetches the metadata of the tokens without one
+func savePendingTokens(ctx context.Context, tx execer, tts TokenTransfers) error {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, tt := range tts {
+		if !seen[tt.Token] {
+			seen[tt.Token] = true
+			tokens = append(tokens, tt.Token)
+		}
+	}
+	// concurrent batches insert the tokens in the same order so they don't
+	// deadlock
+	sort.Strings(tokens)
+
+	rows := make([][]any, len(tokens))
+	for i, token := range tokens {
+		rows[i] = []any{token}
+	}
+	err := batchInsert(ctx, tx, "tokens", []string{"address"}, rows, func(insert string) string {
+		return insert + " ON CONFLICT DO NOTHING"
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save pending tokens: %w", err)
+	}
+	return nil
+}
+
+// ReverseTokenTransfers reverts the token balance changes of a block that
+// became an uncle, it must run in the transaction that marks the block
+func ReverseTokenTransfers(ctx context.Context, tx *sql.Tx, blockHash string) error {
+	_, err := tx.ExecContext(ctx, reverseTokenTransfersStatement, blockHash)
+	return err
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestToTokenTransfers(t *testing.T) {
+	t.Parallel()
+
+	from := common.HexToAddress("0x01")
+	to := common.HexToAddress("0x02")
+	token := common.HexToAddress("0x03").Hex()
+
+	txs := Transactions{{
+		Hash:        "0xaa",
+		BlockNumber: 1,
+		BlockHash:   "0xbb",
+		Logs: TransactionLogs{
+			{
+				Index:   0,
+				Address: token,
+				Topics:  []string{TransferEventTopic.Hex(), common.BytesToHash(from.Bytes()).Hex(), common.BytesToHash(to.Bytes()).Hex()},
+				Data:    common.BigToHash(common.Big3).Hex(),
+			},
+			{
+				// ERC-721 transfer
+				Index:   1,
+				Address: token,
+				Topics:  []string{TransferEventTopic.Hex(), common.BytesToHash(from.Bytes()).Hex(), common.BytesToHash(to.Bytes()).Hex(), common.BigToHash(common.Big1).Hex()},
+				Data:    "0x",
+			},
+			{
+				Index:   2,
+				Address: token,
+				Topics:  []string{common.HexToHash("0x01").Hex(), common.BytesToHash(from.Bytes()).Hex(), common.BytesToHash(to.Bytes()).Hex()},
+				Data:    common.BigToHash(common.Big3).Hex(),
+			},
+		},
+	}}
+
+	transfers := ToTokenTransfers(txs)
+	if len(transfers) != 1 {
+		t.Fatalf("Expected 1 transfer, got %d", len(transfers))
+	}
+
+	transfer := transfers[0]
+	if transfer.Token != token {
+		t.Errorf("Expected token %s, got %s", token, transfer.Token)
+	}
+	if transfer.From != from.Hex() {
+		t.Errorf("Expected from %s, got %s", from.Hex(), transfer.From)
+	}
+	if transfer.To != to.Hex() {
+		t.Errorf("Expected to %s, got %s", to.Hex(), transfer.To)
+	}
+	if transfer.Value != "3" {
+		t.Errorf("Expected value 3, got %s", transfer.Value)
+	}
+	if transfer.TxHash != "0xaa" || transfer.BlockHash != "0xbb" || transfer.BlockNumber != 1 {
+		t.Errorf("Expected transfer of tx 0xaa in block 1 0xbb, got %s in %d %s", transfer.TxHash, transfer.BlockNumber, transfer.BlockHash)
+	}
+}
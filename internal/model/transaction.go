@@ -2,10 +2,15 @@ package model
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
@@ -23,27 +28,165 @@ type Transaction struct {
 	Logs        TransactionLogs `json:"logs"` // get from receipt
 	BlockHash   string          `json:"block_hash"`
 	BlockNumber uint64          `json:"block_number"`
+	// ContractAddress is the address of the contract created by a deployment
+	// transaction, get from receipt
+	ContractAddress string `json:"contract_address"`
+	// Status, GasUsed, CumulativeGasUsed, LogsBloom, BlobGasUsed and
+	// BlobGasPrice get from receipt
+	Status            uint64 `json:"status"`
+	GasUsed           uint64 `json:"gas_used"`
+	CumulativeGasUsed uint64 `json:"cumulative_gas_used"`
+	LogsBloom         string `json:"logs_bloom"`
+	BlobGasUsed       uint64 `json:"blob_gas_used"`
+	BlobGasPrice      string `json:"blob_gas_price"`
+
+	Type uint8  `json:"type"`
+	Gas  uint64 `json:"gas"`
+	// GasPrice is the effective price paid per gas, for EIP-1559 transactions
+	// it is derived from the fee caps and the base fee of the block
+	GasPrice             string                `json:"gas_price"`
+	MaxFeePerGas         string                `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas string                `json:"max_priority_fee_per_gas"`
+	MaxFeePerBlobGas     string                `json:"max_fee_per_blob_gas"`
+	BlobVersionedHashes  TransactionBlobHashes `json:"blob_versioned_hashes"`
+	AccessList           TransactionAccessList `json:"access_list"`
+	ChainID              string                `json:"chain_id"`
+	V                    string                `json:"v"`
+	R                    string                `json:"r"`
+	S                    string                `json:"s"`
 }
 
-// StreamValue returns the value of the transaction as a StreamValue
+// StreamValue returns the value of the transaction as a StreamValue, the
+// fields from the receipt are only included once the receipt is set
 func (tx *Transaction) StreamValue() pkg.StreamValue {
-	return pkg.StreamValue{
-		"index":        tx.Index,
-		"tx_hash":      tx.Hash,
-		"from":         tx.From,
-		"to":           tx.To,
-		"nonce":        hexutil.EncodeUint64(tx.Nonce),
-		"data":         tx.Data,
-		"value":        tx.Value,
-		"block_hash":   tx.BlockHash,
-		"block_number": hexutil.EncodeUint64(tx.BlockNumber),
+	accessList, _ := json.Marshal(tx.AccessList)
+	blobHashes, _ := json.Marshal(tx.BlobVersionedHashes)
+	values := pkg.StreamValue{
+		"index":                    tx.Index,
+		"tx_hash":                  tx.Hash,
+		"from":                     tx.From,
+		"to":                       tx.To,
+		"nonce":                    hexutil.EncodeUint64(tx.Nonce),
+		"data":                     tx.Data,
+		"value":                    tx.Value,
+		"block_hash":               tx.BlockHash,
+		"block_number":             hexutil.EncodeUint64(tx.BlockNumber),
+		"type":                     hexutil.EncodeUint64(uint64(tx.Type)),
+		"gas":                      hexutil.EncodeUint64(tx.Gas),
+		"gas_price":                tx.GasPrice,
+		"max_fee_per_gas":          tx.MaxFeePerGas,
+		"max_priority_fee_per_gas": tx.MaxPriorityFeePerGas,
+		"max_fee_per_blob_gas":     tx.MaxFeePerBlobGas,
+		"blob_versioned_hashes":    string(blobHashes),
+		"access_list":              string(accessList),
+		"chain_id":                 tx.ChainID,
+		"v":                        tx.V,
+		"r":                        tx.R,
+		"s":                        tx.S,
+	}
+
+	if tx.HasReceipt() {
+		logs, _ := json.Marshal(tx.Logs)
+		values["logs"] = string(logs)
+		values["contract_address"] = tx.ContractAddress
+		values["status"] = hexutil.EncodeUint64(tx.Status)
+		values["gas_used"] = hexutil.EncodeUint64(tx.GasUsed)
+		values["cumulative_gas_used"] = hexutil.EncodeUint64(tx.CumulativeGasUsed)
+		values["logs_bloom"] = tx.LogsBloom
+		values["blob_gas_used"] = hexutil.EncodeUint64(tx.BlobGasUsed)
+		values["blob_gas_price"] = tx.BlobGasPrice
 	}
+
+	return values
+}
+
+// ToTransactionFromStreamValue converts a StreamValue produced by
+// Transaction.StreamValue back to a Transaction
+func ToTransactionFromStreamValue(values pkg.StreamValue) (*Transaction, error) {
+	str := func(key string) string {
+		v, _ := values[key].(string)
+		return v
+	}
+
+	index, err := strconv.ParseUint(str("index"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode index %v: %w", values["index"], err)
+	}
+	nonce, err := hexutil.DecodeUint64(str("nonce"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode nonce %v: %w", values["nonce"], err)
+	}
+	blockNumber, err := hexutil.DecodeUint64(str("block_number"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode block number %v: %w", values["block_number"], err)
+	}
+	txType, err := hexutil.DecodeUint64(str("type"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode type %v: %w", values["type"], err)
+	}
+	gas, err := hexutil.DecodeUint64(str("gas"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode gas %v: %w", values["gas"], err)
+	}
+
+	tx := &Transaction{
+		Index:                index,
+		Hash:                 str("tx_hash"),
+		From:                 str("from"),
+		To:                   str("to"),
+		Nonce:                nonce,
+		Data:                 str("data"),
+		Value:                str("value"),
+		BlockHash:            str("block_hash"),
+		BlockNumber:          blockNumber,
+		Type:                 uint8(txType),
+		Gas:                  gas,
+		GasPrice:             str("gas_price"),
+		MaxFeePerGas:         str("max_fee_per_gas"),
+		MaxPriorityFeePerGas: str("max_priority_fee_per_gas"),
+		MaxFeePerBlobGas:     str("max_fee_per_blob_gas"),
+		ChainID:              str("chain_id"),
+		V:                    str("v"),
+		R:                    str("r"),
+		S:                    str("s"),
+	}
+	if err := tx.AccessList.Scan(str("access_list")); err != nil {
+		return nil, fmt.Errorf("failed to decode access list: %w", err)
+	}
+	if err := tx.BlobVersionedHashes.Scan(str("blob_versioned_hashes")); err != nil {
+		return nil, fmt.Errorf("failed to decode blob versioned hashes: %w", err)
+	}
+
+	if str("logs_bloom") == "" {
+		return tx, nil
+	}
+
+	tx.ContractAddress = str("contract_address")
+	tx.LogsBloom = str("logs_bloom")
+	tx.BlobGasPrice = str("blob_gas_price")
+	if err := tx.Logs.Scan(str("logs")); err != nil {
+		return nil, fmt.Errorf("failed to decode logs: %w", err)
+	}
+	for key, dest := range map[string]*uint64{
+		"status":              &tx.Status,
+		"gas_used":            &tx.GasUsed,
+		"cumulative_gas_used": &tx.CumulativeGasUsed,
+		"blob_gas_used":       &tx.BlobGasUsed,
+	} {
+		if *dest, err = hexutil.DecodeUint64(str(key)); err != nil {
+			return nil, fmt.Errorf("failed to decode %s %v: %w", key, values[key], err)
+		}
+	}
+
+	return tx, nil
 }
 
 // TransactionLog is a struct that represents a transaction log in the Ethereum blockchain
 type TransactionLog struct {
-	Index uint   `json:"index"`
-	Data  string `json:"data"`
+	Index   uint     `json:"index"`
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
 }
 
 // TransactionLogs is a slice of TransactionLog
@@ -56,11 +199,56 @@ func (t TransactionLogs) Value() (driver.Value, error) {
 
 // Scan scans the value into a TransactionLog
 func (t *TransactionLogs) Scan(src interface{}) error {
+	return scanJSON(src, t)
+}
+
+// TransactionAccessList is the EIP-2930 access list of a transaction
+type TransactionAccessList types.AccessList
+
+// Value returns the value of the access list as a driver.Value
+func (a TransactionAccessList) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan scans the value into a TransactionAccessList
+func (a *TransactionAccessList) Scan(src interface{}) error {
+	return scanJSON(src, a)
+}
+
+// TransactionBlobHashes is the EIP-4844 blob versioned hashes of a transaction
+type TransactionBlobHashes []string
+
+// Value returns the value of the blob hashes as a driver.Value
+func (b TransactionBlobHashes) Value() (driver.Value, error) {
+	if b == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(b)
+}
+
+// Scan scans the value into a TransactionBlobHashes
+func (b *TransactionBlobHashes) Scan(src interface{}) error {
+	return scanJSON(src, b)
+}
+
+// scanJSON unmarshals a JSON column, NULL and empty values are ignored
+func scanJSON(src interface{}, dest any) error {
 	switch src := src.(type) {
+	case nil:
+		return nil
 	case []byte:
-		return json.Unmarshal(src, t)
+		if len(src) == 0 {
+			return nil
+		}
+		return json.Unmarshal(src, dest)
 	case string:
-		return json.Unmarshal([]byte(src), t)
+		if src == "" {
+			return nil
+		}
+		return json.Unmarshal([]byte(src), dest)
 	default:
 		return fmt.Errorf("unsupported type: %T", src)
 	}
@@ -71,7 +259,7 @@ type Transactions []*Transaction
 
 // ToTransaction converts an Ethereum transaction to a Transaction
 func ToTransaction(ctx context.Context, ethClient *pkg.EthClient, tx *types.Transaction, block *types.Block, index int) (*Transaction, error) {
-	from, err := ethClient.TransactionSender(ctx, tx, block.Hash(), uint(index))
+	from, err := ethClient.RecoverSender(ctx, tx, block.Hash(), uint(index))
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +273,8 @@ func ToTransaction(ctx context.Context, ethClient *pkg.EthClient, tx *types.Tran
 	if valueAddr != nil {
 		value = valueAddr.String()
 	}
-	return &Transaction{
+
+	model := &Transaction{
 		Index:       uint64(index),
 		Hash:        tx.Hash().Hex(),
 		From:        from.Hex(),
@@ -95,21 +284,174 @@ func ToTransaction(ctx context.Context, ethClient *pkg.EthClient, tx *types.Tran
 		Value:       value,
 		BlockHash:   block.Hash().Hex(),
 		BlockNumber: block.Number().Uint64(),
-	}, nil
+		Type:        tx.Type(),
+		Gas:         tx.Gas(),
+		GasPrice:    effectiveGasPrice(tx, block.BaseFee()).String(),
+		AccessList:  TransactionAccessList(tx.AccessList()),
+	}
+
+	switch tx.Type() {
+	case types.DynamicFeeTxType, types.BlobTxType:
+		model.MaxFeePerGas = tx.GasFeeCap().String()
+		model.MaxPriorityFeePerGas = tx.GasTipCap().String()
+	}
+	if tx.Type() == types.BlobTxType {
+		model.MaxFeePerBlobGas = tx.BlobGasFeeCap().String()
+		model.BlobVersionedHashes = make(TransactionBlobHashes, len(tx.BlobHashes()))
+		for i, h := range tx.BlobHashes() {
+			model.BlobVersionedHashes[i] = h.Hex()
+		}
+	}
+	// legacy transactions signed before EIP-155 have no chain ID
+	if tx.Protected() {
+		model.ChainID = tx.ChainId().String()
+	}
+	v, r, s := tx.RawSignatureValues()
+	model.V = hexutil.EncodeBig(v)
+	model.R = hexutil.EncodeBig(r)
+	model.S = hexutil.EncodeBig(s)
+
+	return model, nil
+}
+
+// SetReceipt sets the logs, the execution results and the created contract of
+// the transaction from its receipt
+func (tx *Transaction) SetReceipt(receipt *types.Receipt) {
+	if receipt.Status == types.ReceiptStatusSuccessful && receipt.ContractAddress != (common.Address{}) {
+		tx.ContractAddress = receipt.ContractAddress.Hex()
+	}
+	tx.Status = receipt.Status
+	tx.GasUsed = receipt.GasUsed
+	tx.CumulativeGasUsed = receipt.CumulativeGasUsed
+	tx.LogsBloom = hexutil.Encode(receipt.Bloom.Bytes())
+	tx.BlobGasUsed = receipt.BlobGasUsed
+	if receipt.BlobGasPrice != nil {
+		tx.BlobGasPrice = receipt.BlobGasPrice.String()
+	}
+
+	tx.Logs = make(TransactionLogs, len(receipt.Logs))
+	for i, l := range receipt.Logs {
+		topics := make([]string, len(l.Topics))
+		for j, topic := range l.Topics {
+			topics[j] = topic.Hex()
+		}
+		tx.Logs[i] = TransactionLog{
+			Index:   l.Index,
+			Address: l.Address.Hex(),
+			Topics:  topics,
+			Data:    hexutil.Encode(l.Data),
+		}
+	}
+}
+
+// HasReceipt tells whether the fields from the receipt are set
+func (tx *Transaction) HasReceipt() bool {
+	return tx.LogsBloom != ""
+}
+
+// effectiveGasPrice returns the price per gas the transaction paid in a block
+// with the given base fee
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+	tip, err := tx.EffectiveGasTip(baseFee)
+	if err != nil {
+		return tx.GasPrice()
+	}
+	return tip.Add(tip, baseFee)
+}
+
+// transactionColumns are the columns written by Transactions.Save
+var transactionColumns = []string{
+	"hash", "index", "from_address", "to_address", "nonce", "data", "value", "logs", "block_hash", "block_number", "contract_address",
+	"status", "gas_used", "cumulative_gas_used", "logs_bloom", "blob_gas_used", "blob_gas_price",
+	"type", "gas", "gas_price", "max_fee_per_gas", "max_priority_fee_per_gas", "max_fee_per_blob_gas",
+	"blob_versioned_hashes", "access_list", "chain_id", "v", "r", "s",
+}
+
+func (tx *Transaction) columnValues() []any {
+	return []any{
+		tx.Hash, tx.Index, tx.From, tx.To, tx.Nonce, tx.Data, tx.Value, tx.Logs, tx.BlockHash, tx.BlockNumber, tx.ContractAddress,
+		tx.Status, tx.GasUsed, tx.CumulativeGasUsed, tx.LogsBloom, tx.BlobGasUsed, nullNumeric(tx.BlobGasPrice),
+		tx.Type, tx.Gas, nullNumeric(tx.GasPrice), nullNumeric(tx.MaxFeePerGas), nullNumeric(tx.MaxPriorityFeePerGas), nullNumeric(tx.MaxFeePerBlobGas),
+		tx.BlobVersionedHashes, tx.AccessList, nullNumeric(tx.ChainID), tx.V, tx.R, tx.S,
+	}
 }
 
 // Save saves a slice of Transaction to the database
 func (txs Transactions) Save(ctx context.Context, db *pkg.DBClient) error {
+	return txs.save(ctx, db)
+}
+
+// save inserts the transactions, a transaction is stored once per block that
+// included it and transactions already stored are skipped
+func (txs Transactions) save(ctx context.Context, db execer) error {
 	if len(txs) == 0 {
 		return nil
 	}
-	statement := "INSERT INTO transactions (hash, index, from_address, to_address, nonce, data, value, logs, block_hash, block_number) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
-	args := []any{txs[0].Hash, txs[0].Index, txs[0].From, txs[0].To, txs[0].Nonce, txs[0].Data, txs[0].Value, txs[0].Logs, txs[0].BlockHash, txs[0].BlockNumber}
-	for i := 1; i < len(txs); i++ {
-		statement += fmt.Sprintf(", ($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", (i*10)+1, (i*10)+2, (i*10)+3, (i*10)+4, (i*10)+5, (i*10)+6, (i*10)+7, (i*10)+8, (i*10)+9, (i*10)+10)
-		tx := txs[i]
-		args = append(args, tx.Hash, tx.Index, tx.From, tx.To, tx.Nonce, tx.Data, tx.Value, tx.Logs, tx.BlockHash, tx.BlockNumber)
+
+	columnCount := len(transactionColumns)
+	statement := "INSERT INTO transactions (" + strings.Join(transactionColumns, ", ") + ") VALUES "
+	args := make([]any, 0, len(txs)*columnCount)
+	for i, tx := range txs {
+		if i > 0 {
+			statement += ", "
+		}
+		statement += placeholders(i*columnCount, columnCount)
+		args = append(args, tx.columnValues()...)
 	}
+	statement += " ON CONFLICT (hash, block_hash) DO NOTHING"
+
 	_, err := db.ExecContext(ctx, statement, args...)
 	return err
 }
+
+// TransactionBatch is a batch of transactions saved with the contracts they
+// created and their token transfers
+type TransactionBatch struct {
+	Transactions Transactions
+	Contracts    Contracts
+}
+
+// Save saves the transactions, the contracts and the token transfers decoded
+// from the logs in a single database transaction, so a batch is either fully
+// stored or can be retried
+func (b *TransactionBatch) Save(ctx context.Context, db *pkg.DBClient) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := b.Transactions.save(ctx, tx); err != nil {
+		return fmt.Errorf("failed to save transactions: %w", err)
+	}
+
+	if err := b.Contracts.save(ctx, tx); err != nil {
+		return fmt.Errorf("failed to save contracts: %w", err)
+	}
+
+	if err := ToTokenTransfers(b.Transactions).save(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// nullNumeric returns the value of a NUMERIC column, NULL for a field that
+// doesn't apply to the row
+func nullNumeric(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// nullString returns the value of a nullable column, NULL for an empty value
+func nullString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/korprulu/interview-homework-b/internal/pkg"
+)
+
+func TestTransactionStreamValue(t *testing.T) {
+	t.Parallel()
+
+	expected := &Transaction{
+		Index:                uint64(3),
+		Hash:                 "0xabc",
+		From:                 "0x123",
+		To:                   "0x124",
+		Nonce:                uint64(7),
+		Data:                 "0x",
+		Value:                "12345678",
+		BlockHash:            "0x123123",
+		BlockNumber:          uint64(17310465),
+		Type:                 types.BlobTxType,
+		Gas:                  uint64(21000),
+		GasPrice:             "30000000000",
+		MaxFeePerGas:         "40000000000",
+		MaxPriorityFeePerGas: "1000000000",
+		MaxFeePerBlobGas:     "1",
+		BlobVersionedHashes:  TransactionBlobHashes{"0x01"},
+		AccessList: TransactionAccessList{
+			{Address: common.HexToAddress("0x01"), StorageKeys: []common.Hash{common.HexToHash("0x02")}},
+		},
+		ChainID: "1",
+		V:       "0x1",
+		R:       "0x2",
+		S:       "0x3",
+	}
+
+	actual, err := ToTransactionFromStreamValue(redisStreamValue(t, expected.StreamValue()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+	if actual.HasReceipt() {
+		t.Errorf("Expected no receipt")
+	}
+
+	expected.SetReceipt(&types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		GasUsed:           21000,
+		CumulativeGasUsed: 42000,
+		ContractAddress:   common.HexToAddress("0x05"),
+		Logs: []*types.Log{
+			{Index: 1, Address: common.HexToAddress("0x06"), Topics: []common.Hash{common.HexToHash("0x07")}, Data: []byte{0x08}},
+		},
+	})
+
+	actual, err = ToTransactionFromStreamValue(redisStreamValue(t, expected.StreamValue()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+	if !actual.HasReceipt() {
+		t.Errorf("Expected the receipt to be set")
+	}
+}
+
+// redisStreamValue returns the values as read back from redis, which returns
+// every value as a string
+func redisStreamValue(t *testing.T, values pkg.StreamValue) pkg.StreamValue {
+	result := pkg.StreamValue{}
+	for k, v := range values {
+		switch v := v.(type) {
+		case string:
+			result[k] = v
+		case uint64:
+			result[k] = strconv.FormatUint(v, 10)
+		default:
+			t.Fatalf("unexpected stream value type %T for %s", v, k)
+		}
+	}
+	return result
+}
@@ -4,8 +4,10 @@ package model
 
 import (
 	"context"
+	"math/big"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/korprulu/interview-homework-b/internal/config"
 	"github.com/korprulu/interview-homework-b/internal/pkg"
 )
@@ -46,6 +48,9 @@ func TestModelTransactionSave(t *testing.T) {
 			},
 			BlockHash:   "0x123123",
 			BlockNumber: uint64(1),
+			Type:        uint8(0),
+			Gas:         uint64(21000),
+			GasPrice:    "1000",
 		},
 		&Transaction{
 			Index: uint64(2),
@@ -61,8 +66,12 @@ func TestModelTransactionSave(t *testing.T) {
 					Data:  "0x123",
 				},
 			},
-			BlockHash:   "0x123123",
-			BlockNumber: uint64(1),
+			BlockHash:    "0x123123",
+			BlockNumber:  uint64(1),
+			Type:         uint8(2),
+			Gas:          uint64(50000),
+			GasPrice:     "1000",
+			MaxFeePerGas: "2000",
 		},
 	}
 
@@ -72,7 +81,7 @@ func TestModelTransactionSave(t *testing.T) {
 	}
 	defer dbClient.ExecContext(ctx, "DELETE FROM transactions WHERE hash IN ($1, $2)", models[0].Hash, models[1].Hash)
 
-	rows, err := dbClient.QueryContext(ctx, "SELECT * FROM transactions WHERE hash IN ($1, $2)", models[0].Hash, models[1].Hash)
+	rows, err := dbClient.QueryContext(ctx, "SELECT hash, index, block_hash, block_number, from_address, to_address, nonce, data, value, logs, type, gas, COALESCE(gas_price::text, ''), COALESCE(max_fee_per_gas::text, ''), access_list FROM transactions WHERE hash IN ($1, $2) ORDER BY index", models[0].Hash, models[1].Hash)
 	if err != nil {
 		t.Error(err)
 	}
@@ -81,7 +90,7 @@ func TestModelTransactionSave(t *testing.T) {
 	actualModels := make(Transactions, 0)
 	for rows.Next() {
 		var transaction Transaction
-		err = rows.Scan(&transaction.Hash, &transaction.Index, &transaction.BlockHash, &transaction.BlockNumber, &transaction.From, &transaction.To, &transaction.Nonce, &transaction.Data, &transaction.Value, &transaction.Logs)
+		err = rows.Scan(&transaction.Hash, &transaction.Index, &transaction.BlockHash, &transaction.BlockNumber, &transaction.From, &transaction.To, &transaction.Nonce, &transaction.Data, &transaction.Value, &transaction.Logs, &transaction.Type, &transaction.Gas, &transaction.GasPrice, &transaction.MaxFeePerGas, &transaction.AccessList)
 		if err != nil {
 			t.Error(err)
 		}
@@ -121,8 +130,145 @@ func TestModelTransactionSave(t *testing.T) {
 		if actual.Value != expected.Value {
 			t.Errorf("Expected value %s, got %s", expected.Value, actual.Value)
 		}
+		if actual.Type != expected.Type {
+			t.Errorf("Expected type %d, got %d", expected.Type, actual.Type)
+		}
+		if actual.Gas != expected.Gas {
+			t.Errorf("Expected gas %d, got %d", expected.Gas, actual.Gas)
+		}
+		if actual.GasPrice != expected.GasPrice {
+			t.Errorf("Expected gas price %s, got %s", expected.GasPrice, actual.GasPrice)
+		}
+		if actual.MaxFeePerGas != expected.MaxFeePerGas {
+			t.Errorf("Expected max fee per gas %s, got %s", expected.MaxFeePerGas, actual.MaxFeePerGas)
+		}
 		if len(actual.Logs) != len(expected.Logs) {
 			t.Errorf("Expected %d logs, got %d", len(expected.Logs), len(actual.Logs))
 		}
 	}
+
+	// the fee caps don't apply to the legacy transaction
+	var nullFees int
+	err = dbClient.QueryRowContext(ctx, "SELECT COUNT(*) FROM transactions WHERE hash = $1 AND max_fee_per_gas IS NULL AND max_priority_fee_per_gas IS NULL AND chain_id IS NULL", models[0].Hash).Scan(&nullFees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nullFees != 1 {
+		t.Errorf("Expected NULL fee caps for a legacy transaction")
+	}
+}
+
+func TestTransactionBatchSaveAfterReorg(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbClient, err := pkg.NewDBClient(pkg.DBClientConfig{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		User:     cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbClient.Close()
+
+	ctx := context.Background()
+	token := common.HexToAddress("0xf1").Hex()
+	from := common.HexToAddress("0xf2")
+	to := common.HexToAddress("0xf3")
+	txHash := common.HexToHash("0xf4").Hex()
+	orphaned := &Block{Number: uint64(100001), Hash: "0xa1", ParentHash: "0xa0", Status: "unfinalized", Miner: "0x124"}
+	canonical := &Block{Number: uint64(100001), Hash: "0xb1", ParentHash: "0xa0", Status: "unfinalized", Miner: "0x124"}
+
+	defer dbClient.ExecContext(ctx, "DELETE FROM blocks WHERE hash IN ($1, $2)", orphaned.Hash, canonical.Hash)
+	defer dbClient.ExecContext(ctx, "DELETE FROM transactions WHERE hash = $1", txHash)
+	defer dbClient.ExecContext(ctx, "DELETE FROM token_transfers WHERE tx_hash = $1", txHash)
+	defer dbClient.ExecContext(ctx, "DELETE FROM token_balances WHERE token = $1", token)
+	defer dbClient.ExecContext(ctx, "DELETE FROM tokens WHERE address = $1", token)
+
+	batch := func(block *Block) *TransactionBatch {
+		return &TransactionBatch{Transactions: Transactions{{
+			Hash:        txHash,
+			From:        from.Hex(),
+			To:          token,
+			Value:       "0",
+			BlockHash:   block.Hash,
+			BlockNumber: block.Number,
+			GasPrice:    "1000",
+			Logs: TransactionLogs{{
+				Index:   0,
+				Address: token,
+				Topics:  []string{TransferEventTopic.Hex(), common.BytesToHash(from.Bytes()).Hex(), common.BytesToHash(to.Bytes()).Hex()},
+				Data:    common.BigToHash(big.NewInt(10)).Hex(),
+			}},
+		}}}
+	}
+	balance := func() string {
+		var balance string
+		err := dbClient.QueryRowContext(ctx, "SELECT balance::text FROM token_balances WHERE token = $1 AND holder = $2", token, to.Hex()).Scan(&balance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return balance
+	}
+
+	if err := orphaned.Save(ctx, dbClient); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch(orphaned).Save(ctx, dbClient); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != "10" {
+		t.Errorf("Expected balance 10, got %s", got)
+	}
+	var pending bool
+	if err := dbClient.QueryRowContext(ctx, "SELECT enriched_at IS NULL FROM tokens WHERE address = $1", token).Scan(&pending); err != nil || !pending {
+		t.Errorf("Expected the token pending enrichment, got %v %v", pending, err)
+	}
+
+	// the validator marks the block as an uncle and reverses its transfers
+	tx, err := dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE blocks SET is_uncle = true WHERE number = $1 AND hash = $2", orphaned.Number, orphaned.Hash); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+	if err := ReverseTokenTransfers(ctx, tx, orphaned.Hash); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != "0" {
+		t.Errorf("Expected balance 0 after the reorg, got %s", got)
+	}
+
+	// the transaction is re-included by the new canonical block, and saving
+	// the same batch again doesn't apply the transfer twice
+	if err := canonical.Save(ctx, dbClient); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := batch(canonical).Save(ctx, dbClient); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := balance(); got != "10" {
+		t.Errorf("Expected balance 10 after re-inclusion, got %s", got)
+	}
+
+	var count int
+	if err := dbClient.QueryRowContext(ctx, "SELECT COUNT(*) FROM transactions WHERE hash = $1", txHash).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected the transaction stored once per block, got %d rows", count)
+	}
 }
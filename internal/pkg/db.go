@@ -22,10 +22,14 @@ type DBClientConfig struct {
 	Database string
 }
 
+// ConnString returns the connection string of the database
+func (cfg DBClientConfig) ConnString() string {
+	return fmt.Sprintf("user=%s password='%s' host=%s port=%s dbname=%s sslmode=disable", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
+}
+
 // NewDBClient creates a new Postgres instance
 func NewDBClient(cfg DBClientConfig) (*DBClient, error) {
-	connStr := fmt.Sprintf("user=%s password='%s' host=%s port=%s dbname=%s sslmode=disable", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", cfg.ConnString())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,25 @@
+package pkg
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBListener is a wrapper around pq.Listener receiving Postgres notifications
+type DBListener struct {
+	*pq.Listener
+}
+
+// NewDBListener creates a listener subscribed to the given channels, it
+// reconnects on its own when the connection is lost. eventCallback may be nil.
+func NewDBListener(cfg DBClientConfig, eventCallback pq.EventCallbackType, channels ...string) (*DBListener, error) {
+	listener := pq.NewListener(cfg.ConnString(), 10*time.Second, time.Minute, eventCallback)
+	for _, channel := range channels {
+		if err := listener.Listen(channel); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	return &DBListener{listener}, nil
+}
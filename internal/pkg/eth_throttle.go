@@ -0,0 +1,263 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// rpcLimitExceededCode is the JSON-RPC error code providers use when a
+	// request exceeds their request rate
+	rpcLimitExceededCode = -32005
+	// minThrottleBackoff is the first wait after a rate throttled request
+	// without Retry-After, it doubles on every retry
+	minThrottleBackoff = 500 * time.Millisecond
+	// maxThrottleBackoff caps the wait after a rate throttled request
+	maxThrottleBackoff = 30 * time.Second
+	// maxThrottleRetries is how many times a rate throttled batch is retried
+	// before its error is returned
+	maxThrottleRetries = 5
+)
+
+// throttle is the reason a provider rejected a request
+type throttle int
+
+const (
+	notThrottled throttle = iota
+	// sizeThrottled means the batch is larger than the provider accepts
+	sizeThrottled
+	// rateThrottled means requests are sent faster than the provider accepts
+	rateThrottled
+)
+
+// sizeThrottleMessages are lowercase fragments of provider error messages
+// rejecting a batch for its size
+var sizeThrottleMessages = []string{
+	"batch too large",
+	"batch size too large",
+	"batch limit exceeded",
+	"request entity too large",
+}
+
+// rateThrottleMessages are lowercase fragments of provider error messages
+// rejecting a request for our request rate
+var rateThrottleMessages = []string{
+	"rate limit",
+	"too many requests",
+	"request rate exceeded",
+}
+
+// rateLimiter is a token bucket limiter shared by all requests sent to a
+// provider
+type rateLimiter struct {
+	limiter *rate.Limiter
+}
+
+func newRateLimiter(limit float64, burst int) *rateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{limiter: rate.NewLimiter(rate.Limit(limit), burst)}
+}
+
+// waitN blocks until n tokens are available, n may exceed the burst size
+func (l *rateLimiter) waitN(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	burst := l.limiter.Burst()
+	for n > 0 {
+		take := n
+		if take > burst {
+			take = burst
+		}
+		if err := l.limiter.WaitN(ctx, take); err != nil {
+			return err
+		}
+		n -= take
+	}
+	return nil
+}
+
+// throttleBackoff holds every request back until the provider accepts
+// requests again after throttling us
+type throttleBackoff struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// pause holds requests back for d, an earlier pause is never shortened
+func (b *throttleBackoff) pause(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until := time.Now().Add(d); until.After(b.until) {
+		b.until = until
+	}
+}
+
+// wait blocks until the pause is over
+func (b *throttleBackoff) wait(ctx context.Context) error {
+	b.mu.Lock()
+	d := time.Until(b.until)
+	b.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfter parses a Retry-After header in seconds or as an HTTP date
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
+
+// throttleDelay is the wait before retry attempt n of a rate throttled
+// request, without Retry-After
+func throttleDelay(attempt int) time.Duration {
+	d := minThrottleBackoff << attempt
+	if d <= 0 || d > maxThrottleBackoff {
+		return maxThrottleBackoff
+	}
+	return d
+}
+
+// rateLimitedTransport takes a token for every HTTP request sent to the
+// provider, and holds requests back for the Retry-After of a 429
+type rateLimitedTransport struct {
+	limiter *rateLimiter
+	backoff *throttleBackoff
+	base    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.backoff.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	if err := t.limiter.waitN(req.Context(), 1); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			t.backoff.pause(d)
+		}
+	}
+	return resp, err
+}
+
+// adaptiveBatchSize tracks the batch size accepted by a provider, it is
+// halved when the provider rejects a batch for its size and grows back on
+// success
+type adaptiveBatchSize struct {
+	mu   sync.Mutex
+	size int
+	max  int
+	step int
+}
+
+func newAdaptiveBatchSize(max int) *adaptiveBatchSize {
+	if max < 1 {
+		max = 1
+	}
+	step := max / 10
+	if step < 1 {
+		step = 1
+	}
+	return &adaptiveBatchSize{size: max, max: max, step: step}
+}
+
+// Size returns the current batch size
+func (b *adaptiveBatchSize) Size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Shrink halves the batch size and returns the new size
+func (b *adaptiveBatchSize) Shrink() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.size /= 2
+	if b.size < 1 {
+		b.size = 1
+	}
+	return b.size
+}
+
+// Grow increases the batch size by one step up to the maximum
+func (b *adaptiveBatchSize) Grow() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.size += b.step
+	if b.size > b.max {
+		b.size = b.max
+	}
+}
+
+// classifyThrottle tells whether the error means the provider rejected the
+// request because of its size or because of our request rate
+func classifyThrottle(err error) throttle {
+	if err == nil {
+		return notThrottled
+	}
+
+	// some providers answer a batch too large with a 429, so the message is
+	// checked before the status
+	msg := strings.ToLower(err.Error())
+	for _, m := range sizeThrottleMessages {
+		if strings.Contains(msg, m) {
+			return sizeThrottled
+		}
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusRequestEntityTooLarge:
+			return sizeThrottled
+		case http.StatusTooManyRequests:
+			return rateThrottled
+		}
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == rpcLimitExceededCode {
+		return rateThrottled
+	}
+	for _, m := range rateThrottleMessages {
+		if strings.Contains(msg, m) {
+			return rateThrottled
+		}
+	}
+	return notThrottled
+}
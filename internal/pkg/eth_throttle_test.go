@@ -0,0 +1,206 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type testRPCMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+// newBatchLimitedServer returns a JSON-RPC server that answers 413 when a
+// batch has more than maxBatch elements
+func newBatchLimitedServer(t *testing.T, maxBatch int, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		var msgs []testRPCMessage
+		if err := json.NewDecoder(r.Body).Decode(&msgs); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if len(msgs) > maxBatch {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		writeBatchResult(w, msgs)
+	}))
+}
+
+func writeBatchResult(w http.ResponseWriter, msgs []testRPCMessage) {
+	resp := make([]map[string]any, len(msgs))
+	for i, msg := range msgs {
+		resp[i] = map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": "0x1"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestBatchCallShrinksOnThrottle(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := newBatchLimitedServer(t, 3, &requests)
+	defer server.Close()
+
+	client, err := NewEthClient(EthClientConfig{URL: server.URL, MaxBatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	elems := make([]rpc.BatchElem, 10)
+	for i := range elems {
+		elems[i] = rpc.BatchElem{Method: "eth_chainId", Result: new(string)}
+	}
+
+	if err := client.batchCall(context.Background(), elems); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, elem := range elems {
+		if elem.Error != nil {
+			t.Errorf("element %d: unexpected error %v", i, elem.Error)
+		}
+		if *elem.Result.(*string) != "0x1" {
+			t.Errorf("element %d: expected 0x1, got %s", i, *elem.Result.(*string))
+		}
+	}
+
+	if size := client.batchSize.Size(); size >= 10 {
+		t.Errorf("expected batch size to shrink below 10, got %d", size)
+	}
+}
+
+func TestBatchCallBacksOffOnRateThrottle(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msgs []testRPCMessage
+		if err := json.NewDecoder(r.Body).Decode(&msgs); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeBatchResult(w, msgs)
+	}))
+	defer server.Close()
+
+	client, err := NewEthClient(EthClientConfig{URL: server.URL, MaxBatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	elems := make([]rpc.BatchElem, 10)
+	for i := range elems {
+		elems[i] = rpc.BatchElem{Method: "eth_chainId", Result: new(string)}
+	}
+
+	start := time.Now()
+	if err := client.batchCall(context.Background(), elems); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected to wait for Retry-After, retried after %v", elapsed)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("expected 2 requests, got %d", got)
+	}
+	if size := client.batchSize.Size(); size != 10 {
+		t.Errorf("expected the batch size kept at 10, got %d", size)
+	}
+	for i, elem := range elems {
+		if elem.Error != nil {
+			t.Errorf("element %d: unexpected error %v", i, elem.Error)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	if d, ok := retryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("expected 3s, got %v %v", d, ok)
+	}
+	if d, ok := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); !ok || d <= 0 || d > time.Minute {
+		t.Errorf("expected up to a minute, got %v %v", d, ok)
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Errorf("expected an invalid header to be ignored")
+	}
+	if got := throttleDelay(100); got != maxThrottleBackoff {
+		t.Errorf("expected the backoff capped at %v, got %v", maxThrottleBackoff, got)
+	}
+}
+
+func TestAdaptiveBatchSize(t *testing.T) {
+	t.Parallel()
+
+	size := newAdaptiveBatchSize(20)
+
+	if got := size.Shrink(); got != 10 {
+		t.Errorf("expected 10, got %d", got)
+	}
+	size.Shrink()
+	size.Shrink()
+	size.Shrink()
+	size.Shrink()
+	if got := size.Shrink(); got != 1 {
+		t.Errorf("expected 1, got %d", got)
+	}
+
+	for i := 0; i < 20; i++ {
+		size.Grow()
+	}
+	if got := size.Size(); got != 20 {
+		t.Errorf("expected 20, got %d", got)
+	}
+}
+
+func TestClassifyThrottle(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		err  error
+		exp  throttle
+	}{
+		{"nil", nil, notThrottled},
+		{"429", rpc.HTTPError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}, rateThrottled},
+		{"413", rpc.HTTPError{StatusCode: http.StatusRequestEntityTooLarge, Status: "413 Request Entity Too Large"}, sizeThrottled},
+		{"429 batch too large", rpc.HTTPError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests", Body: []byte("batch too large")}, sizeThrottled},
+		{"500", rpc.HTTPError{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"}, notThrottled},
+		{"batch too large", errors.New("batch too large"), sizeThrottled},
+		{"rate limit", errors.New("project ID request rate exceeded"), rateThrottled},
+		{"too many logs", errors.New("query returned more than 10000 results, too many logs"), notThrottled},
+		{"gas limit", errors.New("exceeds block gas limit exceeded"), notThrottled},
+		{"unrelated", errors.New("execution reverted"), notThrottled},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyThrottle(tc.err); got != tc.exp {
+				t.Errorf("expected %v, got %v", tc.exp, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ERC-20 method selectors
+var (
+	nameSelector        = hexutil.MustDecode("0x06fdde03")
+	symbolSelector      = hexutil.MustDecode("0x95d89b41")
+	decimalsSelector    = hexutil.MustDecode("0x313ce567")
+	totalSupplySelector = hexutil.MustDecode("0x18160ddd")
+)
+
+var abiString, _ = abi.NewType("string", "", nil)
+
+// TokenMetadata is the ERC-20 metadata of a token, the methods a token doesn't
+// implement are left empty
+type TokenMetadata struct {
+	Name        string
+	Symbol      string
+	Decimals    *uint8
+	TotalSupply *big.Int
+}
+
+// revertMessages are lowercase fragments of the errors nodes answer for a
+// call the contract rejected, like a method it doesn't implement
+var revertMessages = []string{
+	"revert",
+	"invalid opcode",
+	"invalid jump",
+}
+
+// isExecutionReverted reports whether a call failed in the contract rather
+// than in the node or on the way to it
+func isExecutionReverted(err error) bool {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	// geth answers the reverts with a reason with code 3
+	if rpcErr.ErrorCode() == 3 {
+		return true
+	}
+	msg := strings.ToLower(rpcErr.Error())
+	for _, m := range revertMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenMetadata calls name, symbol, decimals and totalSupply of a token at
+// the latest block in a single batch. Reverted calls are methods the token
+// doesn't implement, any other failure is returned so the token is retried.
+func (c *EthClient) TokenMetadata(ctx context.Context, token common.Address) (*TokenMetadata, error) {
+	selectors := [][]byte{nameSelector, symbolSelector, decimalsSelector, totalSupplySelector}
+	batchElem := make([]rpc.BatchElem, len(selectors))
+	for i, selector := range selectors {
+		batchElem[i] = rpc.BatchElem{
+			Method: "eth_call",
+			Args: []any{map[string]any{
+				"to":   token,
+				"data": hexutil.Bytes(selector),
+			}, "latest"},
+			Result: &hexutil.Bytes{},
+		}
+	}
+
+	if err := c.batchCall(ctx, batchElem); err != nil {
+		return nil, err
+	}
+
+	results := make([][]byte, len(batchElem))
+	for i, elem := range batchElem {
+		if elem.Error == nil {
+			results[i] = *elem.Result.(*hexutil.Bytes)
+			continue
+		}
+		if !isExecutionReverted(elem.Error) {
+			return nil, fmt.Errorf("failed to call token %s: %w", token.Hex(), elem.Error)
+		}
+	}
+
+	metadata := &TokenMetadata{
+		Name:   decodeTokenString(results[0]),
+		Symbol: decodeTokenString(results[1]),
+	}
+	if len(results[2]) >= 32 {
+		if decimals := new(big.Int).SetBytes(results[2][:32]); decimals.IsUint64() && decimals.Uint64() <= 255 {
+			d := uint8(decimals.Uint64())
+			metadata.Decimals = &d
+		}
+	}
+	if len(results[3]) >= 32 {
+		metadata.TotalSupply = new(big.Int).SetBytes(results[3][:32])
+	}
+
+	return metadata, nil
+}
+
+// decodeTokenString decodes an ABI encoded string, legacy tokens such as MKR
+// return a bytes32 padded with zeros instead
+func decodeTokenString(data []byte) string {
+	var s string
+	if values, err := (abi.Arguments{{Type: abiString}}).Unpack(data); err == nil && len(values) == 1 {
+		s, _ = values[0].(string)
+	} else if len(data) == 32 {
+		s = string(data)
+	}
+
+	// postgres rejects NUL bytes and invalid UTF-8 in text columns
+	s = strings.ReplaceAll(s, "\x00", "")
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "")
+	}
+	return strings.TrimSpace(s)
+}
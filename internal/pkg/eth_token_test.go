@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeTokenString(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := (abi.Arguments{{Type: abiString}}).Pack("Wrapped Ether")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{name: "string", data: encoded, expected: "Wrapped Ether"},
+		{name: "bytes32", data: common.RightPadBytes([]byte("MKR"), 32), expected: "MKR"},
+		{name: "invalid utf8", data: common.RightPadBytes([]byte{'A', 0xff, 'B'}, 32), expected: "AB"},
+		{name: "empty", data: nil, expected: ""},
+		{name: "short", data: big.NewInt(1).Bytes(), expected: ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := decodeTokenString(tt.data); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// newTokenServer returns a JSON-RPC server that answers the name call with
+// the error and the other calls with an empty result
+func newTokenServer(t *testing.T, code int, message string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msgs []testRPCMessage
+		if err := json.NewDecoder(r.Body).Decode(&msgs); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := make([]map[string]any, len(msgs))
+		for i, msg := range msgs {
+			resp[i] = map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": "0x"}
+		}
+		resp[0] = map[string]any{"jsonrpc": "2.0", "id": msgs[0].ID, "error": map[string]any{"code": code, "message": message}}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestTokenMetadataErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		code    int
+		message string
+		failed  bool
+	}{
+		{name: "revert with reason", code: 3, message: "execution reverted: not implemented"},
+		{name: "revert", code: -32000, message: "execution reverted"},
+		{name: "invalid opcode", code: -32000, message: "invalid opcode: INVALID"},
+		{name: "timeout", code: -32000, message: "upstream request timeout", failed: true},
+		{name: "node error", code: -32603, message: "internal error", failed: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newTokenServer(t, tt.code, tt.message)
+			defer server.Close()
+
+			client, err := NewEthClient(EthClientConfig{URL: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			metadata, err := client.TokenMetadata(context.Background(), common.HexToAddress("0x01"))
+			if tt.failed {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", metadata)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if metadata.Name != "" {
+				t.Errorf("Expected no name, got %q", metadata.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// CallFrame is a call frame produced by the geth callTracer
+type CallFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     hexutil.Uint64  `json:"gas"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []CallFrame     `json:"calls,omitempty"`
+}
+
+// TxCallTrace is the call tree of a transaction returned by
+// debug_traceBlockByHash
+type TxCallTrace struct {
+	TxHash common.Hash `json:"txHash"`
+	Result *CallFrame  `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// ParityTraceAction is the action of a trace returned by trace_block
+type ParityTraceAction struct {
+	CallType       string          `json:"callType,omitempty"`
+	CreationMethod string          `json:"creationMethod,omitempty"`
+	From           *common.Address `json:"from,omitempty"`
+	To             *common.Address `json:"to,omitempty"`
+	Value          *hexutil.Big    `json:"value,omitempty"`
+	Gas            hexutil.Uint64  `json:"gas"`
+	// Address, RefundAddress and Balance are set on suicide traces
+	Address       *common.Address `json:"address,omitempty"`
+	RefundAddress *common.Address `json:"refundAddress,omitempty"`
+	Balance       *hexutil.Big    `json:"balance,omitempty"`
+}
+
+// ParityTraceResult is the result of a trace returned by trace_block
+type ParityTraceResult struct {
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Address *common.Address `json:"address,omitempty"`
+}
+
+// ParityTrace is a flat trace returned by trace_block
+type ParityTrace struct {
+	Type                string             `json:"type"`
+	Action              ParityTraceAction  `json:"action"`
+	Result              *ParityTraceResult `json:"result,omitempty"`
+	Error               string             `json:"error,omitempty"`
+	TraceAddress        []int              `json:"traceAddress"`
+	TransactionHash     *common.Hash       `json:"transactionHash,omitempty"`
+	TransactionPosition *uint64            `json:"transactionPosition,omitempty"`
+}
+
+// TraceBlockCalls returns the call tree of every transaction in the block
+// using debug_traceBlockByHash with the callTracer
+func (c *EthClient) TraceBlockCalls(ctx context.Context, hash common.Hash) ([]TxCallTrace, error) {
+	var traces []TxCallTrace
+	err := c.rpc.CallContext(ctx, &traces, "debug_traceBlockByHash", hash, map[string]any{
+		"tracer": "callTracer",
+	})
+	return traces, err
+}
+
+// TraceBlock returns the flat traces of the block using trace_block, which is
+// served by nodes implementing the parity trace module. trace_block only takes
+// a block number, the traces may be of another block after a reorg.
+func (c *EthClient) TraceBlock(ctx context.Context, number uint64) ([]ParityTrace, error) {
+	var traces []ParityTrace
+	err := c.rpc.CallContext(ctx, &traces, "trace_block", hexutil.EncodeUint64(number))
+	return traces, err
+}
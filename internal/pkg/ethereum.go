@@ -2,24 +2,49 @@ package pkg
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// defaultMaxBatchSize is used when EthClientConfig.MaxBatchSize is not set
+const defaultMaxBatchSize = 100
+
 // EthClient is a wrapper around the go-ethereum client
 type EthClient struct {
 	*ethclient.Client
 
-	rpc *rpc.Client
+	rpc       *rpc.Client
+	limiter   *rateLimiter
+	backoff   *throttleBackoff
+	batchSize *adaptiveBatchSize
+
+	signerMu sync.Mutex
+	signer   types.Signer
 }
 
 // EthClientConfig is the configuration for the Ethereum client
 type EthClientConfig struct {
 	URL string
+	// RateLimit is the maximum number of requests per second sent to the
+	// provider, zero means unlimited. Each element of a batch counts as one
+	// request.
+	RateLimit float64
+	// RateBurst is the bucket size of the rate limiter
+	RateBurst int
+	// MaxBatchSize is the largest number of calls sent in a single batch
+	MaxBatchSize int
 }
 
 // BatchTransctionReceiptsResult is the result of a batch transaction receipts call
@@ -36,12 +61,35 @@ type BatchHeaderByNumberResult struct {
 
 // NewEthClient creates a new Ethereum client
 func NewEthClient(config EthClientConfig) (*EthClient, error) {
-	rpcClient, err := rpc.Dial(config.URL)
+	limiter := newRateLimiter(config.RateLimit, config.RateBurst)
+	backoff := &throttleBackoff{}
+
+	httpClient := &http.Client{
+		Transport: &rateLimitedTransport{
+			limiter: limiter,
+			backoff: backoff,
+			base:    &instrumentedTransport{base: http.DefaultTransport},
+		},
+	}
+
+	rpcClient, err := rpc.DialOptions(context.Background(), config.URL, rpc.WithHTTPClient(httpClient))
 	if err != nil {
 		return nil, err
 	}
+
+	maxBatchSize := config.MaxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultMaxBatchSize
+	}
+
 	client := ethclient.NewClient(rpcClient)
-	return &EthClient{Client: client, rpc: rpcClient}, nil
+	return &EthClient{
+		Client:    client,
+		rpc:       rpcClient,
+		limiter:   limiter,
+		backoff:   backoff,
+		batchSize: newAdaptiveBatchSize(maxBatchSize),
+	}, nil
 }
 
 // BatchTransactionReceipts returns the transaction receipts for the given transaction hashes
@@ -55,7 +103,7 @@ func (c *EthClient) BatchTransactionReceipts(ctx context.Context, hash ...common
 		}
 	}
 
-	err := c.rpc.BatchCallContext(ctx, batchElem)
+	err := c.batchCall(ctx, batchElem)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +119,9 @@ func (c *EthClient) BatchTransactionReceipts(ctx context.Context, hash ...common
 	return result, err
 }
 
-// BatchHeaderByNumbers returns the headers for the given block numbers
+// BatchHeaderByNumbers returns the headers for the given block numbers, the
+// Err of a header that failed, was still throttled after the retries or was
+// not found is set
 func (c *EthClient) BatchHeaderByNumbers(ctx context.Context, numbers ...uint64) ([]BatchHeaderByNumberResult, error) {
 	batchElem := make([]rpc.BatchElem, len(numbers))
 	for i, n := range numbers {
@@ -82,7 +132,7 @@ func (c *EthClient) BatchHeaderByNumbers(ctx context.Context, numbers ...uint64)
 		}
 	}
 
-	err := c.rpc.BatchCallContext(ctx, batchElem)
+	err := c.batchCall(ctx, batchElem)
 	if err != nil {
 		return nil, err
 	}
@@ -97,3 +147,158 @@ func (c *EthClient) BatchHeaderByNumbers(ctx context.Context, numbers ...uint64)
 
 	return result, err
 }
+
+// Signer returns the signer of the latest fork for the provider's chain, the
+// chain ID is fetched once and cached
+func (c *EthClient) Signer(ctx context.Context) (types.Signer, error) {
+	c.signerMu.Lock()
+	defer c.signerMu.Unlock()
+
+	if c.signer != nil {
+		return c.signer, nil
+	}
+
+	chainID, err := c.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.signer = types.LatestSignerForChainID(chainID)
+
+	return c.signer, nil
+}
+
+// RecoverSender recovers the sender of a transaction from its signature.
+// Legacy, EIP-2930, EIP-1559 and EIP-4844 transactions are recovered locally,
+// other transaction types fall back to asking the provider.
+func (c *EthClient) RecoverSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+	signer, err := c.Signer(ctx)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	from, err := types.Sender(signer, tx)
+	if err == nil {
+		return from, nil
+	}
+	if !errors.Is(err, types.ErrTxTypeNotSupported) {
+		return common.Address{}, err
+	}
+
+	return c.TransactionSender(ctx, tx, block, index)
+}
+
+// ErrStateUnavailable is returned when the node pruned the state of the
+// requested block
+var ErrStateUnavailable = errors.New("historical state unavailable")
+
+// stateUnavailableMessages are lowercase fragments of the errors nodes answer
+// for a block whose state they pruned
+var stateUnavailableMessages = []string{
+	"missing trie node",
+	"historical state",
+}
+
+// CodeHashAt returns the keccak256 hash of the contract code at the given
+// block. ErrStateUnavailable is returned when the node doesn't keep the state
+// of the block, the code of a later block may differ so it isn't used instead.
+func (c *EthClient) CodeHashAt(ctx context.Context, account common.Address, blockNumber *big.Int) (common.Hash, error) {
+	code, err := c.CodeAt(ctx, account, blockNumber)
+	if err != nil {
+		if isStateUnavailable(err) {
+			return common.Hash{}, fmt.Errorf("%w: %w", ErrStateUnavailable, err)
+		}
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(code), nil
+}
+
+// isStateUnavailable tells whether the error means the node pruned the state
+// of the requested block
+func isStateUnavailable(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, m := range stateUnavailableMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// RawCall sends a request with already encoded params and returns the raw
+// result. Errors answered by the provider implement rpc.Error.
+func (c *EthClient) RawCall(ctx context.Context, method string, params ...json.RawMessage) (json.RawMessage, error) {
+	args := make([]any, len(params))
+	for i, p := range params {
+		args[i] = p
+	}
+
+	var result json.RawMessage
+	if err := c.rpc.CallContext(ctx, &result, method, args...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// batchCall sends the elements in batches no larger than the current adaptive
+// batch size. A batch rejected for its size is retried with half the size. A
+// batch rejected for our request rate is retried at the same size once the
+// provider's Retry-After or an exponential backoff has passed. Every accepted
+// batch lets the size grow back.
+func (c *EthClient) batchCall(ctx context.Context, elems []rpc.BatchElem) error {
+	retries := 0
+	for start := 0; start < len(elems); {
+		end := start + c.batchSize.Size()
+		if end > len(elems) {
+			end = len(elems)
+		}
+		chunk := elems[start:end]
+
+		// the transport takes one token for the HTTP request itself
+		if err := c.limiter.waitN(ctx, len(chunk)-1); err != nil {
+			return err
+		}
+
+		err := c.rpc.BatchCallContext(ctx, chunk)
+		throttled := classifyThrottle(err)
+		if err == nil {
+			for _, elem := range chunk {
+				if t := classifyThrottle(elem.Error); t > throttled {
+					throttled = t
+				}
+			}
+		}
+
+		switch {
+		case throttled == sizeThrottled && len(chunk) > 1:
+			c.batchSize.Shrink()
+			resetErrors(chunk)
+			continue
+		case throttled == rateThrottled && retries < maxThrottleRetries:
+			c.backoff.pause(throttleDelay(retries))
+			retries++
+			if err := c.backoff.wait(ctx); err != nil {
+				return err
+			}
+			resetErrors(chunk)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if throttled == notThrottled {
+			c.batchSize.Grow()
+		}
+		retries = 0
+		start = end
+	}
+
+	return nil
+}
+
+// resetErrors clears the errors of a batch about to be sent again
+func resetErrors(elems []rpc.BatchElem) {
+	for i := range elems {
+		elems[i].Error = nil
+	}
+}
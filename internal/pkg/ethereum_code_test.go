@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// newCodeErrorServer returns a JSON-RPC server that answers every request
+// with the error message
+func newCodeErrorServer(t *testing.T, message string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg testRPCMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      msg.ID,
+			"error":   map[string]any{"code": -32000, "message": message},
+		})
+	}))
+}
+
+func TestCodeHashAtStateUnavailable(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		message     string
+		unavailable bool
+	}{
+		{"missing trie node", "missing trie node 1a2b (path ) state 0x1a2b is not available", true},
+		{"historical state", "historical state 0x1a2b is not available", true},
+		{"other", "upstream request timeout", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newCodeErrorServer(t, tc.message)
+			defer server.Close()
+
+			client, err := NewEthClient(EthClientConfig{URL: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			_, err = client.CodeHashAt(context.Background(), common.HexToAddress("0x01"), big.NewInt(1))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := errors.Is(err, ErrStateUnavailable); got != tc.unavailable {
+				t.Errorf("expected ErrStateUnavailable %v, got %v: %v", tc.unavailable, got, err)
+			}
+		})
+	}
+}
+
+func TestBatchHeaderByNumbersErrors(t *testing.T) {
+	t.Parallel()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(0)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msgs []testRPCMessage
+		if err := json.NewDecoder(r.Body).Decode(&msgs); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := []map[string]any{
+			{"jsonrpc": "2.0", "id": msgs[0].ID, "result": header},
+			{"jsonrpc": "2.0", "id": msgs[1].ID, "result": nil},
+			{"jsonrpc": "2.0", "id": msgs[2].ID, "error": map[string]any{"code": -32000, "message": "upstream request timeout"}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client, err := NewEthClient(EthClientConfig{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	headers, err := client.BatchHeaderByNumbers(context.Background(), 1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers[0].Err != nil || headers[0].Header.Hash() != header.Hash() {
+		t.Errorf("Expected header %s, got %v %v", header.Hash().Hex(), headers[0].Header.Hash().Hex(), headers[0].Err)
+	}
+	if headers[1].Err == nil {
+		t.Errorf("Expected an error for a null block")
+	}
+	if headers[2].Err == nil {
+		t.Errorf("Expected the error of the element")
+	}
+}
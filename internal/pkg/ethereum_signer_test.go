@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+func TestRecoverSender(t *testing.T) {
+	t.Parallel()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := crypto.PubkeyToAddress(key.PublicKey)
+
+	chainID := big.NewInt(1)
+	signer := types.LatestSignerForChainID(chainID)
+	to := common.HexToAddress("0x01")
+
+	// the signer is preset so no RPC call is made
+	client := &EthClient{signer: signer}
+
+	testCases := []struct {
+		name string
+		tx   types.TxData
+	}{
+		{"legacy", &types.LegacyTx{Nonce: 1, To: &to, Gas: 21000, GasPrice: big.NewInt(1), Value: big.NewInt(1)}},
+		{"access list", &types.AccessListTx{ChainID: chainID, Nonce: 1, To: &to, Gas: 21000, GasPrice: big.NewInt(1)}},
+		{"dynamic fee", &types.DynamicFeeTx{ChainID: chainID, Nonce: 1, To: &to, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2)}},
+		{"blob", &types.BlobTx{ChainID: uint256.NewInt(1), Nonce: 1, To: to, Gas: 21000, GasTipCap: uint256.NewInt(1), GasFeeCap: uint256.NewInt(2), BlobFeeCap: uint256.NewInt(1), BlobHashes: []common.Hash{{0x01}}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tx, err := types.SignNewTx(key, signer, tc.tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			from, err := client.RecoverSender(context.Background(), tx, common.Hash{}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != expected {
+				t.Errorf("expected %s, got %s", expected.Hex(), from.Hex())
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds every readiness check so a hung dependency
+// doesn't hang the probe
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck checks a dependency, it returns nil when it is ready
+type HealthCheck func(ctx context.Context) error
+
+// namedCheck is a readiness check reported under name
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// CheckStatus is the result of a readiness check
+type CheckStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Readiness is the response of /readyz
+type Readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckStatus `json:"checks"`
+}
+
+// runChecks runs the checks concurrently
+func runChecks(ctx context.Context, checks []namedCheck) Readiness {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	readiness := Readiness{Status: "ok", Checks: make(map[string]CheckStatus, len(checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+			status := CheckStatus{Status: "ok"}
+			if err := c.check(ctx); err != nil {
+				status = CheckStatus{Status: "error", Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			readiness.Checks[c.name] = status
+			if status.Status != "ok" {
+				readiness.Status = "unavailable"
+			}
+		}(c)
+	}
+	wg.Wait()
+	return readiness
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// PingDB checks Postgres
+func PingDB(client *DBClient) HealthCheck {
+	return func(ctx context.Context) error {
+		return client.PingContext(ctx)
+	}
+}
+
+// PingRedis checks Redis
+func PingRedis(client *RedisClient) HealthCheck {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// PingRPC checks the Ethereum provider answers
+func PingRPC(client *EthClient) HealthCheck {
+	return func(ctx context.Context) error {
+		_, err := client.BlockNumber(ctx)
+		return err
+	}
+}
+
+// HeadLagCheck fails when the indexed blocks are more than maxLag blocks
+// behind the chain
+func HeadLagCheck(dbClient *DBClient, ethClient *EthClient, maxLag uint64) HealthCheck {
+	return func(ctx context.Context) error {
+		chainHead, err := ethClient.BlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+
+		var indexedHead uint64
+		err = dbClient.QueryRowContext(ctx, "SELECT COALESCE(MAX(number), 0) FROM blocks WHERE is_uncle = false").Scan(&indexedHead)
+		if err != nil {
+			return err
+		}
+
+		if chainHead > indexedHead && chainHead-indexedHead > maxLag {
+			return fmt.Errorf("indexed head %d is %d blocks behind the chain, over %d", indexedHead, chainHead-indexedHead, maxLag)
+		}
+		return nil
+	}
+}
+
+// StreamLagCheck fails when more than maxLag entries of a stream are waiting
+// for a consumer group
+func StreamLagCheck(client *RedisClient, stream, group string, maxLag int64) HealthCheck {
+	return func(ctx context.Context) error {
+		groups, err := client.XInfoGroups(ctx, stream).Result()
+		if err != nil {
+			return err
+		}
+
+		for _, g := range groups {
+			if g.Name != group {
+				continue
+			}
+			if g.Lag > maxLag {
+				return fmt.Errorf("%d entries of %s are waiting for %s, over %d", g.Lag, stream, group, maxLag)
+			}
+			return nil
+		}
+		return fmt.Errorf("consumer group %s of %s not found", group, stream)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build integration
+
+package pkg
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStreamLagCheck(t *testing.T) {
+	t.Parallel()
+
+	redisClient := redisClient()
+
+	ctx := context.Background()
+	stream := redisStream(ctx, t, redisClient, t.Name(), t.Name(), t.Name())
+	defer redisClient.Del(ctx, t.Name())
+
+	for i := 0; i < 3; i++ {
+		if _, err := stream.Add(ctx, StreamValue{"hello": "world"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := StreamLagCheck(redisClient, t.Name(), t.Name(), 3)(ctx); err != nil {
+		t.Errorf("Expected a lag of 3 to pass, got %v", err)
+	}
+	if err := StreamLagCheck(redisClient, t.Name(), t.Name(), 2)(ctx); err == nil {
+		t.Errorf("Expected a lag of 3 to fail over 2")
+	}
+	if err := StreamLagCheck(redisClient, t.Name(), "unknown", 2)(ctx); err == nil {
+		t.Errorf("Expected an unknown group to fail")
+	}
+}
@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestOpsServerReadyz(t *testing.T) {
+	t.Parallel()
+
+	logger := zerolog.Nop()
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	hung := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name     string
+		checks   map[string]HealthCheck
+		expected int
+		failed   []string
+	}{
+		{name: "no checks", expected: http.StatusOK},
+		{name: "ready", checks: map[string]HealthCheck{"postgres": ok, "redis": ok}, expected: http.StatusOK},
+		{name: "failing", checks: map[string]HealthCheck{"postgres": ok, "redis": failing}, expected: http.StatusServiceUnavailable, failed: []string{"redis"}},
+		{name: "hung", checks: map[string]HealthCheck{"rpc": hung}, expected: http.StatusServiceUnavailable, failed: []string{"rpc"}},
+	}
+
+	for _, test := range tests {
+		server := NewOpsServer("0", &logger)
+		for name, check := range test.checks {
+			server.AddCheck(name, check)
+		}
+
+		recorder := httptest.NewRecorder()
+		server.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if recorder.Code != test.expected {
+			t.Errorf("%s: Expected %d, got %d", test.name, test.expected, recorder.Code)
+		}
+
+		var readiness Readiness
+		if err := json.Unmarshal(recorder.Body.Bytes(), &readiness); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(readiness.Checks) != len(test.checks) {
+			t.Errorf("%s: Expected %d checks, got %v", test.name, len(test.checks), readiness.Checks)
+		}
+		for _, name := range test.failed {
+			if status := readiness.Checks[name]; status.Status != "error" || status.Error == "" {
+				t.Errorf("%s: Expected %s to fail, got %+v", test.name, name, status)
+			}
+		}
+	}
+}
+
+func TestOpsServerHealthz(t *testing.T) {
+	t.Parallel()
+
+	logger := zerolog.Nop()
+	server := NewOpsServer("0", &logger)
+	server.AddCheck("redis", func(ctx context.Context) error { return errors.New("down") })
+
+	recorder := httptest.NewRecorder()
+	server.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected 200 while a dependency is down, got %d", recorder.Code)
+	}
+}
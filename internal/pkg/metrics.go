@@ -0,0 +1,212 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// metricsNamespace prefixes the metrics of every service
+const metricsNamespace = "indexer"
+
+var (
+	rpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_request_duration_seconds",
+		Help:      "Latency of the JSON-RPC requests sent to the provider, batches of one method are labelled batch:<method> and other batches batch.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"method"})
+	rpcErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_errors_total",
+		Help:      "JSON-RPC requests that failed or were answered with an error.",
+	}, []string{"method"})
+	dbWriteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "db_write_duration_seconds",
+		Help:      "Latency of the database writes.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"operation"})
+)
+
+// StartDBWrite starts a span of a database write, the returned function ends
+// it and records the latency of the write
+func StartDBWrite(ctx context.Context, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := StartSpan(ctx, "db.write "+operation, trace.WithAttributes(attribute.String("db.operation", operation)))
+	return ctx, func(err error) {
+		dbWriteDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		EndSpan(span, err)
+	}
+}
+
+// instrumentedTransport traces the JSON-RPC requests sent through it and
+// records their latency and errors
+type instrumentedTransport struct {
+	base http.RoundTripper
+}
+
+// rpcMessage holds the fields of a JSON-RPC request or response the metrics
+// need
+type rpcMessage struct {
+	Method string          `json:"method"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// rpcMethod returns the method of a JSON-RPC request body, batch:<method>
+// for a batch of one method, or batch
+func rpcMethod(body []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var msgs []rpcMessage
+		if err := json.Unmarshal(body, &msgs); err != nil || len(msgs) == 0 || msgs[0].Method == "" {
+			return "batch"
+		}
+		for _, msg := range msgs[1:] {
+			if msg.Method != msgs[0].Method {
+				return "batch"
+			}
+		}
+		return "batch:" + msgs[0].Method
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Method == "" {
+		return "unknown"
+	}
+	return msg.Method
+}
+
+// hasRPCError reports whether a JSON-RPC response body holds an error, for a
+// batch any element with an error counts
+func hasRPCError(body []byte) bool {
+	var msgs []rpcMessage
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		if err := json.Unmarshal(body, &msgs); err != nil {
+			return true
+		}
+	} else {
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return true
+		}
+		msgs = append(msgs, msg)
+	}
+	for _, msg := range msgs {
+		if len(msg.Error) > 0 && string(msg.Error) != "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := "unknown"
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		method = rpcMethod(body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	ctx, span := StartSpan(req.Context(), "rpc "+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("rpc.system", "jsonrpc"),
+		attribute.String("rpc.method", method),
+	))
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		rpcErrors.WithLabelValues(method).Inc()
+		EndSpan(span, err)
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	rpcRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		rpcErrors.WithLabelValues(method).Inc()
+		EndSpan(span, err)
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || hasRPCError(body) {
+		rpcErrors.WithLabelValues(method).Inc()
+		err = fmt.Errorf("rpc %s failed with status %d", method, resp.StatusCode)
+	}
+	EndSpan(span, err)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// streamCollector reports the length of Redis streams and the lag and the
+// pending entries of their consumer groups when scraped
+type streamCollector struct {
+	client  *RedisClient
+	streams []string
+	logger  *zerolog.Logger
+
+	length  *prometheus.Desc
+	lag     *prometheus.Desc
+	pending *prometheus.Desc
+}
+
+// NewStreamCollector creates a collector of the streams
+func NewStreamCollector(client *RedisClient, logger *zerolog.Logger, streams ...string) prometheus.Collector {
+	return &streamCollector{
+		client:  client,
+		streams: streams,
+		logger:  logger,
+		length: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "stream", "length"),
+			"Entries in the stream.", []string{"stream"}, nil),
+		lag: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "stream", "group_lag"),
+			"Entries of the stream not delivered to the consumer group yet.", []string{"stream", "group"}, nil),
+		pending: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "stream", "group_pending"),
+			"Entries delivered to the consumer group and not acknowledged yet.", []string{"stream", "group"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *streamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.length
+	ch <- c.lag
+	ch <- c.pending
+}
+
+// Collect implements prometheus.Collector
+func (c *streamCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, stream := range c.streams {
+		length, err := c.client.XLen(ctx, stream).Result()
+		if err != nil {
+			c.logger.Warn().Err(err).Str("stream", stream).Msg("failed to collect stream length")
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.length, prometheus.GaugeValue, float64(length), stream)
+
+		groups, err := c.client.XInfoGroups(ctx, stream).Result()
+		if err != nil {
+			c.logger.Warn().Err(err).Str("stream", stream).Msg("failed to collect stream groups")
+			continue
+		}
+		for _, group := range groups {
+			ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(group.Lag), stream, group.Name)
+			ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(group.Pending), stream, group.Name)
+		}
+	}
+}
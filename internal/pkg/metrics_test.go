@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRPCMethod(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`:            "eth_blockNumber",
+		` [{"method":"eth_getBlockByNumber"},{"method":"eth_getBlockByNumber"}]`:     "batch:eth_getBlockByNumber",
+		`[{"method":"eth_getBlockByNumber"},{"method":"eth_getTransactionReceipt"}]`: "batch",
+		`[{"method":""}]`: "batch",
+		`[]`:              "batch",
+		`not json`:        "unknown",
+	}
+	for body, expected := range tests {
+		if got := rpcMethod([]byte(body)); got != expected {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestHasRPCError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		`{"id":1,"result":"0x1"}`:                       false,
+		`{"id":1,"result":"0x1","error":null}`:          false,
+		`{"id":1,"error":{"code":-32000}}`:              true,
+		`[{"id":1,"result":"0x1"},{"id":2,"result":1}]`: false,
+		`[{"id":1,"result":"0x1"},{"id":2,"error":{}}]`: true,
+		`<html>`: true,
+	}
+	for body, expected := range tests {
+		if got := hasRPCError([]byte(body)); got != expected {
+			t.Errorf("%s: Expected %v, got %v", body, expected, got)
+		}
+	}
+}
+
+func TestInstrumentedTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &instrumentedTransport{base: http.DefaultTransport}}
+	before := testutil.ToFloat64(rpcErrors.WithLabelValues("test_instrumentedTransport"))
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"test_instrumentedTransport"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := testutil.ToFloat64(rpcErrors.WithLabelValues("test_instrumentedTransport")); got != before+1 {
+		t.Errorf("Expected %v errors, got %v", before+1, got)
+	}
+	if got := testutil.CollectAndCount(rpcRequestDuration, "indexer_rpc_request_duration_seconds"); got == 0 {
+		t.Errorf("Expected observed latencies, got %d series", got)
+	}
+}
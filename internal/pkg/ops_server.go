@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+)
+
+// OpsServer serves the operational endpoints of a service beside its main
+// work: /metrics, /healthz telling the process is alive, and /readyz running
+// the readiness checks of its dependencies
+type OpsServer struct {
+	server *http.Server
+	mux    *http.ServeMux
+	logger *zerolog.Logger
+	checks []namedCheck
+}
+
+// NewOpsServer creates an ops server listening on port
+func NewOpsServer(port string, logger *zerolog.Logger) *OpsServer {
+	mux := http.NewServeMux()
+	s := &OpsServer{
+		server: &http.Server{Addr: ":" + port, Handler: mux},
+		mux:    mux,
+		logger: logger,
+	}
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	return s
+}
+
+// AddCheck adds a readiness check reported under name, it must be called
+// before Start
+func (s *OpsServer) AddCheck(name string, check HealthCheck) {
+	s.checks = append(s.checks, namedCheck{name: name, check: check})
+}
+
+func (s *OpsServer) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz answers 503 when any check fails, the status of every check is in
+// the body
+func (s *OpsServer) readyz(w http.ResponseWriter, r *http.Request) {
+	readiness := runChecks(r.Context(), s.checks)
+	status := http.StatusOK
+	if readiness.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, readiness)
+}
+
+// Start serves in the background
+func (s *OpsServer) Start() {
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Error().Err(err).Msg("failed to serve ops endpoints")
+		}
+	}()
+}
+
+// Close shuts the server down
+func (s *OpsServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to shutdown ops server")
+	}
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// responseCachePrefix starts the Redis keys of the API response cache
+const responseCachePrefix = "apicache"
+
+// ResponseCache keeps API responses in Redis. A response of a block that may
+// still become an uncle is tagged with the block hash so it can be dropped
+// once the block is replaced.
+type ResponseCache struct {
+	client *RedisClient
+}
+
+// NewResponseCache creates a response cache
+func NewResponseCache(client *RedisClient) *ResponseCache {
+	return &ResponseCache{client: client}
+}
+
+func entryKey(key string) string {
+	return responseCachePrefix + ":entry:" + key
+}
+
+func blockTagKey(blockHash string) string {
+	return responseCachePrefix + ":block:" + blockHash
+}
+
+// Get returns a cached response, nil when it isn't cached
+func (c *ResponseCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, entryKey(key)).Bytes()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	return value, err
+}
+
+// Set caches a response for ttl, a response tagged with a block hash is
+// dropped by InvalidateBlock
+func (c *ResponseCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, blockHash string) error {
+	_, err := c.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Set(ctx, entryKey(key), value, ttl)
+		if blockHash != "" {
+			pipe.SAdd(ctx, blockTagKey(blockHash), entryKey(key))
+			pipe.Expire(ctx, blockTagKey(blockHash), ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// InvalidateBlock drops the responses tagged with a block hash
+func (c *ResponseCache) InvalidateBlock(ctx context.Context, blockHash string) error {
+	keys, err := c.client.SMembers(ctx, blockTagKey(blockHash)).Result()
+	if err != nil {
+		return err
+	}
+	return c.client.Del(ctx, append(keys, blockTagKey(blockHash))...).Err()
+}
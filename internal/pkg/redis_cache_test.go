@@ -0,0 +1,35 @@
+//go:build integration
+
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheInvalidateBlock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cache := NewResponseCache(redisClient())
+	blockHash := t.Name() + time.Now().Format(time.RFC3339Nano)
+
+	if err := cache.Set(ctx, t.Name()+":unfinalized", []byte("unfinalized"), time.Minute, blockHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, t.Name()+":finalized", []byte("finalized"), time.Minute, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.InvalidateBlock(ctx, blockHash); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := cache.Get(ctx, t.Name()+":unfinalized"); err != nil || value != nil {
+		t.Errorf("Expected the tagged response to be dropped, got %s %v", value, err)
+	}
+	if value, err := cache.Get(ctx, t.Name()+":finalized"); err != nil || string(value) != "finalized" {
+		t.Errorf("Expected finalized, got %s %v", value, err)
+	}
+}
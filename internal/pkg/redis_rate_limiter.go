@@ -0,0 +1,125 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// rateLimitScript takes a token from the bucket of a key and counts the
+// request in the daily usage of the key, atomically so every API instance
+// shares the limits. The clock of Redis is used for the same reason.
+//
+// KEYS[1] is the bucket, KEYS[2] the prefix of the daily usage counters.
+// ARGV[1] is the refill rate per second, ARGV[2] the bucket size and
+// ARGV[3] the daily quota, 0 is unlimited.
+// It returns the status, 1 allowed, 0 rate limited or -1 over quota, the
+// tokens left, the milliseconds until a token is available and the usage.
+var rateLimitScript = goredis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local quota = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local usageKey = KEYS[2] .. math.floor(now / 86400000)
+
+local used = tonumber(redis.call('GET', usageKey) or '0')
+if quota > 0 and used >= quota then
+	return {-1, 0, 0, used}
+end
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local status = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	status = 1
+	used = redis.call('INCR', usageKey)
+	redis.call('EXPIRE', usageKey, 172800)
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {status, math.floor(tokens), wait, used}
+`)
+
+// RateLimit is the token bucket and the daily quota of a key
+type RateLimit struct {
+	// Rate is the tokens added to the bucket per second
+	Rate float64
+	// Burst is the size of the bucket
+	Burst int
+	// DailyQuota is the requests allowed per UTC day, 0 is unlimited
+	DailyQuota int64
+}
+
+// RateLimitResult is the outcome of a request
+type RateLimitResult struct {
+	Allowed bool
+	// QuotaExceeded is set when the request was rejected by the daily quota
+	QuotaExceeded bool
+	// Remaining is the whole tokens left in the bucket
+	Remaining int
+	// RetryAfter is the time until a token is available
+	RetryAfter time.Duration
+	// Used is the requests allowed today
+	Used int64
+}
+
+// RedisRateLimiter enforces per-key token buckets and daily quotas in Redis
+type RedisRateLimiter struct {
+	client *RedisClient
+	prefix string
+}
+
+// NewRedisRateLimiter creates a limiter, its keys in Redis start with prefix
+func NewRedisRateLimiter(client *RedisClient, prefix string) *RedisRateLimiter {
+	return &RedisRateLimiter{client: client, prefix: prefix}
+}
+
+func (l *RedisRateLimiter) bucketKey(key string) string {
+	return fmt.Sprintf("%s:%s:bucket", l.prefix, key)
+}
+
+func (l *RedisRateLimiter) usageKeyPrefix(key string) string {
+	return fmt.Sprintf("%s:%s:usage:", l.prefix, key)
+}
+
+// Allow takes a token for a request of a key
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	if limit.Rate <= 0 || limit.Burst < 1 {
+		return RateLimitResult{}, fmt.Errorf("invalid rate limit %v burst %d", limit.Rate, limit.Burst)
+	}
+
+	values, err := rateLimitScript.Run(ctx, l.client, []string{l.bucketKey(key), l.usageKeyPrefix(key)}, limit.Rate, limit.Burst, limit.DailyQuota).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return RateLimitResult{
+		Allowed:       values[0] == 1,
+		QuotaExceeded: values[0] == -1,
+		Remaining:     int(values[1]),
+		RetryAfter:    time.Duration(values[2]) * time.Millisecond,
+		Used:          values[3],
+	}, nil
+}
+
+// Usage returns the requests of a key allowed on the UTC day of t, usage is
+// kept for two days
+func (l *RedisRateLimiter) Usage(ctx context.Context, key string, t time.Time) (int64, error) {
+	day := t.Unix() / int64(24*time.Hour/time.Second)
+	used, err := l.client.Get(ctx, fmt.Sprintf("%s%d", l.usageKeyPrefix(key), day)).Int64()
+	if err == goredis.Nil {
+		return 0, nil
+	}
+	return used, err
+}
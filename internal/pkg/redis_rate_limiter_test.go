@@ -0,0 +1,68 @@
+//go:build integration
+
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRedisRateLimiterAllow(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	redisClient := redisClient()
+	limiter := NewRedisRateLimiter(redisClient, t.Name())
+	key := time.Now().Format(time.RFC3339Nano)
+	defer redisClient.Del(ctx, limiter.bucketKey(key))
+
+	limit := RateLimit{Rate: 1, Burst: 2}
+	for i := 0; i < 2; i++ {
+		result, err := limiter.Allow(ctx, key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed {
+			t.Fatalf("Expected request %d to be allowed, got %+v", i, result)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.QuotaExceeded || result.RetryAfter <= 0 {
+		t.Errorf("Expected rate limited with a retry after, got %+v", result)
+	}
+
+	used, err := limiter.Usage(ctx, key, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 2 {
+		t.Errorf("Expected 2 requests used, got %d", used)
+	}
+}
+
+func TestRedisRateLimiterQuota(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	redisClient := redisClient()
+	limiter := NewRedisRateLimiter(redisClient, t.Name())
+	key := time.Now().Format(time.RFC3339Nano)
+	defer redisClient.Del(ctx, limiter.bucketKey(key))
+
+	limit := RateLimit{Rate: 100, Burst: 100, DailyQuota: 1}
+	if result, err := limiter.Allow(ctx, key, limit); err != nil || !result.Allowed {
+		t.Fatalf("Expected the first request to be allowed, got %+v %v", result, err)
+	}
+	result, err := limiter.Allow(ctx, key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || !result.QuotaExceeded {
+		t.Errorf("Expected quota exceeded, got %+v", result)
+	}
+}
@@ -16,6 +16,7 @@ type (
 		streamName   string
 		groupName    string
 		consumerName string
+		claimMinIdle time.Duration
 	}
 
 	// RedisStreamConfig is the config for a RedisStream
@@ -24,6 +25,10 @@ type (
 		GroupName    string
 		ConsumerName string
 		Client       *RedisClient
+		// ClaimMinIdle is how long a message may stay unacknowledged before
+		// Read delivers it again, to this or any other consumer of the group.
+		// Zero never delivers a message twice.
+		ClaimMinIdle time.Duration
 	}
 )
 
@@ -34,6 +39,7 @@ func NewRedisStream(ctx context.Context, cfg RedisStreamConfig) (*RedisStream, e
 		streamName:   cfg.StreamName,
 		groupName:    cfg.GroupName,
 		consumerName: cfg.ConsumerName,
+		claimMinIdle: cfg.ClaimMinIdle,
 	}
 
 	err := cfg.Client.XGroupCreateMkStream(ctx, cfg.StreamName, cfg.GroupName, "$").Err()
@@ -57,15 +63,32 @@ func NewRedisStream(ctx context.Context, cfg RedisStreamConfig) (*RedisStream, e
 	return stream, nil
 }
 
-// Read reads messages from the stream
+// Read reads messages from the stream. Reading new messages with ">" first
+// claims the messages left unacknowledged for ClaimMinIdle, so a message whose
+// processing failed is retried, and returns no message when none arrived
+// within ClaimMinIdle.
 func (r *RedisStream) Read(ctx context.Context, id string, count int) ([]StreamMessage, error) {
+	// block until a new message arrives, or while messages are retried until
+	// the next claim is due
+	block := time.Duration(0)
+	if id == ">" && r.claimMinIdle > 0 {
+		messages, err := r.claim(ctx, count)
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+		block = r.claimMinIdle
+	}
+
 	streams, err := r.client.XReadGroup(ctx, &goredis.XReadGroupArgs{
 		Group:    r.groupName,
 		Consumer: r.consumerName,
 		Streams:  []string{r.streamName, id},
 		Count:    int64(count),
-		Block:    0,
+		Block:    block,
 	}).Result()
+	if err == goredis.Nil {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -84,6 +107,31 @@ func (r *RedisStream) Read(ctx context.Context, id string, count int) ([]StreamM
 	return result, nil
 }
 
+// claim takes over up to count messages of the group left unacknowledged for
+// claimMinIdle
+func (r *RedisStream) claim(ctx context.Context, count int) ([]StreamMessage, error) {
+	messages, _, err := r.client.XAutoClaim(ctx, &goredis.XAutoClaimArgs{
+		Stream:   r.streamName,
+		Group:    r.groupName,
+		Consumer: r.consumerName,
+		MinIdle:  r.claimMinIdle,
+		Start:    "0-0",
+		Count:    int64(count),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]StreamMessage, 0, len(messages))
+	for _, message := range messages {
+		result = append(result, StreamMessage{
+			ID:     message.ID,
+			Values: message.Values,
+		})
+	}
+	return result, nil
+}
+
 // Ack acknowledges a message
 func (r *RedisStream) Ack(ctx context.Context, id string) error {
 	return r.client.XAck(ctx, r.streamName, r.groupName, id).Err()
@@ -6,6 +6,7 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	goredis "github.com/redis/go-redis/v9"
 )
@@ -110,3 +111,67 @@ func TestRedisStreamRead(t *testing.T) {
 		})
 	}
 }
+
+func TestRedisStreamReadClaimsUnacked(t *testing.T) {
+	t.Parallel()
+
+	redisClient := redisClient()
+
+	ctx := context.Background()
+	streamName := t.Name()
+	groupName := t.Name()
+
+	stream, err := NewRedisStream(ctx, RedisStreamConfig{
+		Client:       redisClient,
+		StreamName:   streamName,
+		GroupName:    groupName,
+		ConsumerName: t.Name(),
+		ClaimMinIdle: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redisClient.Del(ctx, streamName)
+
+	id, err := stream.Add(ctx, StreamValue{"hello": "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := stream.Read(ctx, ">", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].ID != id {
+		t.Fatalf("expected message %s, got %v", id, messages)
+	}
+
+	// not acknowledged, the next read waits for ClaimMinIdle and the one
+	// after it delivers the message again
+	messages, err = stream.Read(ctx, ">", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) == 0 {
+		messages, err = stream.Read(ctx, ">", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(messages) != 1 || messages[0].ID != id {
+		t.Fatalf("expected message %s to be delivered again, got %v", id, messages)
+	}
+
+	if err := stream.Ack(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	messages, err = stream.Read(ctx, ">", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Errorf("expected no message after ack, got %v", messages)
+	}
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of every span of the services
+const tracerName = "github.com/korprulu/interview-homework-b"
+
+// Tracing exporters
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+// TracingConfig is the config of the tracer provider
+type TracingConfig struct {
+	ServiceName string
+	// Exporter is TracingExporterNone, TracingExporterStdout or
+	// TracingExporterOTLP. The OTLP exporter reads its endpoint from the
+	// standard OTEL_EXPORTER_OTLP_* variables.
+	Exporter    string
+	SampleRatio float64
+}
+
+// NewTracerProvider installs the global tracer provider and the W3C trace
+// context propagator, the returned function flushes the pending spans. With
+// no exporter, spans are not recorded but trace context is still passed on.
+func NewTracerProvider(ctx context.Context, cfg TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", TracingExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// StartSpan starts a span of the services
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// EndSpan records err on span and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// streamValueCarrier carries trace context in the fields of a stream value
+type streamValueCarrier StreamValue
+
+func (c streamValueCarrier) Get(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c streamValueCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c streamValueCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectTraceContext adds the trace context of ctx to a stream value, in its
+// traceparent field
+func InjectTraceContext(ctx context.Context, value StreamValue) StreamValue {
+	otel.GetTextMapPropagator().Inject(ctx, streamValueCarrier(value))
+	return value
+}
+
+// ExtractTraceContext returns ctx with the trace context of a stream value
+func ExtractTraceContext(ctx context.Context, value StreamValue) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, streamValueCarrier(value))
+}
@@ -0,0 +1,52 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContextStreamValue(t *testing.T) {
+	t.Parallel()
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer(t.Name()).Start(context.Background(), "enqueue")
+	defer span.End()
+
+	value := InjectTraceContext(ctx, StreamValue{"number": "0x1"})
+	if _, ok := value["traceparent"].(string); !ok {
+		t.Fatalf("Expected a traceparent field, got %v", value)
+	}
+	if value["number"] != "0x1" {
+		t.Errorf("Expected the fields kept, got %v", value)
+	}
+
+	extracted := trace.SpanContextFromContext(ExtractTraceContext(context.Background(), value))
+	if extracted.TraceID() != span.SpanContext().TraceID() || extracted.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("Expected %v, got %v", span.SpanContext(), extracted)
+	}
+
+	if extracted := trace.SpanContextFromContext(ExtractTraceContext(context.Background(), StreamValue{})); extracted.IsValid() {
+		t.Errorf("Expected no trace context, got %v", extracted)
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewTracerProvider(context.Background(), TracingConfig{Exporter: "jaeger"}); err == nil {
+		t.Errorf("Expected an unknown exporter to fail")
+	}
+	shutdown, err := NewTracerProvider(context.Background(), TracingConfig{Exporter: TracingExporterNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
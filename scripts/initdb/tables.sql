@@ -5,11 +5,142 @@ CREATE TABLE blocks (
     timestamp BIGINT NOT NULL,
     status VARCHAR(15) NOT NULL,
     is_uncle BOOLEAN DEFAULT FALSE,
+    miner VARCHAR(42),
+    gas_used BIGINT,
+    gas_limit BIGINT,
+    base_fee_per_gas NUMERIC(78, 0) NULL,
+    difficulty NUMERIC(78, 0) NULL,
+    extra_data VARCHAR,
+    state_root VARCHAR(66),
+    transactions_root VARCHAR(66),
+    receipts_root VARCHAR(66),
+    logs_bloom VARCHAR(514),
+    sha3_uncles VARCHAR(66),
+    mix_hash VARCHAR(66),
+    nonce VARCHAR(18),
+    size BIGINT,
+    withdrawals_root VARCHAR(66),
+    blob_gas_used BIGINT,
+    excess_blob_gas BIGINT,
+    parent_beacon_block_root VARCHAR(66),
+    -- the transactions are stored by the transaction processors, a block is
+    -- complete once this many are stored
+    transaction_count INT NOT NULL DEFAULT 0,
     PRIMARY KEY (number, hash)
 );
 
+CREATE INDEX blocks_timestamp_idx ON blocks (timestamp);
+
+CREATE TABLE withdrawals (
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    index BIGINT NOT NULL,
+    validator_index BIGINT NOT NULL,
+    address VARCHAR(42) NOT NULL,
+    amount BIGINT NOT NULL,
+    PRIMARY KEY (block_hash, index)
+);
+
+CREATE INDEX withdrawals_address_idx ON withdrawals (address);
+
+-- ommer headers referenced by a block, blocks.is_uncle marks orphaned blocks
+CREATE TABLE uncles (
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    index SMALLINT NOT NULL,
+    number BIGINT NOT NULL,
+    hash VARCHAR(66) NOT NULL,
+    parent_hash VARCHAR(66) NOT NULL,
+    miner VARCHAR(42) NOT NULL,
+    timestamp BIGINT NOT NULL,
+    difficulty NUMERIC(78, 0) NULL,
+    gas_limit BIGINT,
+    gas_used BIGINT,
+    PRIMARY KEY (block_hash, index)
+);
+
+CREATE INDEX uncles_hash_idx ON uncles (hash);
+
+CREATE TABLE internal_transactions (
+    tx_hash VARCHAR(66) NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    index INTEGER NOT NULL,
+    trace_address JSONB NOT NULL,
+    depth SMALLINT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    from_address VARCHAR(42) NOT NULL,
+    to_address VARCHAR(42),
+    value VARCHAR NOT NULL,
+    gas BIGINT,
+    gas_used BIGINT,
+    error VARCHAR,
+    PRIMARY KEY (block_hash, tx_hash, index)
+);
+
+CREATE INDEX internal_transactions_tx_hash_idx ON internal_transactions (tx_hash);
+
+CREATE TABLE contracts (
+    address VARCHAR(42) NOT NULL,
+    creator VARCHAR(42) NOT NULL,
+    creation_tx_hash VARCHAR(66) NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    -- NULL when the provider pruned the state of the block
+    bytecode_hash VARCHAR(66),
+    PRIMARY KEY (address, block_hash, creation_tx_hash)
+);
+
+CREATE TABLE address_balance_changes (
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    address VARCHAR(42) NOT NULL,
+    delta NUMERIC(78, 0) NOT NULL,
+    PRIMARY KEY (block_hash, address)
+);
+
+CREATE INDEX address_balance_changes_address_idx ON address_balance_changes (address, block_number);
+
+CREATE TABLE token_transfers (
+    tx_hash VARCHAR(66) NOT NULL,
+    log_index BIGINT NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    token VARCHAR(42) NOT NULL,
+    from_address VARCHAR(42) NOT NULL,
+    to_address VARCHAR(42) NOT NULL,
+    value NUMERIC(78, 0) NOT NULL,
+    PRIMARY KEY (block_hash, log_index)
+);
+
+CREATE INDEX token_transfers_token_idx ON token_transfers (token);
+
+CREATE TABLE tokens (
+    address VARCHAR(42) PRIMARY KEY,
+    name VARCHAR NOT NULL DEFAULT '',
+    symbol VARCHAR NOT NULL DEFAULT '',
+    decimals SMALLINT,
+    total_supply VARCHAR NOT NULL DEFAULT '',
+    -- a token is stored with its first transfers and enriched_at stays NULL
+    -- until the enricher fetches its metadata
+    enriched_at TIMESTAMP
+);
+
+CREATE INDEX tokens_pending_idx ON tokens (address) WHERE enriched_at IS NULL;
+
+CREATE TABLE token_balances (
+    token VARCHAR(42) NOT NULL,
+    holder VARCHAR(42) NOT NULL,
+    balance NUMERIC(78, 0) NOT NULL,
+    PRIMARY KEY (token, holder)
+);
+
+CREATE INDEX token_balances_token_balance_idx ON token_balances (token, balance DESC);
+CREATE INDEX token_balances_holder_idx ON token_balances (holder);
+
+-- a transaction re-included by a reorg is stored once per block
 CREATE TABLE transactions (
-    hash VARCHAR(66) PRIMARY KEY,
+    hash VARCHAR(66) NOT NULL,
     index SMALLINT NOT NULL,
     block_hash VARCHAR(66) NOT NULL,
     block_number BIGINT NOT NULL,
@@ -18,5 +149,85 @@ CREATE TABLE transactions (
     nonce BIGINT,
     data BYTEA,
     value VARCHAR,
-    logs JSONB
+    logs JSONB,
+    contract_address VARCHAR(42),
+    status SMALLINT,
+    gas_used BIGINT,
+    cumulative_gas_used BIGINT,
+    logs_bloom VARCHAR(514),
+    blob_gas_used BIGINT,
+    blob_gas_price NUMERIC(78, 0) NULL,
+    type SMALLINT NOT NULL DEFAULT 0,
+    gas BIGINT,
+    gas_price NUMERIC(78, 0) NULL,
+    max_fee_per_gas NUMERIC(78, 0) NULL,
+    max_priority_fee_per_gas NUMERIC(78, 0) NULL,
+    max_fee_per_blob_gas NUMERIC(78, 0) NULL,
+    blob_versioned_hashes JSONB,
+    access_list JSONB,
+    chain_id NUMERIC(78, 0) NULL,
+    v VARCHAR,
+    r VARCHAR,
+    s VARCHAR,
+    PRIMARY KEY (hash, block_hash)
+);
+
+CREATE INDEX transactions_block_hash_idx ON transactions (block_hash, index);
+CREATE INDEX transactions_from_address_idx ON transactions (from_address, block_number);
+CREATE INDEX transactions_to_address_idx ON transactions (to_address, block_number);
+-- eth_getLogs looks up the logs of an address or a topic by containment
+CREATE INDEX transactions_logs_idx ON transactions USING GIN (logs jsonb_path_ops);
+
+CREATE TABLE quarantined_blocks (
+    number BIGINT,
+    hash VARCHAR(66),
+    status VARCHAR(15) NOT NULL,
+    reason TEXT NOT NULL,
+    quarantined_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (number, hash)
+);
+
+CREATE TABLE webhook_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    url VARCHAR NOT NULL,
+    address VARCHAR(42) NOT NULL DEFAULT '',
+    topic VARCHAR(66) NOT NULL DEFAULT '',
+    min_confirmations INT NOT NULL DEFAULT 0,
+    secret VARCHAR NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    -- the last block matched by the dispatcher, NULL until the first match
+    matched_block BIGINT,
+    CHECK (address <> '' OR topic <> '')
+);
+
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions (id),
+    event VARCHAR(15) NOT NULL,
+    tx_hash VARCHAR(66) NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    corrects_delivery_id BIGINT REFERENCES webhook_deliveries (id),
+    status VARCHAR(15) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (subscription_id, event, tx_hash, block_hash)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    prefix VARCHAR(12) NOT NULL,
+    rate_limit DOUBLE PRECISION NOT NULL CHECK (rate_limit > 0),
+    burst INT NOT NULL CHECK (burst > 0),
+    daily_quota BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP
 );
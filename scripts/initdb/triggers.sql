@@ -0,0 +1,44 @@
+-- Notifications consumed by the API live feed. A notification is delivered
+-- when the inserting transaction commits, and the payload must stay below
+-- 8000 bytes, so transactions only carry their hash and block hash.
+
+CREATE FUNCTION notify_new_block() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('new_blocks', json_build_object(
+        'block_num', NEW.number,
+        'block_hash', NEW.hash,
+        'parent_hash', NEW.parent_hash,
+        'block_time', NEW.timestamp
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER blocks_notify_insert AFTER INSERT ON blocks
+    FOR EACH ROW EXECUTE FUNCTION notify_new_block();
+
+CREATE FUNCTION notify_reorged_block() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('reorged_blocks', json_build_object(
+        'block_num', NEW.number,
+        'block_hash', NEW.hash
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER blocks_notify_reorg AFTER UPDATE OF is_uncle ON blocks
+    FOR EACH ROW WHEN (NEW.is_uncle AND NOT OLD.is_uncle) EXECUTE FUNCTION notify_reorged_block();
+
+CREATE FUNCTION notify_new_transaction() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('new_transactions', json_build_object(
+        'tx_hash', NEW.hash,
+        'block_hash', NEW.block_hash
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transactions_notify_insert AFTER INSERT ON transactions
+    FOR EACH ROW EXECUTE FUNCTION notify_new_transaction();